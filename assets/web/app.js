@@ -0,0 +1,180 @@
+"use strict";
+
+// the secret of dl serve --rpc-secret is asked once and kept in the browser
+var secret = localStorage.getItem("dl-secret") || "";
+
+function api(method, path, body) {
+  var opt = { method: method, headers: {} };
+  if (secret) {
+    opt.headers["Authorization"] = "Bearer " + secret;
+  }
+  if (body) {
+    opt.headers["Content-Type"] = "application/json";
+    opt.body = JSON.stringify(body);
+  }
+  return fetch("api" + path, opt).then(function (resp) {
+    if (resp.status === 401) {
+      secret = prompt("Secret") || "";
+      localStorage.setItem("dl-secret", secret);
+      throw new Error("unauthorized");
+    }
+    if (resp.status === 204) {
+      return null;
+    }
+    return resp.json().then(function (data) {
+      if (!resp.ok) {
+        throw new Error(data.error || resp.statusText);
+      }
+      return data;
+    });
+  });
+}
+
+function showError(err) {
+  var el = document.getElementById("error");
+  el.textContent = err ? err.message : "";
+  el.hidden = !err;
+}
+
+function bytes(n) {
+  var units = ["B", "kB", "MB", "GB", "TB", "PB"];
+  var i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return (i === 0 ? n : n.toFixed(1)) + " " + units[i];
+}
+
+// unknown sizes are reported as the max uint64
+function knownSize(job) {
+  return job.size > 0 && job.size < Number.MAX_SAFE_INTEGER;
+}
+
+function bar(done, total) {
+  var outer = document.createElement("div");
+  outer.className = "bar";
+  var inner = document.createElement("div");
+  inner.style.width = (total > 0 ? Math.min(100, (done * 100) / total) : 0) + "%";
+  outer.appendChild(inner);
+  return outer;
+}
+
+function button(label, action) {
+  var b = document.createElement("button");
+  b.textContent = label;
+  b.onclick = function () {
+    action().then(refresh).catch(showError);
+  };
+  return b;
+}
+
+function render(job) {
+  var el = document.createElement("div");
+  el.className = "job";
+
+  var head = document.createElement("div");
+  head.className = "head";
+  var name = document.createElement("span");
+  name.className = "name";
+  name.textContent = job.location || job.name || job.url;
+  name.title = job.url;
+  head.appendChild(name);
+
+  var actions = document.createElement("span");
+  var id = "/jobs/" + job.id;
+  if (job.status === "active" || job.status === "queued") {
+    actions.appendChild(button("Pause", function () { return api("POST", id + "/pause"); }));
+  }
+  if (job.status === "paused") {
+    actions.appendChild(button("Resume", function () { return api("POST", id + "/resume"); }));
+  }
+  if (job.status === "failed") {
+    actions.appendChild(button("Retry", function () { return api("POST", id + "/resume"); }));
+  }
+  actions.appendChild(button(job.status === "completed" ? "Clear" : "Cancel", function () {
+    return api("DELETE", id);
+  }));
+  head.appendChild(actions);
+  el.appendChild(head);
+
+  var meta = document.createElement("div");
+  meta.className = "meta";
+  var text = job.status;
+  if (knownSize(job)) {
+    var done = job.status === "completed" ? job.size : job.downloaded;
+    text += " · " + bytes(done) + " / " + bytes(job.size) + " (" + ((done * 100) / job.size).toFixed(1) + "%)";
+  } else if (job.downloaded) {
+    text += " · " + bytes(job.downloaded);
+  }
+  if (job.speed) {
+    text += " · " + bytes(job.speed) + "/s";
+  }
+  meta.textContent = text;
+  el.appendChild(meta);
+
+  if (job.error) {
+    var failed = document.createElement("div");
+    failed.className = "failed";
+    failed.textContent = job.error;
+    el.appendChild(failed);
+  }
+
+  if (knownSize(job)) {
+    el.appendChild(bar(job.status === "completed" ? job.size : job.downloaded, job.size));
+  }
+  if (job.chunks && job.chunks.length > 1) {
+    var chunks = document.createElement("div");
+    chunks.className = "chunks";
+    job.chunks.forEach(function (c) {
+      chunks.appendChild(bar(c.done, c.end - c.start));
+    });
+    el.appendChild(chunks);
+  }
+  return el;
+}
+
+var sections = {
+  active: ["active"],
+  queued: ["queued", "paused"],
+  completed: ["completed", "failed"]
+};
+
+function refresh() {
+  return api("GET", "/jobs").then(function (jobs) {
+    showError(null);
+    var speed = 0;
+    Object.keys(sections).forEach(function (key) {
+      var list = document.getElementById(key);
+      list.innerHTML = "";
+      jobs.filter(function (j) {
+        return sections[key].indexOf(j.status) >= 0;
+      }).forEach(function (j) {
+        speed += j.speed || 0;
+        list.appendChild(render(j));
+      });
+      if (!list.children.length) {
+        list.innerHTML = '<p class="empty">Nothing here</p>';
+      }
+    });
+    document.getElementById("stats").textContent = bytes(speed) + "/s";
+  });
+}
+
+document.getElementById("add").onsubmit = function (e) {
+  e.preventDefault();
+  var req = {
+    url: document.getElementById("url").value.trim(),
+    name: document.getElementById("name").value.trim(),
+    directory: document.getElementById("directory").value.trim()
+  };
+  api("POST", "/jobs", req).then(function () {
+    e.target.reset();
+    return refresh();
+  }).catch(showError);
+};
+
+refresh().catch(showError);
+setInterval(function () {
+  refresh().catch(showError);
+}, 1000);
@@ -0,0 +1,360 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	indexFileName = "index.json"
+	lockFileName  = "index.lock"
+	objectsDir    = "objects"
+)
+
+// Object represents a cached file stored by its SHA-256 digest
+type Object struct {
+	Digest   string    `json:"digest"` // hex encoded SHA-256
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"` // of the stored file, a changed file isn't served
+	URL      string    `json:"url"`      // the url it was last downloaded from
+	LastUsed time.Time `json:"last_used"`
+}
+
+// index represents the content of the index file
+type index struct {
+	Keys    map[string]string  `json:"keys"`    // url and ETag to digest
+	Objects map[string]*Object `json:"objects"` // by digest
+}
+
+// Cache stores the downloaded files by their SHA-256 digest, so the urls serving the same content share a file;
+// the files are linked to the destinations by reflink where the file system supports it, copied otherwise. A hard
+// link would let the changes of a destination e.g: by a hook, reach the cached file and the other destinations
+type Cache struct {
+	dir     string
+	maxSize int64 // the least recently used files are evicted above the size; 0 means unlimited
+
+	mu sync.Mutex // the file lock doesn't exclude the goroutines of the process on every platform
+}
+
+// New return the cache of the directory e.g: ~/.dl/cache
+func New(dir string, maxSize int64) *Cache {
+	return &Cache{dir: dir, maxSize: maxSize}
+}
+
+func key(url, etag string) string {
+	return url + " " + etag
+}
+
+func (c *Cache) objectPath(digest string) string {
+	return filepath.Join(c.dir, objectsDir, digest)
+}
+
+// Get link the cached file of the url and ETag to dst
+func (c *Cache) Get(url, etag, dst string) (string, bool, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return "", false, err
+	}
+	digest, ok := idx.Keys[key(url, etag)]
+	if !ok {
+		return "", false, nil
+	}
+	obj := idx.Objects[digest]
+	if obj == nil || !valid(c.objectPath(digest), obj) {
+		delete(idx.Keys, key(url, etag))
+		if obj != nil {
+			os.Remove(c.objectPath(digest))
+			delete(idx.Objects, digest)
+		}
+		return "", false, c.save(idx)
+	}
+
+	if err := link(c.objectPath(digest), dst); err != nil {
+		return "", false, err
+	}
+	obj.LastUsed = time.Now()
+	return digest, true, c.save(idx)
+}
+
+// Put add the downloaded file of the url and ETag; the least recently used files are evicted above the max size
+func (c *Cache) Put(url, etag, path, digest string) error {
+	if len(digest) != 64 || strings.ContainsAny(digest, `/\.`) {
+		return errors.New("cache: invalid digest")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	idx, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	obj := idx.Objects[digest]
+	if obj == nil {
+		if err := os.MkdirAll(filepath.Join(c.dir, objectsDir), os.ModePerm); err != nil {
+			return err
+		}
+		if err := link(path, c.objectPath(digest)); err != nil {
+			return err
+		}
+		if fi, err = os.Stat(c.objectPath(digest)); err != nil {
+			return err
+		}
+		obj = &Object{Digest: digest, Size: fi.Size(), ModTime: fi.ModTime()}
+		idx.Objects[digest] = obj
+	}
+	obj.URL = url
+	obj.LastUsed = time.Now()
+	idx.Keys[key(url, etag)] = digest
+
+	if c.maxSize > 0 {
+		c.evict(idx, c.maxSize)
+	}
+	return c.save(idx)
+}
+
+// List return the cached files, most recently used first
+func (c *Cache) List() ([]Object, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]Object, 0, len(idx.Objects))
+	for _, o := range idx.Objects {
+		objs = append(objs, *o)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].LastUsed.After(objs[j].LastUsed) })
+	return objs, nil
+}
+
+// Size return the total size of the cached files
+func (c *Cache) Size() (int64, error) {
+	objs, err := c.List()
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, o := range objs {
+		size += o.Size
+	}
+	return size, nil
+}
+
+// Prune evict the least recently used files until the cache fits the size, along with the files left behind
+// by interrupted writes; 0 empties the cache. It return the number of evicted files and their size
+func (c *Cache) Prune(size int64) (int, int64, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return 0, 0, err
+	}
+	n, freed := c.evict(idx, size)
+
+	// the files unknown to the index e.g: added by a process which lost the race to save the index
+	files, err := ioutil.ReadDir(filepath.Join(c.dir, objectsDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return n, freed, err
+	}
+	for _, fi := range files {
+		if _, ok := idx.Objects[fi.Name()]; !ok && os.Remove(filepath.Join(c.dir, objectsDir, fi.Name())) == nil {
+			n++
+			freed += fi.Size()
+		}
+	}
+	return n, freed, c.save(idx)
+}
+
+// evict remove the least recently used files until the total size is at most max
+func (c *Cache) evict(idx *index, max int64) (int, int64) {
+	objs := make([]*Object, 0, len(idx.Objects))
+	var total int64
+	for _, o := range idx.Objects {
+		objs = append(objs, o)
+		total += o.Size
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].LastUsed.Before(objs[j].LastUsed) })
+
+	n, freed := 0, int64(0)
+	for _, o := range objs {
+		if total <= max {
+			break
+		}
+		if err := os.Remove(c.objectPath(o.Digest)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		delete(idx.Objects, o.Digest)
+		total -= o.Size
+		n++
+		freed += o.Size
+	}
+	for k, digest := range idx.Keys {
+		if _, ok := idx.Objects[digest]; !ok {
+			delete(idx.Keys, k)
+		}
+	}
+	return n, freed
+}
+
+// valid report whether the file is unchanged since it was stored i.e: has the size and the modification time of
+// the object; an object of an index without the time is checked against its digest once
+func valid(path string, obj *Object) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.Size() != obj.Size {
+		return false
+	}
+	if !obj.ModTime.IsZero() {
+		return fi.ModTime().Equal(obj.ModTime)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil || hex.EncodeToString(h.Sum(nil)) != obj.Digest {
+		return false
+	}
+	obj.ModTime = fi.ModTime()
+	return true
+}
+
+// lock take the index for a read-modify-write, against the other goroutines and the other processes sharing
+// the directory e.g: the daemon and a download in the foreground; the returned func releases it
+func (c *Cache) lock() (func(), error) {
+	c.mu.Lock()
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(c.dir, lockFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		c.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+		c.mu.Unlock()
+	}, nil
+}
+
+// load read the index; an empty index if there is none
+func (c *Cache) load() (*index, error) {
+	idx := &index{Keys: make(map[string]string), Objects: make(map[string]*Object)}
+	bb, err := ioutil.ReadFile(filepath.Join(c.dir, indexFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bb, idx); err != nil {
+		return nil, err
+	}
+	if idx.Keys == nil {
+		idx.Keys = make(map[string]string)
+	}
+	if idx.Objects == nil {
+		idx.Objects = make(map[string]*Object)
+	}
+	return idx, nil
+}
+
+// save write the index through a temporary file renamed over it, the readers never see a partial index;
+// MUST hold the lock
+func (c *Cache) save(idx *index) error {
+	bb, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.dir, indexFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(bb)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, indexFileName))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// link make dst a copy of src: a reflink sharing the storage until either is modified, or a plain copy;
+// dst is replaced atomically
+func link(src, dst string) error {
+	tmp := dst + ".dl-cache"
+	os.Remove(tmp)
+	err := reflink(src, tmp)
+	if err != nil {
+		err = copyFile(src, tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
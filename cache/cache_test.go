@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// putFile write the content to a downloaded file and add it to the cache
+func putFile(t *testing.T, c *Cache, url, etag string, content []byte) string {
+	t.Helper()
+	fn := filepath.Join(t.TempDir(), "download.bin")
+	if err := ioutil.WriteFile(fn, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	if err := c.Put(url, etag, fn, digest); err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+func readFile(t *testing.T, fn string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestGetPut(t *testing.T) {
+	c := New(t.TempDir(), 0)
+	content := []byte("cached content")
+	digest := putFile(t, c, "https://example.com/file.bin", `"v1"`, content)
+
+	dst := filepath.Join(t.TempDir(), "file.bin")
+	got, ok, err := c.Get("https://example.com/file.bin", `"v1"`, dst)
+	if err != nil || !ok || got != digest {
+		t.Fatalf("Get = %s, %v, %v, want %s", got, ok, err, digest)
+	}
+	if !bytes.Equal(readFile(t, dst), content) {
+		t.Fatal("destination differs from the cached content")
+	}
+
+	// another ETag or url is a miss
+	for _, k := range [][2]string{{"https://example.com/file.bin", `"v2"`}, {"https://example.com/other.bin", `"v1"`}} {
+		if _, ok, err := c.Get(k[0], k[1], filepath.Join(t.TempDir(), "file.bin")); ok || err != nil {
+			t.Fatalf("Get(%s, %s) = %v, %v, want a miss", k[0], k[1], ok, err)
+		}
+	}
+	if err := c.Put("https://example.com/file.bin", "", dst, "../../etc/passwd"); err == nil {
+		t.Fatal("expected an error for an invalid digest")
+	}
+}
+
+func TestDestinationChanged(t *testing.T) {
+	c := New(t.TempDir(), 0)
+	content := []byte("#!/bin/sh\necho cached\n")
+	putFile(t, c, "https://example.com/run.sh", "", content)
+
+	// a hook making the destination executable and editing it doesn't reach the cache or the other destinations
+	first := filepath.Join(t.TempDir(), "run.sh")
+	second := filepath.Join(t.TempDir(), "run.sh")
+	for _, dst := range []string{first, second} {
+		if _, ok, err := c.Get("https://example.com/run.sh", "", dst); !ok || err != nil {
+			t.Fatalf("Get = %v, %v", ok, err)
+		}
+	}
+	if err := os.Chmod(first, 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(first, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("#!/bin/sh\necho edited\n"), 0)
+	f.Close()
+
+	if !bytes.Equal(readFile(t, second), content) {
+		t.Fatal("the edit of a destination changed another destination")
+	}
+	if fi, _ := os.Stat(second); fi.Mode().Perm()&0111 != 0 {
+		t.Fatal("the mode of a destination changed another destination")
+	}
+	third := filepath.Join(t.TempDir(), "run.sh")
+	if _, ok, err := c.Get("https://example.com/run.sh", "", third); !ok || err != nil {
+		t.Fatalf("Get after the edit = %v, %v", ok, err)
+	}
+	if !bytes.Equal(readFile(t, third), content) {
+		t.Fatal("the edit of a destination changed the cached file")
+	}
+}
+
+func TestObjectChanged(t *testing.T) {
+	c := New(t.TempDir(), 0)
+	digest := putFile(t, c, "https://example.com/file.bin", "", []byte("cached content"))
+
+	// same size, another modification time
+	obj := c.objectPath(digest)
+	if err := ioutil.WriteFile(obj, []byte("CACHED CONTENT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(obj, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if _, ok, err := c.Get("https://example.com/file.bin", "", filepath.Join(t.TempDir(), "file.bin")); ok || err != nil {
+		t.Fatalf("Get = %v, %v, want a miss for a changed file", ok, err)
+	}
+	if _, err := os.Stat(obj); !os.IsNotExist(err) {
+		t.Fatalf("changed file kept: %v", err)
+	}
+	if objs, _ := c.List(); len(objs) != 0 {
+		t.Fatalf("changed file still listed: %+v", objs)
+	}
+}
+
+func TestIndexWithoutModTime(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, 0)
+	good := putFile(t, c, "https://example.com/good.bin", "", []byte("good content"))
+	bad := putFile(t, c, "https://example.com/bad.bin", "", []byte("bad content"))
+	if err := ioutil.WriteFile(c.objectPath(bad), []byte("BAD CONTENT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the index of a previous version
+	idx, err := c.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range idx.Objects {
+		o.ModTime = time.Time{}
+	}
+	if err := c.save(idx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := c.Get("https://example.com/good.bin", "", filepath.Join(t.TempDir(), "good.bin")); !ok || err != nil {
+		t.Fatalf("Get = %v, %v, want the verified file", ok, err)
+	}
+	if idx, _ := c.load(); idx.Objects[good].ModTime.IsZero() {
+		t.Fatal("modification time of the verified file isn't recorded")
+	}
+	if _, ok, err := c.Get("https://example.com/bad.bin", "", filepath.Join(t.TempDir(), "bad.bin")); ok || err != nil {
+		t.Fatalf("Get = %v, %v, want a miss for a file differing from its digest", ok, err)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	c := New(t.TempDir(), 250)
+	var digests []string
+	for i := 0; i < 3; i++ {
+		digests = append(digests, putFile(t, c, fmt.Sprintf("https://example.com/%d.bin", i), "", bytes.Repeat([]byte{byte('a' + i)}, 100)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	// the least recently used is evicted above the max size
+	objs, err := c.List()
+	if err != nil || len(objs) != 2 || objs[0].Digest != digests[2] || objs[1].Digest != digests[1] {
+		t.Fatalf("listed %+v, %v, want the 2 most recent files", objs, err)
+	}
+	if _, ok, _ := c.Get("https://example.com/0.bin", "", filepath.Join(t.TempDir(), "0.bin")); ok {
+		t.Fatal("evicted file served")
+	}
+	if size, err := c.Size(); err != nil || size != 200 {
+		t.Fatalf("size %d, %v", size, err)
+	}
+
+	// the files left by interrupted writes are pruned too
+	if err := ioutil.WriteFile(filepath.Join(c.dir, objectsDir, "stray"), []byte("stray"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, freed, err := c.Prune(0)
+	if err != nil || n != 3 || freed != 205 {
+		t.Fatalf("pruned %d files of %d bytes, %v, want 3 of 205", n, freed, err)
+	}
+	if objs, _ := c.List(); len(objs) != 0 {
+		t.Fatalf("listed %+v after pruning everything", objs)
+	}
+}
+
+func TestConcurrentPut(t *testing.T) {
+	c := New(t.TempDir(), 0)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			putFile(t, c, fmt.Sprintf("https://example.com/%d.bin", i), "", []byte(fmt.Sprintf("content %d", i)))
+		}(i)
+	}
+	wg.Wait()
+
+	objs, err := c.List()
+	if err != nil || len(objs) != 20 {
+		t.Fatalf("listed %d files, %v, want 20", len(objs), err)
+	}
+	bb, _ := ioutil.ReadFile(filepath.Join(c.dir, indexFileName))
+	var idx index
+	if err := json.Unmarshal(bb, &idx); err != nil || len(idx.Keys) != 20 {
+		t.Fatalf("index of %d keys, %v", len(idx.Keys), err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package cache
+
+import "os"
+
+// lockFile is a no-op, the index is only guarded against the goroutines of the process
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build linux
+// +build linux
+
+package cache
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl sharing the extents of a file e.g: on btrfs and xfs
+const ficlone = 0x40049409
+
+// reflink create dst as a copy on write clone of src
+func reflink(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd()); errno != 0 {
+		out.Close()
+		os.Remove(dst)
+		return errno
+	}
+	return out.Close()
+}
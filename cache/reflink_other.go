@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package cache
+
+import "errors"
+
+// reflink isn't supported; the files are copied
+func reflink(src, dst string) error {
+	return errors.New("cache: reflink not supported")
+}
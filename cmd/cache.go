@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/thedevsaddam/dl/cache"
+	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+var (
+	pruneAll bool
+
+	cmdCache = &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the download cache",
+		Long:  `Manage the download cache in ~/.dl/cache; enable it with: dl config --cache true`,
+	}
+
+	cmdCacheList = &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List the cached files",
+		Long:    `List the cached files, most recently used first`,
+		Args:    cobra.NoArgs,
+		Run:     listCache,
+	}
+
+	cmdCachePrune = &cobra.Command{
+		Use:   "prune",
+		Short: "Evict the least recently used files above the maximum size",
+		Long:  `Evict the least recently used files above the maximum size and the files left behind by interrupted writes`,
+		Args:  cobra.NoArgs,
+		Run:   pruneCache,
+	}
+
+	cmdCacheSize = &cobra.Command{
+		Use:   "size",
+		Short: "Print the size of the cache",
+		Long:  `Print the size of the cache and its maximum size`,
+		Args:  cobra.NoArgs,
+		Run:   printCacheSize,
+	}
+)
+
+func init() {
+	cmdCachePrune.Flags().BoolVar(&pruneAll, "all", false, "evict every file")
+
+	cmdCache.AddCommand(cmdCacheList, cmdCachePrune, cmdCacheSize)
+	cmdDL.AddCommand(cmdCache)
+}
+
+// newCache return the cache of the state directory
+func newCache(cfg config.Config) *cache.Cache {
+	return cache.New(filepath.Join(stateDir(), "cache"), cacheMaxSize(cfg))
+}
+
+// cacheMaxSize return the configured maximum size of the cache
+func cacheMaxSize(cfg config.Config) int64 {
+	if cfg.Cache.MaxSize > 0 {
+		return cfg.Cache.MaxSize
+	}
+	return config.DefaultCacheMaxSize
+}
+
+func listCache(cmd *cobra.Command, args []string) {
+	objs, err := newCache(config.DefaultConfig()).List()
+	if err != nil {
+		exitWithError(err)
+	}
+	if len(objs) == 0 {
+		out.Infof("Cache is empty\n")
+		return
+	}
+
+	w := tabwriter.NewWriter(out.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SHA-256\tSIZE\tLAST USED\tURL")
+	for _, o := range objs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", o.Digest[:12], downloader.HumanReadableBytes(uint64(o.Size)), o.LastUsed.Local().Format("2006-01-02 15:04"), o.URL)
+	}
+	w.Flush()
+}
+
+func pruneCache(cmd *cobra.Command, args []string) {
+	cfg := config.DefaultConfig()
+	size := cacheMaxSize(cfg)
+	if pruneAll {
+		size = 0
+	}
+	n, freed, err := newCache(cfg).Prune(size)
+	if err != nil {
+		exitWithError(err)
+	}
+	out.Infof("Evicted %d files, %s freed\n", n, downloader.HumanReadableBytes(uint64(freed)))
+}
+
+func printCacheSize(cmd *cobra.Command, args []string) {
+	cfg := config.DefaultConfig()
+	size, err := newCache(cfg).Size()
+	if err != nil {
+		exitWithError(err)
+	}
+	status := "enabled"
+	if !cfg.Cache.Enabled {
+		status = "disabled"
+	}
+	out.Printf("%s of %s (%s)\n", downloader.HumanReadableBytes(uint64(size)), downloader.HumanReadableBytes(uint64(cacheMaxSize(cfg))), status)
+}
@@ -5,16 +5,24 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/values"
 )
 
 var (
 	path       string
 	subPath    string
+	mimePath   string
 	autoUpdate string
+	s3Endpoint string
+	s3Region   string
+	s3Profile  string
+	useCache   string
+	cacheLimit string
 
 	cmdConfig = &cobra.Command{
 		Use:   "config",
@@ -27,9 +35,15 @@ var (
 func init() {
 	cmdConfig.Flags().StringVarP(&path, "path", "p", "", "destination directory where the file will be downloaded")
 	cmdConfig.Flags().StringVarP(&subPath, "subpath", "s", "", "sub directory map in this format subdirectoryName:.ext1,.ext2. e.g: video:.mp4,.mkv")
+	cmdConfig.Flags().StringVarP(&mimePath, "mime-subpath", "m", "", "sub directory map of the media types for the extensions without one, in this format subdirectoryName:type1,type2. e.g: video:video/*")
 	cmdConfig.Flags().IntVarP(&concurrent, "concurrent", "c", 0, "number of concurrent process will be running, default: 5")
 	cmdConfig.Flags().BoolVarP(&debug, "debug", "d", false, "display configuration")
 	cmdConfig.Flags().StringVarP(&autoUpdate, "auto-update", "a", "", "enable/disable auto-update. e.g: -a true, -a false")
+	cmdConfig.Flags().StringVar(&s3Endpoint, "s3-endpoint", "", "custom endpoint for s3:// urls. e.g: http://localhost:9000")
+	cmdConfig.Flags().StringVar(&s3Region, "s3-region", "", "region for s3:// urls, default: resolved from AWS_REGION or ~/.aws/config")
+	cmdConfig.Flags().StringVar(&s3Profile, "s3-profile", "", "AWS credentials profile for s3:// urls, default: resolved from AWS_PROFILE")
+	cmdConfig.Flags().StringVar(&useCache, "cache", "", "enable/disable the download cache in ~/.dl/cache. e.g: --cache true")
+	cmdConfig.Flags().StringVar(&cacheLimit, "cache-max-size", "", "maximum size of the download cache, the least recently used files are evicted. e.g: 20GB")
 	cmdDL.AddCommand(cmdConfig)
 }
 
@@ -45,6 +59,7 @@ func setConfig(cmd *cobra.Command, args []string) {
 
 	oldCfg := config.DefaultConfig()
 	newCfg := config.Config{Directory: path, Concurrency: uint(concurrent)}
+	newCfg.S3 = config.S3Config{Endpoint: s3Endpoint, Region: s3Region, Profile: s3Profile}
 	newCfg.AutoUpdate = oldCfg.AutoUpdate
 	if autoUpdate == "true" {
 		newCfg.AutoUpdate = true
@@ -52,6 +67,20 @@ func setConfig(cmd *cobra.Command, args []string) {
 		newCfg.AutoUpdate = false
 	}
 
+	newCfg.Cache.Enabled = oldCfg.Cache.Enabled
+	if useCache == "true" {
+		newCfg.Cache.Enabled = true
+	} else if useCache == "false" {
+		newCfg.Cache.Enabled = false
+	}
+	if cacheLimit != "" {
+		size, err := parseBytes(cacheLimit)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		newCfg.Cache.MaxSize = size
+	}
+
 	if subPath != "" {
 		newCfg.SubDirMap = config.DefaultConfig().SubDirMap // assign old config
 
@@ -65,6 +94,17 @@ func setConfig(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if mimePath != "" {
+		newCfg.MimeDirMap = make(values.MapStrSliceString)
+
+		pp := strings.Split(mimePath, ":")
+		if len(pp) > 1 {
+			for _, t := range strings.Split(pp[1], ",") {
+				newCfg.MimeDirMap.Add(pp[0], t)
+			}
+		}
+	}
+
 	if err := config.SetConfig(newCfg); err != nil {
 		log.Fatalln(err)
 	}
@@ -75,7 +115,29 @@ func setConfig(cmd *cobra.Command, args []string) {
 		}
 		bb, err := json.MarshalIndent(config.DefaultConfig(), "", "  ")
 		if err == nil {
-			fmt.Println(string(bb))
+			out.Printf("%s\n", bb)
 		}
 	}
 }
+
+// parseBytes parse a size with an optional unit e.g: 512MB, 20GB, 1.5TB
+func parseBytes(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   float64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	text := strings.ToUpper(strings.TrimSpace(s))
+	mul := float64(1)
+	for _, u := range units {
+		if strings.HasSuffix(text, u.suffix) {
+			text, mul = strings.TrimSpace(strings.TrimSuffix(text, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q, e.g: 512MB, 20GB", s)
+	}
+	return int64(n * mul), nil
+}
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/daemon"
+	"github.com/thedevsaddam/dl/downloader"
+	"github.com/thedevsaddam/dl/notifier"
+)
+
+var (
+	jobs              int
+	listen            string
+	rpcSecret         string
+	rpcAllowOriginAll bool
+
+	cmdDaemon = &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the background daemon owning the download queue",
+		Long:  `Run the background daemon owning the download queue; the queue survives restarts`,
+		Args:  cobra.NoArgs,
+		Run:   runDaemon,
+	}
+
+	cmdServe = &cobra.Command{
+		Use:   "serve",
+		Short: "Run the daemon with an aria2 compatible JSON-RPC server",
+		Long:  `Run the daemon with an aria2 compatible JSON-RPC server at /jsonrpc over http and websocket`,
+		Args:  cobra.NoArgs,
+		Run:   runDaemon,
+	}
+
+	cmdAdd = &cobra.Command{
+		Use:   "add <url>...",
+		Short: "Add downloads to the daemon queue",
+		Long:  `Add downloads to the daemon queue`,
+		Args:  cobra.MinimumNArgs(1),
+		Run:   addJobs,
+	}
+
+	cmdList = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the downloads of the daemon queue",
+		Long:    `List the downloads of the daemon queue`,
+		Args:    cobra.NoArgs,
+		Run:     listJobs,
+	}
+
+	cmdPause = &cobra.Command{
+		Use:   "pause <id>...",
+		Short: "Pause downloads of the daemon queue",
+		Long:  `Pause downloads of the daemon queue, the progress is kept to resume later`,
+		Args:  cobra.MinimumNArgs(1),
+		Run: forEachJob(func(c *daemon.Client, id int) error {
+			_, err := c.Pause(id)
+			return err
+		}),
+	}
+
+	cmdResume = &cobra.Command{
+		Use:   "resume <id>...",
+		Short: "Resume paused or failed downloads of the daemon queue",
+		Long:  `Resume paused or failed downloads of the daemon queue`,
+		Args:  cobra.MinimumNArgs(1),
+		Run: forEachJob(func(c *daemon.Client, id int) error {
+			_, err := c.Resume(id)
+			return err
+		}),
+	}
+
+	cmdRemove = &cobra.Command{
+		Use:     "remove <id>...",
+		Aliases: []string{"rm"},
+		Short:   "Remove downloads from the daemon queue",
+		Long:    `Remove downloads from the daemon queue, the partial file of an unfinished download is deleted`,
+		Args:    cobra.MinimumNArgs(1),
+		Run: forEachJob(func(c *daemon.Client, id int) error {
+			return c.Remove(id)
+		}),
+	}
+)
+
+func init() {
+	cmdDaemon.Flags().IntVarP(&jobs, "jobs", "j", 1, "number of downloads running at the same time")
+	cmdDaemon.Flags().BoolVarP(&debug, "debug", "d", false, "debug print the essential logs")
+	cmdServe.Flags().IntVarP(&jobs, "jobs", "j", 1, "number of downloads running at the same time")
+	cmdServe.Flags().BoolVarP(&debug, "debug", "d", false, "debug print the essential logs")
+	cmdServe.Flags().StringVarP(&listen, "listen", "l", "127.0.0.1:6800", "address of the JSON-RPC server, a secret is required off the loopback")
+	cmdServe.Flags().StringVar(&rpcSecret, "rpc-secret", "", "secret token the JSON-RPC clients must send, required when listening on a public interface")
+	cmdServe.Flags().BoolVar(&rpcAllowOriginAll, "rpc-allow-origin-all", false, "allow the browsers of any origin e.g: extensions, to call the JSON-RPC server")
+	cmdAdd.Flags().StringVarP(&name, "name", "n", "", "destination name with extension, only for a single url. e.g: foo.jpg")
+	cmdAdd.Flags().StringVarP(&path, "path", "p", "", "destination directory where the file will be downloaded")
+
+	cmdDL.AddCommand(cmdDaemon, cmdServe, cmdAdd, cmdList, cmdPause, cmdResume, cmdRemove)
+}
+
+// stateDir return the directory holding the daemon queue and socket
+func stateDir() string {
+	dir, err := config.Dir()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return dir
+}
+
+func runDaemon(cmd *cobra.Command, args []string) {
+	cfg := config.DefaultConfig()
+	dir := stateDir()
+	managers := sync.Map{} // job id to the download manager, for the hooks once done
+
+	d, err := daemon.New(daemon.Options{
+		Dir:            dir,
+		Jobs:           jobs,
+		Log:            newLogger(true),
+		Listen:         listen,
+		Secret:         rpcSecret,
+		AllowOriginAll: rpcAllowOriginAll,
+		Root:           cfg.Directory,
+		Version:        Version,
+		Factory: func(job daemon.Job) *downloader.DownloadManager {
+			dm := newDownloadManager(cfg)
+			if job.Directory != "" {
+				dm.ApplyOption(downloader.WithFilePath(job.Directory))
+				dm.ApplyOption(downloader.WithSkipSubPathMap())
+			}
+			if job.Name != "" {
+				dm.ApplyOption(downloader.WithFilename(job.Name))
+			} else if job.Location != "" {
+				dm.ApplyOption(downloader.WithFilename(filepath.Base(job.Location)))
+			}
+			recordHistory(dm, job.URL)
+			managers.Store(job.ID, dm)
+			return dm
+		},
+		OnDone: func(job daemon.Job) {
+			if dm, ok := managers.Load(job.ID); ok {
+				managers.Delete(job.ID)
+				if job.Status == daemon.StatusFailed {
+					runHooks(dm.(*downloader.DownloadManager), job.URL, nil, errors.New(job.Error))
+				} else {
+					runHooks(dm.(*downloader.DownloadManager), job.URL, &downloader.Result{Path: job.Location, Size: job.Size, Digest: job.Digest}, nil)
+				}
+			}
+			n := notifier.New("DL [Terminal Downloader]")
+			if job.Status == daemon.StatusFailed {
+				n.Notify("Download failed", fmt.Sprintf("Job %d: %s", job.ID, job.URL))
+				return
+			}
+			n.Notify("Download complete!", fmt.Sprintf("File: %s", job.Location))
+		},
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	out.Infof("Listening on %s\n", daemon.SocketPath(dir))
+	if err := d.Run(ctx); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func addJobs(cmd *cobra.Command, args []string) {
+	if name != "" && len(args) > 1 {
+		out.Errorf("Error: --name can only be used with a single url\n")
+		os.Exit(1)
+	}
+
+	// the daemon runs in another working directory
+	dir := path
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		dir = abs
+	}
+
+	c := daemon.NewClient(stateDir())
+	for _, arg := range args {
+		u, err := resolveURL(arg)
+		if err != nil {
+			out.Errorf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		job, err := c.Add(daemon.Request{URL: u, Name: name, Directory: dir})
+		if err != nil {
+			out.Errorf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		out.Infof("Added job %d: %s\n", job.ID, job.URL)
+	}
+}
+
+func listJobs(cmd *cobra.Command, args []string) {
+	jobs, err := daemon.NewClient(stateDir()).List()
+	if err != nil {
+		out.Errorf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(jobs) == 0 {
+		out.Infof("Queue is empty\n")
+		return
+	}
+
+	w := tabwriter.NewWriter(out.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tPROGRESS\tSIZE\tNAME")
+	for _, j := range jobs {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", j.ID, j.Status, jobProgress(j), jobSize(j), jobName(j))
+	}
+	w.Flush()
+}
+
+// jobProgress return the downloaded percentage of the job
+func jobProgress(j daemon.Job) string {
+	if j.Status == daemon.StatusCompleted {
+		return "100%"
+	}
+	if j.Size == 0 || j.Size == ^uint64(0) {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(j.Downloaded)*100/float64(j.Size))
+}
+
+// jobSize return the size of the job; unknown until the meta information is fetched
+func jobSize(j daemon.Job) string {
+	if j.Size == 0 || j.Size == ^uint64(0) {
+		return "-"
+	}
+	return downloader.HumanReadableBytes(j.Size)
+}
+
+// jobName return the most specific name known for the job
+func jobName(j daemon.Job) string {
+	switch {
+	case j.Location != "":
+		return j.Location
+	case j.Name != "":
+		return j.Name
+	case j.Error != "":
+		return j.URL + " (" + j.Error + ")"
+	}
+	return j.URL
+}
+
+// forEachJob return a command runner applying the action on every job id of the arguments
+func forEachJob(action func(c *daemon.Client, id int) error) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		c := daemon.NewClient(stateDir())
+		failed := false
+		for _, arg := range args {
+			id, err := strconv.Atoi(arg)
+			if err == nil {
+				err = action(c, id)
+			} else {
+				err = errors.New("invalid job id: " + arg)
+			}
+			if err != nil {
+				out.Errorf("Error: job %s: %s\n", arg, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
@@ -2,16 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	netUrl "net/url"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/thedevsaddam/dl/config"
 	"github.com/thedevsaddam/dl/downloader"
-	"github.com/thedevsaddam/dl/logger"
 	"github.com/thedevsaddam/dl/notifier"
 	"github.com/thedevsaddam/dl/update"
 )
@@ -30,6 +31,9 @@ For more info visit: https://github.com/thedevsaddam/dl
 
 const (
 	unknown = "unknown"
+
+	// stdoutPath is the --output streaming the file to stdout
+	stdoutPath = "-"
 )
 
 var (
@@ -37,6 +41,23 @@ var (
 	name       string
 	concurrent int
 	debug      bool
+	variant    string
+	seedRatio  float64
+	recursive  bool
+	accept     []string
+	reject     []string
+	maxDepth   int
+	progress   string
+	jsonOutput bool
+	noCache    bool
+	extractDir string
+	stripComps int
+	rmArchive  bool
+	decompress bool
+	noPrealloc bool
+	bufferSize string
+	fsync      bool
+	outputPath string
 
 	GitCommit = unknown
 	Version   = unknown
@@ -60,12 +81,35 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
-	cmdDL.Flags().StringVarP(&url, "url", "u", "", "url should be the address where the file will be downloaded. e.g: https://example.com/foo.jpg")
+	cobra.OnInitialize(initConfig, initOutput, initLogger)
+	cmdDL.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print the errors only")
+	cmdDL.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable the colors, also disabled by the NO_COLOR environment variable")
+	cmdDL.PersistentFlags().StringVar(&logFile, "log-file", "", "append the logs to the file e.g: for a bug report")
+	cmdDL.PersistentFlags().StringVar(&logLevel, "log-level", "info", "minimum level of the logs: debug, info, warn or error; --debug implies debug")
+	cmdDL.PersistentFlags().StringVar(&logFormat, "log-format", "text", "format of the logs: text or json")
+	cmdDL.Flags().StringVarP(&url, "url", "u", "", "url should be the address where the file will be downloaded. e.g: https://example.com/foo.jpg, s3://bucket/foo.jpg, file:///tmp/foo.jpg, magnet:?xt=urn:btih:...")
 	cmdDL.Flags().StringVarP(&name, "name", "n", "", "destination name with extension. e.g: foo.jpg")
 	cmdDL.Flags().StringVarP(&path, "path", "p", "", "destination directory where the file will be downloaded")
 	cmdDL.Flags().IntVarP(&concurrent, "concurrent", "c", 0, "number of concurrent process will be running, default: 5")
 	cmdDL.Flags().BoolVarP(&debug, "debug", "d", false, "debug print the essential logs")
+	cmdDL.Flags().Float64Var(&seedRatio, "seed-ratio", 0, "keep seeding torrents until the upload ratio is reached. e.g: 1.5")
+	cmdDL.Flags().BoolVarP(&recursive, "recursive", "r", false, "recursively download the files of a HTTP directory listing")
+	cmdDL.Flags().StringSliceVar(&accept, "accept", nil, "comma separated file name patterns to download in recursive mode. e.g: *.iso,*.sha256")
+	cmdDL.Flags().StringSliceVar(&reject, "reject", nil, "comma separated file name patterns to skip in recursive mode. e.g: *.log")
+	cmdDL.Flags().IntVar(&maxDepth, "max-depth", 5, "maximum sub-directory depth in recursive mode, 0 means unlimited")
+	cmdDL.Flags().StringVar(&progress, "progress", "", "progress style: bar, multi (a bar per chunk), dots, line or none; default: bar on terminals, line otherwise")
+	cmdDL.Flags().BoolVar(&jsonOutput, "json", false, "print newline delimited JSON records instead of the progress: start, progress and final")
+	cmdDL.Flags().BoolVar(&noCache, "no-cache", false, "neither serve the file from the download cache nor add it")
+	cmdDL.Flags().StringVar(&extractDir, "extract", "", "extract the downloaded .tar(.gz|.bz2|.xz|.zst) or .zip archive, next to it or to the directory. e.g: --extract=/opt/tools")
+	cmdDL.Flags().Lookup("extract").NoOptDefVal = extractNextToArchive
+	cmdDL.Flags().IntVar(&stripComps, "strip-components", 0, "remove the number of leading path components while extracting e.g: the top directory")
+	cmdDL.Flags().BoolVar(&rmArchive, "delete-archive", false, "delete the archive once it's extracted")
+	cmdDL.Flags().BoolVar(&decompress, "decompress", false, "replace a downloaded .gz, .bz2, .xz or .zst file by its decompressed content. e.g: foo.pdf.gz to foo.pdf")
+	cmdDL.Flags().BoolVar(&noPrealloc, "no-prealloc", false, "don't reserve the size of the file before downloading, the file is sparse until completed")
+	cmdDL.Flags().StringVar(&bufferSize, "buffer-size", "", "write buffer of a connection, larger buffers mean fewer writes e.g: on network file systems. default: 256KB")
+	cmdDL.Flags().BoolVar(&fsync, "fsync", false, "flush the file to the disk before reporting the download as complete")
+	cmdDL.Flags().StringVarP(&outputPath, "output", "o", "", "path of the downloaded file; - writes it to stdout in order while still downloading the chunks concurrently, the progress goes to stderr. e.g: -o - | tar x")
+	cmdDL.Flags().StringVar(&variant, "variant", "", "HLS/DASH variant to download: highest, lowest, resolution or bandwidth. e.g: 1280x720, 720p; audio or audio:<variant> for the separate DASH audio")
 }
 
 func initConfig() {
@@ -79,10 +123,10 @@ func initConfig() {
 func startDownload(cmd *cobra.Command, args []string) {
 	cfg := config.DefaultConfig()
 
-	if cfg.AutoUpdate {
+	if cfg.AutoUpdate && !out.quiet && !out.json && outputPath != stdoutPath { // the update notes can't be silenced
 		err := update.SelfUpdate(context.Background(), BuildDate, Version)
 		if err != nil {
-			fmt.Println("Error: failed to update dl:", err) //this error can be skipped
+			out.Errorf("Error: failed to update dl: %v\n", err) //this error can be skipped
 		}
 	}
 
@@ -91,25 +135,145 @@ func startDownload(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	if _, err := netUrl.ParseRequestURI(url); err != nil {
-		fmt.Println("Error: invalid URL:", err)
+	u, err := resolveURL(url)
+	if err != nil {
+		out.Fail(url, err)
+		return
+	}
+	url = u
+
+	if progress, err = progressMode(progress); err != nil {
+		out.Fail(url, err)
+		return
+	}
+
+	if outputPath == stdoutPath && (recursive || extractDir != "" || decompress) {
+		out.Fail(url, errors.New("--recursive, --extract and --decompress can't write to stdout"))
+		os.Exit(1)
+	}
+
+	if recursive {
+		downloadRecursive(cfg, url)
+		return
+	}
+
+	dm := newDownloadManager(cfg)
+
+	if name != "" {
+		dm.ApplyOption(downloader.WithFilename(name))
+	}
+
+	switch outputPath {
+	case "":
+	case stdoutPath:
+		dm.ApplyOption(downloader.WithOutput(os.Stdout))
+	default:
+		p, err := filepath.Abs(outputPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		dm.ApplyOption(downloader.WithFilePath(filepath.Dir(p)))
+		dm.ApplyOption(downloader.WithSkipSubPathMap())
+		dm.ApplyOption(downloader.WithFilename(filepath.Base(p)))
+	}
+
+	download(dm, url)
+}
+
+// progressMode return the progress style of the flag; --json and --quiet take over
+func progressMode(mode string) (string, error) {
+	mode, err := resolveProgressMode(mode)
+	switch {
+	case err != nil:
+		return "", err
+	case out.json:
+		return progressJSON, nil
+	case out.quiet:
+		return progressNone, nil
+	}
+	return mode, nil
+}
+
+// download the url in the foreground, record it in the history and notify the user; exit on failure
+func download(dm *downloader.DownloadManager, url string) {
+	recordHistory(dm, url)
+	res, err := runDownload(context.Background(), dm, url)
+	if err != nil {
+		runHooks(dm, url, nil, err)
+		out.Errorf("Download failed: %v\n", err)
+		if debug {
+			for _, e := range dm.Errors() {
+				log.Println("Error:", e)
+			}
+		}
+		os.Exit(1)
 		return
 	}
 
+	if extractDir != "" {
+		if err := extractArchive(res.Path); err != nil {
+			runHooks(dm, url, nil, err)
+			out.Errorf("Extraction failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	runHooks(dm, url, res, nil)
+
+	n := notifier.New("DL [Terminal Downloader]")
+	n.Notify("Download complete!", fmt.Sprintf("File: %s (%s)", dm.GetFileName(), downloader.HumanReadableBytes(res.Size)))
+}
+
+// resolveURL validate the url; local .torrent files are accepted as path
+func resolveURL(url string) (string, error) {
+	url = strings.TrimSpace(url)
+	if _, err := os.Stat(url); err == nil && strings.HasSuffix(strings.ToLower(url), ".torrent") {
+		abs, err := filepath.Abs(url)
+		if err != nil {
+			return "", err
+		}
+		u := netUrl.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+		if !strings.HasPrefix(u.Path, "/") {
+			u.Path = "/" + u.Path
+		}
+		url = u.String()
+	}
+
+	if _, err := netUrl.ParseRequestURI(url); err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+	return url, nil
+}
+
+// newDownloadManager return a download manager configured from the config and the flags
+func newDownloadManager(cfg config.Config) *downloader.DownloadManager {
 	dm := downloader.New()
 
 	if debug {
 		dm.ApplyOption(downloader.WithVerbose())
-		dm.ApplyOption(downloader.WithLogger(logger.New(true)))
 	}
+	dm.ApplyOption(downloader.WithLogger(newLogger(debug)))
 
-	con := cfg.Concurrency
-	if concurrent != 0 { // assuming default is 5
-		con = uint(concurrent)
-	}
-	dm.ApplyOption(downloader.WithConcurrency(uint(con)))
+	dm.ApplyOption(downloader.WithConcurrency(concurrency(cfg)))
 
 	dm.ApplyOption(downloader.WithSubPathMap(cfg.SubDirMap))
+	dm.ApplyOption(downloader.WithMimePathMap(cfg.MimeDirMap))
+
+	if len(cfg.Rules) > 0 {
+		rules := make([]downloader.Rule, 0, len(cfg.Rules))
+		for _, r := range cfg.Rules {
+			rules = append(rules, downloader.Rule(r))
+		}
+		if err := dm.ApplyOption(downloader.WithRules(rules)); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	dm.ApplyOption(downloader.WithS3Options(downloader.S3Options{
+		Endpoint:  cfg.S3.Endpoint,
+		Region:    cfg.S3.Region,
+		Profile:   cfg.S3.Profile,
+		PathStyle: cfg.S3.PathStyle,
+	}))
 
 	if cfg.Directory != "" {
 		dm.ApplyOption(downloader.WithFilePath(cfg.Directory))
@@ -129,22 +293,47 @@ func startDownload(cmd *cobra.Command, args []string) {
 		dm.ApplyOption(downloader.WithSkipSubPathMap())
 	}
 
-	if name != "" {
-		dm.ApplyOption(downloader.WithFilename(name))
+	if seedRatio > 0 {
+		dm.ApplyOption(downloader.WithSeedRatio(seedRatio))
 	}
 
-	errs := dm.Download(url).Errors()
-	if len(errs) > 0 {
-		fmt.Printf("\nDownload failed\n")
-		if debug {
-			for _, e := range errs {
-				log.Println("Error:", e)
-			}
+	if variant != "" {
+		dm.ApplyOption(downloader.WithVariant(variant))
+	}
+
+	if cfg.Cache.Enabled && !noCache {
+		dm.ApplyOption(downloader.WithCache(newCache(cfg)))
+	}
+
+	if decompress {
+		dm.ApplyOption(downloader.WithDecompress())
+	}
+
+	if noPrealloc {
+		dm.ApplyOption(downloader.WithNoPrealloc())
+	}
+
+	if bufferSize != "" {
+		size, err := parseBytes(bufferSize)
+		if err == nil {
+			err = dm.ApplyOption(downloader.WithBufferSize(int(size)))
+		}
+		if err != nil {
+			log.Fatalln(err)
 		}
-		os.Exit(1)
-		return
 	}
 
-	n := notifier.New("DL [Terminal Downloader]")
-	n.Notify("Download complete!", fmt.Sprintf("File: %s (%s)", dm.GetFileName(), dm.GetFileSize()))
+	if fsync {
+		dm.ApplyOption(downloader.WithFsync())
+	}
+
+	return dm
+}
+
+// concurrency return the concurrency of the flag or the config
+func concurrency(cfg config.Config) uint {
+	if concurrent != 0 { // assuming default is 5
+		return uint(concurrent)
+	}
+	return cfg.Concurrency
 }
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/thedevsaddam/dl/extract"
+)
+
+// extractNextToArchive is the value of a bare --extract; the archive is extracted in its own directory
+const extractNextToArchive = "(archive dir)"
+
+// extractArchive extract the downloaded archive as the flags ask
+func extractArchive(archive string) error {
+	dir := extractDir
+	if dir == extractNextToArchive {
+		dir = filepath.Dir(archive)
+	}
+	n, err := extract.Extract(archive, dir, extract.Options{StripComponents: stripComps})
+	if err != nil {
+		return err
+	}
+	abs, _ := filepath.Abs(dir)
+	out.Infof("Extracted %d files to %s\n", n, abs)
+
+	if rmArchive {
+		return os.Remove(archive)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setExtractFlags set the extract flags for the test
+func setExtractFlags(t *testing.T, dir string, strip int, rm bool) {
+	t.Helper()
+	prevDir, prevStrip, prevRm, prevOut := extractDir, stripComps, rmArchive, out.stdout
+	extractDir, stripComps, rmArchive, out.stdout = dir, strip, rm, ioutil.Discard
+	t.Cleanup(func() { extractDir, stripComps, rmArchive, out.stdout = prevDir, prevStrip, prevRm, prevOut })
+}
+
+func writeArchive(t *testing.T, fn string, files map[string]string) {
+	t.Helper()
+	buf := bytes.Buffer{}
+	tw := tar.NewWriter(&buf)
+	for name, body := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(body))})
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	if err := ioutil.WriteFile(fn, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "project.tar")
+	writeArchive(t, archive, map[string]string{"project-1.0/README": "readme"})
+
+	// a bare --extract writes next to the archive, --delete-archive removes it once extracted
+	setExtractFlags(t, extractNextToArchive, 1, true)
+	if err := extractArchive(archive); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "README")); err != nil || string(b) != "readme" {
+		t.Fatalf("extracted %q, %v", b, err)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatalf("archive kept: %v", err)
+	}
+
+	// the archive is kept without --delete-archive and when the extraction fails
+	to := filepath.Join(t.TempDir(), "to")
+	setExtractFlags(t, to, 0, false)
+	writeArchive(t, archive, map[string]string{"project-1.0/README": "readme"})
+	if err := extractArchive(archive); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "project-1.0", "README")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(archive); err != nil {
+		t.Fatalf("archive removed: %v", err)
+	}
+
+	setExtractFlags(t, to, 0, true)
+	writeArchive(t, archive, map[string]string{"../evil": "evil"})
+	if err := extractArchive(archive); err == nil {
+		t.Fatal("expected an error for an unsafe archive")
+	}
+	if _, err := os.Stat(archive); err != nil {
+		t.Fatalf("archive removed after a failure: %v", err)
+	}
+}
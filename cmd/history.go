@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/downloader"
+	"github.com/thedevsaddam/dl/history"
+)
+
+var (
+	historySince  string
+	historyUntil  string
+	historyHost   string
+	historyStatus string
+	historySearch string
+	historyLimit  int
+
+	cmdHistory = &cobra.Command{
+		Use:   "history",
+		Short: "List the completed and failed downloads",
+		Long:  `List the completed and failed downloads recorded in ~/.dl/history.jsonl, oldest first`,
+		Args:  cobra.NoArgs,
+		Run:   listHistory,
+	}
+
+	cmdHistoryShow = &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show the details of a download of the history",
+		Long:  `Show the details of a download of the history`,
+		Args:  cobra.ExactArgs(1),
+		Run:   showHistory,
+	}
+
+	cmdHistoryRedo = &cobra.Command{
+		Use:   "redo <id>",
+		Short: "Download a url of the history again",
+		Long:  `Download a url of the history again to the same location`,
+		Args:  cobra.ExactArgs(1),
+		Run:   redoHistory,
+	}
+)
+
+func init() {
+	cmdHistory.Flags().StringVar(&historySince, "since", "", "downloads finished since the date or duration ago. e.g: 2021-06-01, 2021-06-01T10:00:00Z, 24h")
+	cmdHistory.Flags().StringVar(&historyUntil, "until", "", "downloads finished until the date (inclusive) or duration ago. e.g: 2021-06-30, 2h")
+	cmdHistory.Flags().StringVar(&historyHost, "host", "", "downloads of the host or its sub-domains. e.g: example.com")
+	cmdHistory.Flags().StringVar(&historyStatus, "status", "", "downloads of the status: completed or failed")
+	cmdHistory.Flags().StringVarP(&historySearch, "search", "s", "", "search the text in the url, location, digest and error")
+	cmdHistory.Flags().IntVarP(&historyLimit, "limit", "n", 0, "the most recent downloads only, 0 means all")
+	cmdHistoryRedo.Flags().IntVarP(&concurrent, "concurrent", "c", 0, "number of concurrent process will be running, default: 5")
+	cmdHistoryRedo.Flags().BoolVarP(&debug, "debug", "d", false, "debug print the essential logs")
+
+	cmdHistory.AddCommand(cmdHistoryShow, cmdHistoryRedo)
+	cmdDL.AddCommand(cmdHistory)
+}
+
+// historyStore return the history of the state directory
+func historyStore() *history.Store {
+	return history.New(stateDir())
+}
+
+// recordHistory add the outcome of the download to the history
+func recordHistory(dm *downloader.DownloadManager, url string) {
+	dm.ApplyOption(downloader.WithProgressHandler(historyStore().Recorder(url, newLogger(true))))
+}
+
+func listHistory(cmd *cobra.Command, args []string) {
+	f := history.Filter{
+		Host:   historyHost,
+		Status: history.Status(historyStatus),
+		Text:   historySearch,
+		Limit:  historyLimit,
+	}
+	var err error
+	if f.Since, err = parseHistoryTime(historySince, false); err != nil {
+		exitWithError(err)
+	}
+	if f.Until, err = parseHistoryTime(historyUntil, true); err != nil {
+		exitWithError(err)
+	}
+	if f.Status != "" && f.Status != history.StatusCompleted && f.Status != history.StatusFailed {
+		exitWithError(fmt.Errorf("invalid status %q, must be completed or failed", historyStatus))
+	}
+
+	entries, err := historyStore().List(f)
+	if err != nil {
+		exitWithError(err)
+	}
+	if len(entries) == 0 {
+		out.Infof("No downloads found\n")
+		return
+	}
+
+	w := tabwriter.NewWriter(out.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFINISHED\tSTATUS\tSIZE\tNAME")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", e.ID, e.FinishedAt.Local().Format("2006-01-02 15:04"), e.Status, entrySize(e), entryName(e))
+	}
+	w.Flush()
+}
+
+func showHistory(cmd *cobra.Command, args []string) {
+	e := historyEntry(args[0])
+
+	w := tabwriter.NewWriter(out.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", e.ID)
+	fmt.Fprintf(w, "URL:\t%s\n", e.URL)
+	fmt.Fprintf(w, "Status:\t%s\n", e.Status)
+	if e.Location != "" {
+		fmt.Fprintf(w, "Location:\t%s\n", e.Location)
+	}
+	fmt.Fprintf(w, "Size:\t%s\n", entrySize(e))
+	if e.Digest != "" {
+		fmt.Fprintf(w, "SHA-256:\t%s\n", e.Digest)
+	}
+	fmt.Fprintf(w, "Started:\t%s\n", e.StartedAt.Local().Format(time.RFC3339))
+	fmt.Fprintf(w, "Finished:\t%s\n", e.FinishedAt.Local().Format(time.RFC3339))
+	fmt.Fprintf(w, "Duration:\t%s\n", e.Duration.Round(time.Millisecond))
+	if e.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", e.Error)
+	}
+	w.Flush()
+}
+
+func redoHistory(cmd *cobra.Command, args []string) {
+	e := historyEntry(args[0])
+
+	var err error
+	if progress, err = progressMode(""); err != nil {
+		exitWithError(err)
+	}
+
+	download(redoManager(e, config.DefaultConfig()), e.URL)
+}
+
+// redoManager return the download manager of the config writing the url of the entry to the same location
+func redoManager(e history.Entry, cfg config.Config) *downloader.DownloadManager {
+	dm := newDownloadManager(cfg)
+	if e.Location != "" {
+		dm.ApplyOption(downloader.WithFilePath(filepath.Dir(e.Location)))
+		dm.ApplyOption(downloader.WithSkipSubPathMap())
+		dm.ApplyOption(downloader.WithFilename(filepath.Base(e.Location)))
+	}
+	return dm
+}
+
+// historyEntry return the entry of the id argument; exit if there is none
+func historyEntry(arg string) history.Entry {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		exitWithError(fmt.Errorf("invalid history id: %s", arg))
+	}
+	e, err := historyStore().Get(id)
+	if err != nil {
+		exitWithError(err)
+	}
+	return e
+}
+
+// parseHistoryTime parse a date, a RFC3339 time or a duration ago; the end of the day is taken for a date if inclusive
+func parseHistoryTime(s string, inclusive bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, must be a date, a RFC3339 time or a duration", s)
+	}
+	if inclusive {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// entrySize return the size of the entry; unknown for a download failed before the meta information
+func entrySize(e history.Entry) string {
+	if e.Size == 0 && e.Status == history.StatusFailed {
+		return "-"
+	}
+	return downloader.HumanReadableBytes(e.Size)
+}
+
+// entryName return the location of the entry; the url with the error if it has none
+func entryName(e history.Entry) string {
+	switch {
+	case e.Location != "":
+		return e.Location
+	case e.Error != "":
+		return e.URL + " (" + e.Error + ")"
+	}
+	return e.URL
+}
+
+func exitWithError(err error) {
+	out.Errorf("Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/history"
+	"github.com/thedevsaddam/dl/values"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	june := time.Date(2021, 6, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		s         string
+		inclusive bool
+		want      time.Time
+		err       bool
+	}{
+		{s: "", want: time.Time{}},
+		{s: "2021-06-01", want: june},
+		{s: " 2021-06-01 ", inclusive: true, want: june.AddDate(0, 0, 1)}, // the whole day until
+		{s: "2021-06-01T10:00:00Z", want: time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{s: "2021-06-01T10:00:00+06:00", inclusive: true, want: time.Date(2021, 6, 1, 4, 0, 0, 0, time.UTC)},
+		{s: "yesterday", err: true},
+		{s: "2021-13-01", err: true},
+		{s: "2021/06/01", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHistoryTime(tt.s, tt.inclusive)
+		if (err != nil) != tt.err || !got.Equal(tt.want) {
+			t.Errorf("parseHistoryTime(%q, %v) = %s, %v, want %s, error %v", tt.s, tt.inclusive, got, err, tt.want, tt.err)
+		}
+	}
+
+	got, err := parseHistoryTime("24h", false)
+	if want := time.Now().Add(-24 * time.Hour); err != nil || got.Before(want.Add(-time.Minute)) || got.After(want) {
+		t.Errorf("parseHistoryTime(24h) = %s, %v, want about %s", got, err, want)
+	}
+}
+
+func TestRedoManager(t *testing.T) {
+	content := bytes.Repeat([]byte("redo"), 100<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	// the sub directories and the rules of the config don't move the file of the entry
+	cfg := config.Config{
+		Directory:  t.TempDir(),
+		SubDirMap:  values.MapStrSliceString{"binary": {".bin"}},
+		MimeDirMap: values.MapStrSliceString{"video": {"video/*"}},
+		Rules:      []config.Rule{{MIME: "video/*", Destination: "movies/"}},
+	}
+	location := filepath.Join(t.TempDir(), "sub", "renamed.bin")
+	res, err := redoManager(history.Entry{URL: srv.URL + "/clip.bin", Location: location}, cfg).DownloadContext(context.Background(), srv.URL+"/clip.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path != location {
+		t.Fatalf("downloaded to %s, want %s", res.Path, location)
+	}
+	if b, err := ioutil.ReadFile(location); err != nil || !bytes.Equal(b, content) {
+		t.Fatalf("redone file: %v", err)
+	}
+
+	// an entry failed before the download started goes where a new download would
+	res, err = redoManager(history.Entry{URL: srv.URL + "/clip.bin"}, cfg).DownloadContext(context.Background(), srv.URL+"/clip.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(cfg.Directory, "movies", "clip.bin"); res.Path != want {
+		t.Fatalf("downloaded to %s, want %s", res.Path, want)
+	}
+}
+
+func TestEntryNameSize(t *testing.T) {
+	tests := []struct {
+		e          history.Entry
+		name, size string
+	}{
+		{e: history.Entry{URL: "https://example.com/a", Location: "/data/a", Size: 2048, Status: history.StatusCompleted}, name: "/data/a", size: "2.0 kB"},
+		{e: history.Entry{URL: "https://example.com/a", Error: "404 Not Found", Status: history.StatusFailed}, name: "https://example.com/a (404 Not Found)", size: "-"},
+		{e: history.Entry{URL: "https://example.com/a", Status: history.StatusCompleted}, name: "https://example.com/a", size: " 0 B"},
+	}
+	for _, tt := range tests {
+		if got := entryName(tt.e); got != tt.name {
+			t.Errorf("entryName(%+v) = %s, want %s", tt.e, got, tt.name)
+		}
+		if got := entrySize(tt.e); got != tt.size {
+			t.Errorf("entrySize(%+v) = %s, want %s", tt.e, got, tt.size)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/downloader"
+	"github.com/thedevsaddam/dl/hook"
+)
+
+// runHooks run the hooks of the config for the download of the url, once it and the extraction returned:
+// the completion hooks for the result, the failure hooks for the error; cancelled downloads don't run any
+func runHooks(dm *downloader.DownloadManager, url string, res *downloader.Result, err error) {
+	cfg := config.DefaultConfig()
+	if len(cfg.Hooks.Categories) == 0 && len(cfg.Hooks.Extensions) == 0 {
+		return
+	}
+	if err != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+	r := hook.Runner{
+		Timeout: time.Duration(cfg.Hooks.Timeout) * time.Second,
+		Log:     newLogger(!out.quiet),
+	}
+
+	var v hook.Vars
+	if err == nil {
+		v = hook.Vars{
+			Path:   res.Path,
+			Name:   filepath.Base(res.Path),
+			Size:   res.Size,
+			URL:    url,
+			Digest: res.Digest,
+		}
+		if v.Path == "" { // written to stdout
+			v.Name = dm.GetFileName()
+		}
+	} else {
+		st := dm.Stats()
+		v = hook.Vars{
+			Path:   st.Location,
+			Name:   st.FileName,
+			Size:   st.Downloaded,
+			URL:    url,
+			Errors: joinErrors(err, dm.Errors()),
+		}
+	}
+
+	var cmds []string
+	for _, h := range matchHooks(cfg, v.Name, dm.Category(v.Name)) {
+		if err == nil {
+			cmds = append(cmds, h.OnComplete...)
+		} else {
+			cmds = append(cmds, h.OnFailure...)
+		}
+	}
+	r.Run(context.Background(), cmds, v)
+}
+
+// matchHooks return the hooks of every download, of the category the file is routed to and of its extension
+func matchHooks(cfg config.Config, name, category string) []config.Hooks {
+	hooks := []config.Hooks{cfg.Hooks.Categories["*"]}
+	if h, ok := cfg.Hooks.Categories[category]; ok {
+		hooks = append(hooks, h)
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for e, h := range cfg.Hooks.Extensions {
+		if "."+strings.TrimPrefix(strings.ToLower(strings.TrimSpace(e)), ".") == ext {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+// joinErrors return the distinct messages of the error stopping the download and of the error bag
+func joinErrors(err error, errs []error) string {
+	var msgs []string
+	seen := make(map[string]bool)
+	for _, e := range append([]error{err}, errs...) {
+		if e == nil || seen[e.Error()] {
+			continue
+		}
+		seen[e.Error()] = true
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/downloader"
+	"github.com/thedevsaddam/dl/values"
+)
+
+func TestMatchHooks(t *testing.T) {
+	hooks := func(name string) config.Hooks { return config.Hooks{OnComplete: []string{name}} }
+	cfg := config.Config{Hooks: config.HooksConfig{
+		Categories: map[string]config.Hooks{"*": hooks("every"), "binary": hooks("binary"), "video": hooks("video")},
+		Extensions: map[string]config.Hooks{".SH": hooks("sh"), " run ": hooks("run"), "zip": hooks("zip")},
+	}}
+	dm := downloader.New(
+		downloader.WithSubPathMap(values.MapStrSliceString{"binary": {".sh", ".run", ".exe"}, "video": {".mp4"}}),
+	)
+
+	tests := map[string]string{
+		"install.sh":  "every,binary,sh",
+		"install.SH":  "every,binary,sh",
+		"setup.run":   "every,binary,run",
+		"movie.mp4":   "every,video",
+		"archive.zip": "every,zip",
+		"notes.txt":   "every",
+		"sh":          "every",
+	}
+	for name, want := range tests {
+		var got []string
+		for _, h := range matchHooks(cfg, name, dm.Category(name)) {
+			got = append(got, h.OnComplete...)
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("%s: hooks %v, want %s", name, got, want)
+		}
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	err := errors.New("chunk 2 failed")
+	got := joinErrors(err, []error{errors.New("timeout"), err, nil, errors.New("timeout")})
+	if got != "chunk 2 failed; timeout" {
+		t.Fatalf("joinErrors = %q", got)
+	}
+}
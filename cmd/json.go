@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+// progressJSON is the progress mode of --json; it can't be chosen through --progress
+const progressJSON = "json"
+
+// jsonProgressInterval is how often the --json output emits a progress record
+const jsonProgressInterval = time.Second
+
+// jsonStart is the first record of a download, emitted once the meta information is fetched
+type jsonStart struct {
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	URL         string    `json:"url"`
+	Name        string    `json:"name"`
+	Size        uint64    `json:"size"` // 0 if unknown
+	Location    string    `json:"location"`
+	Concurrency uint      `json:"concurrency"`
+	Chunks      int       `json:"chunks"`
+}
+
+// jsonProgress is emitted periodically while downloading
+type jsonProgress struct {
+	Type            string    `json:"type"`
+	Time            time.Time `json:"time"`
+	Downloaded      uint64    `json:"downloaded"`
+	Size            uint64    `json:"size"`
+	Speed           uint64    `json:"speed"` // bytes per second
+	Chunks          int       `json:"chunks"`
+	ChunksCompleted int       `json:"chunks_completed"`
+}
+
+// jsonFinal is the last record of a download
+type jsonFinal struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	URL      string    `json:"url"`
+	Status   string    `json:"status"` // completed, failed or cancelled
+	Name     string    `json:"name,omitempty"`
+	Location string    `json:"location,omitempty"`
+	Bytes    uint64    `json:"bytes"`
+	Duration float64   `json:"duration"` // seconds
+	Digest   string    `json:"digest,omitempty"`
+	Errors   []string  `json:"errors"`
+}
+
+// jsonRenderer writes the progress as newline delimited JSON records to stdout
+type jsonRenderer struct {
+	url       string
+	enc       *json.Encoder
+	startedAt time.Time
+	printedAt time.Time
+	last      uint64
+}
+
+func newJSONRenderer(url string) *jsonRenderer {
+	return &jsonRenderer{url: url, enc: json.NewEncoder(out.stdout), startedAt: time.Now()}
+}
+
+func (r *jsonRenderer) handle(e downloader.Event) {
+	st := e.Stats
+	switch e.Type {
+	case downloader.MetaFetched:
+		r.printedAt = time.Now()
+		r.last = st.Downloaded
+		r.enc.Encode(jsonStart{
+			Type:        "start",
+			Time:        r.printedAt,
+			URL:         r.url,
+			Name:        st.FileName,
+			Size:        st.Size,
+			Location:    st.Location,
+			Concurrency: concurrency(config.DefaultConfig()),
+			Chunks:      st.Chunks,
+		})
+	case downloader.ChunkProgress:
+		if time.Since(r.printedAt) < jsonProgressInterval {
+			return
+		}
+		rate := speed(st.Downloaded, r.last, time.Since(r.printedAt).Seconds())
+		r.printedAt = time.Now()
+		r.last = st.Downloaded
+		r.enc.Encode(jsonProgress{
+			Type:            "progress",
+			Time:            r.printedAt,
+			Downloaded:      st.Downloaded,
+			Size:            st.Size,
+			Speed:           rate,
+			Chunks:          st.Chunks,
+			ChunksCompleted: st.ChunksCompleted,
+		})
+	}
+}
+
+func (r *jsonRenderer) stop() {}
+
+// final write the last record with the outcome of the download
+func (r *jsonRenderer) final(dm *downloader.DownloadManager, res *downloader.Result, cancelled bool) {
+	st := dm.Stats()
+	rec := jsonFinal{
+		Type:     "final",
+		Time:     time.Now(),
+		URL:      r.url,
+		Status:   "completed",
+		Name:     st.FileName,
+		Location: st.Location,
+		Bytes:    st.Downloaded,
+		Duration: time.Since(r.startedAt).Seconds(),
+		Errors:   []string{},
+	}
+	if res != nil {
+		rec.Location = res.Path
+		rec.Bytes = res.Size
+		rec.Duration = res.Duration.Seconds()
+		rec.Digest = res.Digest
+	}
+	for _, err := range dm.Errors() {
+		rec.Errors = append(rec.Errors, err.Error())
+	}
+	switch {
+	case cancelled:
+		rec.Status = "cancelled"
+	case res == nil:
+		rec.Status = "failed"
+	}
+	r.enc.Encode(rec)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/thedevsaddam/dl/logger"
+)
+
+var (
+	logFile   string
+	logLevel  string
+	logFormat string
+
+	logOptions logger.Options
+	logWriter  io.Writer // the --log-file; nil if not set
+)
+
+// initLogger validate the log flags and open the --log-file
+func initLogger() {
+	level, err := logger.ParseLevel(logLevel)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if debug {
+		level = logger.LevelDebug
+	}
+	format, err := logger.ParseFormat(logFormat)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	logOptions = logger.Options{Level: level, Format: format}
+
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		logWriter = f
+	}
+}
+
+// newLogger return the logger writing to the --log-file; console writes to stderr as well
+func newLogger(console bool) logger.LevelLogger {
+	var ws []io.Writer
+	if console {
+		ws = append(ws, os.Stderr)
+	}
+	if logWriter != nil {
+		ws = append(ws, logWriter)
+	}
+	if len(ws) == 0 {
+		return logger.New(false)
+	}
+	return logger.NewWriter(io.MultiWriter(ws...), logOptions)
+}
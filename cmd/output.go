@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// output prints every user facing message so that --quiet, --json and --no-color apply everywhere;
+// the requested data e.g: the job list, go to stdout, the errors to stderr
+type output struct {
+	stdout io.Writer
+	stderr io.Writer
+	quiet  bool // only the errors
+	json   bool // stdout carries the JSON records only
+	color  bool
+}
+
+var out = &output{stdout: os.Stdout, stderr: os.Stderr, color: true}
+
+var (
+	quiet   bool
+	noColor bool
+)
+
+// initOutput apply the output flags; NO_COLOR is honoured as described at https://no-color.org
+func initOutput() {
+	out.quiet = quiet
+	out.json = jsonOutput
+	if outputPath == stdoutPath { // stdout carries the downloaded file
+		out.stdout = os.Stderr
+	}
+	out.color = !noColor && os.Getenv("NO_COLOR") == "" && out.terminal()
+}
+
+// terminal report whether the messages are printed to a terminal
+func (o *output) terminal() bool {
+	f, ok := o.stdout.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// isTerminal report whether the file is a character device e.g: not a pipe or a regular file
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Infof print an informative message; hidden by --quiet and --json
+func (o *output) Infof(format string, a ...interface{}) {
+	if o.quiet || o.json {
+		return
+	}
+	fmt.Fprintf(o.stdout, format, a...)
+}
+
+// Printf print the data the command was asked for
+func (o *output) Printf(format string, a ...interface{}) {
+	fmt.Fprintf(o.stdout, format, a...)
+}
+
+// Errorf print an error message to stderr
+func (o *output) Errorf(format string, a ...interface{}) {
+	fmt.Fprintf(o.stderr, format, a...)
+}
+
+// Fail print the error of the url; the --json output gets a failed final record
+func (o *output) Fail(url string, err error) {
+	if o.json {
+		json.NewEncoder(o.stdout).Encode(jsonFinal{
+			Type:   "final",
+			Time:   time.Now(),
+			URL:    url,
+			Status: "failed",
+			Errors: []string{err.Error()},
+		})
+		return
+	}
+	o.Errorf("Error: %v\n", err)
+}
+
+// colorize wrap the text with the color tag of the progressbar e.g: [cyan]text[reset]
+func (o *output) colorize(color, text string) string {
+	if !o.color {
+		return text
+	}
+	return "[" + color + "]" + text + "[reset]"
+}
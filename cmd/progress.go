@@ -0,0 +1,423 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/schollz/progressbar/v3"
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+// progress modes of the --progress flag
+const (
+	progressBar   = "bar"
+	progressMulti = "multi"
+	progressDots  = "dots"
+	progressLine  = "line"
+	progressNone  = "none"
+)
+
+// repaintInterval limits how often the multi renderer paints
+const repaintInterval = 200 * time.Millisecond
+
+// renderer paints the progress events of a download
+type renderer interface {
+	handle(e downloader.Event)
+	stop() // called once the download returns
+}
+
+// resolveProgressMode validate the --progress flag; terminals get the progressbar, logs get plain lines
+func resolveProgressMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		if out.terminal() {
+			return progressBar, nil
+		}
+		return progressLine, nil
+	case progressBar, progressMulti, progressDots, progressLine, progressNone:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid progress %q, must be one of bar, multi, dots, line or none", mode)
+}
+
+func newRenderer(mode string, dm *downloader.DownloadManager, url string) renderer {
+	switch mode {
+	case progressMulti:
+		return newMultiRenderer(dm, url)
+	case progressDots:
+		return &dotsRenderer{}
+	case progressLine:
+		return &lineRenderer{}
+	case progressNone:
+		return noneRenderer{}
+	case progressJSON:
+		return newJSONRenderer(url)
+	}
+	return newBarRenderer(url)
+}
+
+// runDownload download the url while rendering the progress; Ctrl+C cancels the download and exits
+func runDownload(ctx context.Context, dm *downloader.DownloadManager, url string) (*downloader.Result, error) {
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	defer stop()
+
+	r := newRenderer(progress, dm, url)
+	dm.ApplyOption(downloader.WithProgressHandler(r.handle))
+	res, err := dm.DownloadContext(sigCtx, url)
+	r.stop()
+
+	cancelled := sigCtx.Err() != nil && ctx.Err() == nil
+	if jr, ok := r.(*jsonRenderer); ok {
+		jr.final(dm, res, cancelled)
+		if cancelled {
+			os.Exit(1)
+		}
+		return res, err
+	}
+	if cancelled {
+		out.Infof("\nOperation cancelled!\n")
+		if out.terminal() {
+			io.WriteString(out.stdout, "\033[?25h") // restore the cursor hidden by the spinner
+		}
+		os.Exit(1)
+	}
+	if err == nil {
+		out.Infof("File name: %s\n", dm.GetFileName())
+		out.Infof("File size: %s\n", downloader.HumanReadableBytes(res.Size))
+		out.Infof("Time elapsed: %s\n", res.Duration)
+		if res.Path != "" { // empty if written to stdout
+			out.Infof("Location: %s\n", res.Path)
+		}
+	}
+	return res, err
+}
+
+// newMetaSpinner start the spinner shown while fetching the meta information
+func newMetaSpinner(url string) *spinner.Spinner {
+	s := spinner.New(spinner.CharSets[70], 100*time.Millisecond, spinner.WithHiddenCursor(true))
+	s.Prefix = "Fetching file's meta information ( "
+	if downloader.IsTorrentURL(url) {
+		s.Prefix = "Fetching torrent meta information ( "
+	}
+	s.Suffix = ")"
+	s.Writer = out.stdout
+	s.Start()
+	return s
+}
+
+// printSeeding tell that a completed torrent keeps seeding
+func printSeeding(e downloader.Event) {
+	if e.Type == downloader.Completed && e.Stats.State == downloader.StateSeeding {
+		fmt.Fprintf(out.stdout, "\nSeeding until ratio %.2f is reached...\n", seedRatio)
+	}
+}
+
+// barRenderer renders the spinner while fetching the meta information and a single progressbar afterwards
+type barRenderer struct {
+	spinner *spinner.Spinner
+	bar     *progressbar.ProgressBar
+}
+
+func newBarRenderer(url string) *barRenderer {
+	return &barRenderer{spinner: newMetaSpinner(url)}
+}
+
+func (r *barRenderer) handle(e downloader.Event) {
+	if e.Type == downloader.MetaFetched {
+		r.spinner.Stop()
+		fmt.Fprintln(out.stdout)
+		r.bar = newProgressBar(e.Stats)
+	}
+	if r.bar == nil {
+		return // failed while fetching the meta information
+	}
+	r.bar.Describe(out.colorize("cyan", fmt.Sprintf("[%d/%d]", e.Stats.ChunksCompleted, e.Stats.Chunks)) + " Downloading:")
+	r.bar.Set64(int64(e.Stats.Downloaded))
+	printSeeding(e)
+}
+
+func (r *barRenderer) stop() {
+	r.spinner.Stop()
+	fmt.Fprintln(out.stdout)
+}
+
+// newProgressBar create the progressbar; unknown sizes render a spinner like bar
+func newProgressBar(st downloader.Stats) *progressbar.ProgressBar {
+	max := int64(-1)
+	if st.Size > 0 {
+		max = int64(st.Size)
+	}
+	return progressbar.NewOptions64(max,
+		progressbar.OptionSetDescription(out.colorize("red", fmt.Sprintf("[0/%d]", st.Chunks))+" Downloading:"),
+		progressbar.OptionSetWriter(out.stdout),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionEnableColorCodes(out.color),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionShowCount(),
+		progressbar.OptionThrottle(500*time.Millisecond),
+	)
+}
+
+// chunkLine is the state of a single bar of the multi renderer
+type chunkLine struct {
+	size    uint64 // 0 if unknown
+	bytes   uint64
+	last    uint64 // bytes at the previous paint
+	speed   uint64 // bytes per second
+	retries int
+	done    bool
+}
+
+// multiRenderer renders one bar per chunk with its own speed so that a stuck chunk stands out;
+// streams and torrents only have the total bar as their segments and pieces aren't ranges of the file
+type multiRenderer struct {
+	dm      *downloader.DownloadManager
+	spinner *spinner.Spinner
+
+	chunks    []chunkLine
+	total     chunkLine
+	lines     int // painted lines to move over on the next paint
+	paintedAt time.Time
+}
+
+func newMultiRenderer(dm *downloader.DownloadManager, url string) *multiRenderer {
+	return &multiRenderer{dm: dm, spinner: newMetaSpinner(url)}
+}
+
+func (r *multiRenderer) handle(e downloader.Event) {
+	switch e.Type {
+	case downloader.MetaFetched:
+		r.spinner.Stop()
+		fmt.Fprintln(out.stdout)
+		for _, c := range r.dm.Chunks() {
+			// [Start, End); the single chunk of a file of unknown size ends once done
+			size := c.End - c.Start
+			if c.End == ^uint64(0) {
+				size = 0
+			}
+			r.chunks = append(r.chunks, chunkLine{size: size, bytes: c.Done, last: c.Done, done: size > 0 && c.Done >= size})
+		}
+		r.total.last = e.Stats.Downloaded
+		r.paintedAt = time.Now()
+	case downloader.ChunkStarted, downloader.ChunkProgress, downloader.ChunkRetried, downloader.ChunkDone:
+		if e.Chunk >= 0 && e.Chunk < len(r.chunks) {
+			c := &r.chunks[e.Chunk]
+			if e.Type == downloader.ChunkRetried {
+				c.retries++
+			} else {
+				c.bytes = e.Bytes
+			}
+			c.done = e.Type == downloader.ChunkDone
+			if c.done && c.size == 0 {
+				c.size = c.bytes
+			}
+		}
+	}
+	if e.Stats.State < downloader.StateDownloading {
+		return
+	}
+	r.total.size = e.Stats.Size
+	r.total.bytes = e.Stats.Downloaded
+	r.total.done = e.Type == downloader.Completed
+
+	// the last paint has to be accurate, the others are throttled
+	if e.Type != downloader.Completed && time.Since(r.paintedAt) < repaintInterval {
+		return
+	}
+	r.paint(e.Stats)
+	printSeeding(e)
+}
+
+func (r *multiRenderer) paint(st downloader.Stats) {
+	elapsed := time.Since(r.paintedAt).Seconds()
+	r.paintedAt = time.Now()
+
+	var b strings.Builder
+	if r.lines > 0 {
+		fmt.Fprintf(&b, "\033[%dA", r.lines) // back to the first bar
+	}
+	for i := range r.chunks {
+		c := &r.chunks[i]
+		c.speed = speed(c.bytes, c.last, elapsed)
+		c.last = c.bytes
+		label := fmt.Sprintf("#%d", i+1)
+		if c.retries > 0 {
+			label = fmt.Sprintf("#%d (%d retries)", i+1, c.retries)
+		}
+		fmt.Fprintf(&b, "\r\033[K%s\n", c.render(label))
+	}
+	t := &r.total
+	t.speed = speed(t.bytes, t.last, elapsed)
+	t.last = t.bytes
+	fmt.Fprintf(&b, "\r\033[K%s\n", t.render(fmt.Sprintf("[%d/%d]", st.ChunksCompleted, st.Chunks)))
+
+	r.lines = len(r.chunks) + 1
+	io.WriteString(out.stdout, b.String())
+}
+
+func (r *multiRenderer) stop() {
+	r.spinner.Stop()
+	if r.lines == 0 {
+		fmt.Fprintln(out.stdout)
+	}
+}
+
+// render format the line e.g: #1 [===============               ]  50.0% 1.0 MB/2.0 MB 512 kB/s
+func (c chunkLine) render(label string) string {
+	const width = 30
+	state := downloader.HumanReadableBytes(c.speed) + "/s"
+	if c.done {
+		state = "done"
+	}
+	if c.size == 0 {
+		return fmt.Sprintf("%-16s %s %s", label, downloader.HumanReadableBytes(c.bytes), state)
+	}
+
+	bytes := c.bytes
+	if bytes > c.size {
+		bytes = c.size
+	}
+	filled := int(bytes * width / c.size)
+	return fmt.Sprintf("%-16s [%s%s] %5.1f%% %s/%s %s", label,
+		strings.Repeat("=", filled), strings.Repeat(" ", width-filled), float64(bytes)*100/float64(c.size),
+		downloader.HumanReadableBytes(c.bytes), downloader.HumanReadableBytes(c.size), state)
+}
+
+// speed return the bytes per second since the previous paint
+func speed(bytes, last uint64, elapsed float64) uint64 {
+	if bytes < last || elapsed <= 0 {
+		return 0
+	}
+	return uint64(float64(bytes-last) / elapsed)
+}
+
+// dotsRenderer prints a dot per percent like wget, or per megabyte if the size is unknown; made for CI logs
+type dotsRenderer struct {
+	size    uint64
+	dots    uint64
+	started bool
+}
+
+func (r *dotsRenderer) handle(e downloader.Event) {
+	switch e.Type {
+	case downloader.MetaFetched:
+		r.started = true
+		r.size = e.Stats.Size
+		fmt.Fprintf(out.stdout, "Downloading %s (%s)\n", e.Stats.FileName, sizeOrUnknown(r.size))
+	case downloader.ChunkRetried:
+		if r.dots%50 != 0 {
+			fmt.Fprintln(out.stdout)
+		}
+		fmt.Fprintf(out.stdout, "chunk %d retried: %v\n", e.Chunk+1, e.Err)
+		r.dots -= r.dots % 50 // repeat the dots of the interrupted line
+		return
+	}
+	if !r.started {
+		return
+	}
+
+	want := e.Stats.Downloaded >> 20
+	if r.size > 0 {
+		want = e.Stats.Downloaded * 100 / r.size
+		if want > 100 {
+			want = 100
+		}
+	}
+	for r.dots < want {
+		r.dots++
+		fmt.Fprint(out.stdout, ".")
+		switch {
+		case r.dots%50 == 0 && r.size > 0:
+			fmt.Fprintf(out.stdout, " %3d%%\n", r.dots)
+		case r.dots%50 == 0:
+			fmt.Fprintf(out.stdout, " %s\n", downloader.HumanReadableBytes(r.dots<<20))
+		case r.dots%10 == 0:
+			fmt.Fprint(out.stdout, " ")
+		}
+	}
+	printSeeding(e)
+}
+
+func (r *dotsRenderer) stop() {
+	if r.dots%50 != 0 {
+		fmt.Fprintln(out.stdout)
+	}
+}
+
+// lineInterval is how often the line renderer prints the progress of an unknown size
+const lineInterval = 5 * time.Second
+
+// lineRenderer prints plain lines without escape codes; used when the output isn't a terminal
+type lineRenderer struct {
+	printedAt time.Time
+	last      uint64
+	step      uint64 // the last printed tenth of the size
+}
+
+func (r *lineRenderer) handle(e downloader.Event) {
+	switch e.Type {
+	case downloader.MetaFetched:
+		fmt.Fprintf(out.stdout, "Downloading %s (%s) in %d chunks\n", e.Stats.FileName, sizeOrUnknown(e.Stats.Size), e.Stats.Chunks)
+		r.printedAt = time.Now()
+		r.last = e.Stats.Downloaded
+		return
+	case downloader.ChunkRetried:
+		fmt.Fprintf(out.stdout, "chunk %d retried: %v\n", e.Chunk+1, e.Err)
+		return
+	case downloader.Completed:
+		r.print(e.Stats)
+		printSeeding(e)
+		return
+	case downloader.Failed:
+		return
+	}
+
+	st := e.Stats
+	if st.Size > 0 {
+		// every 10 percent
+		if step := st.Downloaded * 10 / st.Size; step > r.step && step < 10 {
+			r.step = step
+			r.print(st)
+		}
+		return
+	}
+	if time.Since(r.printedAt) >= lineInterval {
+		r.print(st)
+	}
+}
+
+func (r *lineRenderer) print(st downloader.Stats) {
+	rate := speed(st.Downloaded, r.last, time.Since(r.printedAt).Seconds())
+	r.printedAt = time.Now()
+	r.last = st.Downloaded
+
+	done := downloader.HumanReadableBytes(st.Downloaded)
+	if st.Size > 0 {
+		done = fmt.Sprintf("%3d%% %s/%s", st.Downloaded*100/st.Size, done, downloader.HumanReadableBytes(st.Size))
+	}
+	fmt.Fprintf(out.stdout, "[%d/%d] %s %s/s\n", st.ChunksCompleted, st.Chunks, done, downloader.HumanReadableBytes(rate))
+}
+
+func (r *lineRenderer) stop() {}
+
+// noneRenderer doesn't render anything
+type noneRenderer struct{}
+
+func (noneRenderer) handle(downloader.Event) {}
+func (noneRenderer) stop()                   {}
+
+// sizeOrUnknown return the human readable size; 0 means unknown
+func sizeOrUnknown(size uint64) string {
+	if size == 0 {
+		return "unknown size"
+	}
+	return downloader.HumanReadableBytes(size)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	netUrl "net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/thedevsaddam/dl/config"
+	"github.com/thedevsaddam/dl/downloader"
+	"github.com/thedevsaddam/dl/notifier"
+)
+
+// downloadRecursive crawl the directory listing and download the files through a shared queue by the workers of --concurrent
+func downloadRecursive(cfg config.Config, url string) {
+	entries, err := downloader.Crawl(context.Background(), nil, url, downloader.CrawlOptions{
+		Accept:   accept,
+		Reject:   reject,
+		MaxDepth: maxDepth,
+	})
+	// the files of a directory that can't be listed are missing from the tree; report them and download the rest
+	var skipped *downloader.CrawlError
+	if errors.As(err, &skipped) {
+		for i, dir := range skipped.Dirs {
+			out.Errorf("Skipped directory: %s: %v\n", dir, skipped.Errs[i])
+		}
+	} else if err != nil {
+		out.Fail(url, err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		out.Infof("No files found\n")
+		if skipped != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	dest := cfg.Directory
+	if path != "" {
+		dest = path
+	}
+	if dest == "" || dest == "." {
+		dir, err := os.Getwd()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		dest = dir
+	}
+
+	// recreate the tree inside a directory named after the crawled directory
+	if u, err := netUrl.Parse(url); err == nil {
+		if base := filepath.Base(strings.TrimSuffix(u.Path, "/")); base != "" && base != "/" && base != "." {
+			dest = filepath.Join(dest, base)
+		}
+	}
+
+	queue := make(chan downloader.Entry, len(entries))
+	for _, e := range entries {
+		queue <- e
+	}
+	close(queue)
+
+	// the workers share the connections of --concurrent; a single one renders the progress of its file
+	workers := int(concurrency(cfg))
+	if workers > len(entries) {
+		workers = len(entries)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	chunks := concurrency(cfg) / uint(workers)
+	if chunks < 1 {
+		chunks = 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	defer stop()
+	if workers == 1 {
+		ctx = context.Background() // runDownload handles the signals
+	}
+
+	var mu sync.Mutex
+	failed := 0
+	started := 0
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range queue {
+				mu.Lock()
+				started++
+				out.Infof("[%d/%d] %s\n", started, len(entries), e.Path)
+				mu.Unlock()
+
+				err := downloadEntry(ctx, cfg, dest, e, chunks, workers == 1)
+				if err != nil && ctx.Err() == nil {
+					mu.Lock()
+					failed++
+					out.Errorf("Download failed: %s: %v\n", e.URL, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		out.Infof("\nOperation cancelled!\n")
+		os.Exit(1)
+	}
+
+	n := notifier.New("DL [Terminal Downloader]")
+	if failed > 0 || skipped != nil {
+		summary := fmt.Sprintf("%d of %d files failed", failed, len(entries))
+		if skipped != nil {
+			summary += fmt.Sprintf(", %d directories couldn't be listed", len(skipped.Dirs))
+		}
+		n.Notify("Download finished with errors", summary)
+		out.Errorf("%s\n", summary)
+		os.Exit(1)
+	}
+	n.Notify("Download complete!", fmt.Sprintf("Directory: %s (%d files)", dest, len(entries)))
+}
+
+// downloadEntry download the file of the listing into its directory of the tree with the number of connections;
+// the progress is rendered only if asked, the parallel downloads would mix their bars
+func downloadEntry(ctx context.Context, cfg config.Config, dest string, e downloader.Entry, chunks uint, render bool) error {
+	dir := filepath.Join(dest, filepath.Dir(filepath.FromSlash(e.Path)))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	dm := newDownloadManager(cfg)
+	dm.ApplyOption(downloader.WithConcurrency(chunks))
+	dm.ApplyOption(downloader.WithFilePath(dir))
+	dm.ApplyOption(downloader.WithSkipSubPathMap())
+	dm.ApplyOption(downloader.WithFilename(filepath.Base(filepath.FromSlash(e.Path))))
+	recordHistory(dm, e.URL)
+
+	var res *downloader.Result
+	var err error
+	if render {
+		res, err = runDownload(ctx, dm, e.URL)
+	} else {
+		res, err = dm.DownloadContext(ctx, e.URL)
+	}
+	runHooks(dm, e.URL, res, err)
+	if err != nil && debug {
+		for _, e := range dm.Errors() {
+			log.Println("Error:", e)
+		}
+	}
+	return err
+}
@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 )
 
@@ -18,8 +16,8 @@ func init() {
 }
 
 func version(cmd *cobra.Command, args []string) {
-	fmt.Println(logo)
-	fmt.Println("Version:", Version)
-	fmt.Println("Git commit:", GitCommit)
-	fmt.Println("Build date:", BuildDate)
+	out.Printf("%s\n", logo)
+	out.Printf("Version: %s\n", Version)
+	out.Printf("Git commit: %s\n", GitCommit)
+	out.Printf("Build date: %s\n", BuildDate)
 }
@@ -13,6 +13,9 @@ import (
 const (
 	configDirectory = ".dl"
 	configFileName  = configDirectory + "/config.json"
+
+	// DefaultCacheMaxSize is the size of the cache if it isn't configured
+	DefaultCacheMaxSize = 10 << 30
 )
 
 var (
@@ -25,6 +28,53 @@ type Config struct {
 	Directory   string                   `json:"directory"`
 	Concurrency uint                     `json:"concurrency"`
 	SubDirMap   values.MapStrSliceString `json:"sub_dir_map"`
+	MimeDirMap  values.MapStrSliceString `json:"mime_dir_map"` // media type patterns e.g: video/*, for the extensions without sub directory
+	S3          S3Config                 `json:"s3"`
+	Cache       CacheConfig              `json:"cache"`
+	Hooks       HooksConfig              `json:"hooks"`
+	Rules       []Rule                   `json:"rules"` // checked in order before the sub_dir_map
+}
+
+// S3Config represent configurations for accessing s3:// urls
+type S3Config struct {
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	Profile   string `json:"profile"`
+	PathStyle bool   `json:"path_style"`
+}
+
+// CacheConfig represent configurations of the download cache
+type CacheConfig struct {
+	Enabled bool  `json:"enabled"`
+	MaxSize int64 `json:"max_size"` // bytes; the least recently used files are evicted above, 0 means DefaultCacheMaxSize
+}
+
+// Rule represent a routing rule: the downloads matching all of its conditions are stored at the destination,
+// a template inside the directory e.g: {host}/{yyyy}-{mm}/{name}
+type Rule struct {
+	Host        string   `json:"host,omitempty"`       // the host or its sub-domains
+	Path        string   `json:"path,omitempty"`       // glob of the url path e.g: /releases/*
+	Name        string   `json:"name,omitempty"`       // regular expression of the file name
+	Extensions  []string `json:"extensions,omitempty"` // e.g: [".iso", ".img"]
+	MIME        string   `json:"mime,omitempty"`       // glob of the media type e.g: video/*
+	MinSize     uint64   `json:"min_size,omitempty"`   // bytes
+	MaxSize     uint64   `json:"max_size,omitempty"`   // bytes
+	Destination string   `json:"destination"`
+}
+
+// HooksConfig represent the shell commands run after the downloads; the commands of the category "*",
+// the category of the file and its extension are run in that order
+type HooksConfig struct {
+	Timeout    int              `json:"timeout"`    // seconds a command may run, 0 means one minute
+	Categories map[string]Hooks `json:"categories"` // by sub-directory of the extension or the media type e.g: binary, "*" for every download
+	Extensions map[string]Hooks `json:"extensions"` // e.g: .sh
+}
+
+// Hooks represent the commands run once a download completes or fails; the placeholders {path}, {name},
+// {size}, {url}, {digest} and {errors} are replaced by the quoted values
+type Hooks struct {
+	OnComplete []string `json:"on_complete"`
+	OnFailure  []string `json:"on_failure"`
 }
 
 func getConfigDir() (string, error) {
@@ -35,6 +85,11 @@ func getConfigDir() (string, error) {
 	return filepath.Join(homeDir, configDirectory), nil
 }
 
+// Dir return the directory holding the configuration and the application state e.g: the download queue
+func Dir() (string, error) {
+	return getConfigDir()
+}
+
 func getConfigFileName() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -69,13 +124,31 @@ func LoadDefaultConfig() error {
 			Concurrency: 5,
 			Directory:   "",
 			SubDirMap:   subDir,
+			MimeDirMap:  defaultMimeDirMap(),
 		})
 	}
 	contents, err := ioutil.ReadFile(fn)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(contents, &defaultConfig)
+	if err := json.Unmarshal(contents, &defaultConfig); err != nil {
+		return err
+	}
+	// the config files created before the media types were sorted
+	if defaultConfig.MimeDirMap == nil {
+		defaultConfig.MimeDirMap = defaultMimeDirMap()
+	}
+	return nil
+}
+
+func defaultMimeDirMap() values.MapStrSliceString {
+	mimeDir := make(values.MapStrSliceString)
+	mimeDir["audio"] = []string{"audio/*"}
+	mimeDir["video"] = []string{"video/*"}
+	mimeDir["image"] = []string{"image/*"}
+	mimeDir["document"] = []string{"text/*", "application/pdf", "application/rtf", "application/msword",
+		"application/vnd.ms-excel", "application/vnd.openxmlformats-officedocument.*", "application/vnd.oasis.opendocument.*"}
+	return mimeDir
 }
 
 // CreateConfig create a config file in user home directory
@@ -114,6 +187,32 @@ func SetConfig(c Config) error {
 	}
 
 	oldCfg.AutoUpdate = c.AutoUpdate
+	oldCfg.Cache.Enabled = c.Cache.Enabled
+
+	if c.Cache.MaxSize != 0 {
+		oldCfg.Cache.MaxSize = c.Cache.MaxSize
+	}
+
+	if c.S3.Endpoint != "" {
+		oldCfg.S3.Endpoint = c.S3.Endpoint
+	}
+
+	if c.S3.Region != "" {
+		oldCfg.S3.Region = c.S3.Region
+	}
+
+	if c.S3.Profile != "" {
+		oldCfg.S3.Profile = c.S3.Profile
+	}
+
+	if oldCfg.MimeDirMap == nil {
+		oldCfg.MimeDirMap = make(values.MapStrSliceString)
+	}
+	for k, types := range c.MimeDirMap {
+		for _, t := range types {
+			oldCfg.MimeDirMap.Add(k, t)
+		}
+	}
 
 	for k, extensions := range c.SubDirMap {
 		for _, e := range extensions {
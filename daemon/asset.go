@@ -0,0 +1,200 @@
+// Code generated by vfsgen; DO NOT EDIT.
+
+package daemon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"time"
+)
+
+// WebFS statically implements the virtual filesystem provided to vfsgen.
+var WebFS = func() http.FileSystem {
+	fs := vfsgen۰FS{
+		"/": &vfsgen۰DirInfo{
+			name:    "/",
+			modTime: time.Date(2026, 10, 18, 20, 33, 18, 236710739, time.UTC),
+		},
+		"/app.js": &vfsgen۰CompressedFileInfo{
+			name:             "app.js",
+			modTime:          time.Date(2026, 10, 18, 20, 33, 18, 229598610, time.UTC),
+			uncompressedSize: 5137,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xa4\x58\x5f\x6f\x1b\xb9\x11\x7f\xd7\xa7\x98\x23\xd0\xbb\xdd\x5a\x5e\xc9\x87\xa0\x0f\x56\xd6\x41\x92\xd3\xa5\x29\x2e\x4e\x70\xf6\x43\x01\xc3\x38\x50\xbb\x23\x2f\xad\x15\xb9\x21\xb9\xb6\x95\x58\x9f\xab\xef\xfd\x64\xc5\x90\xdc\x7f\x92\xed\xaa\xbd\x17\x61\x49\xce\x0c\x7f\xf3\x7f\x28\x56\x1b\x04\x63\xb5\xc8\x2c\x9b\x8d\x46\x93\x09\xd8\x02\xc1\x60\xa6\xd1\x82\x5a\x42\x5e\x82\x41\x7d\x87\x70\x7c\xac\xab\xec\x38\x1c\x08\x03\xdc\xac\x30\x07\x25\x33\x04\x2e\x73\x58\x61\x65\x41\x48\xc7\xbd\xd0\xea\xde\xa0\x1e\xdd\x71\xdd\x48\x4a\xa1\x54\x19\x2f\x2f\xac\xd2\xfc\x06\x93\x1b\xb4\x1f\x2d\xae\x23\x96\x97\x41\x24\x8b\xe1\xf1\x11\x18\x61\x58\xd6\x32\xb3\x42\x49\xe0\x95\x88\xd6\x68\x0b\x95\x8f\xa1\xe2\xb6\x18\xc3\x42\xe5\x9b\x18\xbe\x8f\x00\x48\xb6\xaa\x48\xf0\x77\xf0\x34\xa7\xd0\xd0\x16\xc8\x73\xd4\xe6\x14\xbe\x6f\x61\x3b\x1b\x01\x88\x25\x44\xfe\x1a\xcf\x0c\xc4\x9a\x04\xb2\x2b\xf6\xb6\xb6\x85\xd2\xe2\x1b\xa7\x5b\xd9\x35\xa4\xc0\xde\x21\xd7\xa8\x81\xc1\x51\xd0\x80\xc4\x6c\x83\xa8\x0e\xc5\x8e\xa0\xf7\x4a\x5a\x94\xf6\xf8\x72\x53\xa1\x97\xc3\xab\xaa\x14\x99\x13\x3c\xb9\x35\x4a\xb2\x59\xcb\x45\x52\x20\x85\x7f\x5c\x7c\x3e\x4f\xc8\x01\xf2\x46\x2c\x37\x5e\x76\x73\x99\x46\x5b\x6b\x09\x4b\xb4\x59\x11\x31\x5e\x09\xc2\xe3\x2d\xa1\x2a\x1b\x27\xb6\x40\x19\xb5\xe6\x8a\x34\x9a\xaa\xc1\x25\x96\x7e\x9d\x18\xcb\x6d\x6d\x20\x4d\x53\x78\x35\x3d\x69\x8e\xa1\x73\x4c\xa5\xd5\xba\xb2\x11\xbb\xd8\xf1\x83\x27\x1b\xb8\xcd\xec\xbb\x6d\x1c\x04\xc5\x0d\x83\x2d\xb4\xba\x07\x89\xf7\x30\xd7\x5a\xe9\x88\xd5\x92\x07\xfb\x62\xce\x02\xd9\xf6\x59\x8c\x3f\x4f\x5f\x75\x18\x83\xfe\xb2\x2e\xcb\x3e\x5f\xd8\x76\xac\x64\xd4\x68\xcf\x12\x39\xb7\xbc\x13\x43\xf7\xfc\xe0\xa8\xd5\xaa\xdb\xdd\x87\x4a\x5c\x09\xd2\x27\xd9\xa0\x87\xec\x12\x1f\x3a\x05\xb7\x43\x70\xc4\x14\xc0\x79\xbf\xc5\xb3\xd1\xb6\x17\xc4\xa6\x50\xf7\x5e\x3c\x6a\xdd\xc5\x2e\x96\x90\x42\xae\xb2\x7a\x8d\xd2\x52\x3e\xcc\x4b\xa4\xcf\x77\x9b\x8f\x79\xc4\x1c\x08\x6f\x2c\x2c\x13\x8b\x0f\x36\x84\x16\xa4\x80\x5a\xc3\x1b\xfa\x4d\xd6\x68\x0c\xbf\x41\x38\x0d\xfe\xc2\x32\x29\x44\x9e\xa3\x84\x14\x7e\x40\xad\x87\x40\x16\x1b\x8b\x26\x92\x1d\x84\x5a\x0a\x6b\x20\x85\x2b\xf6\x8e\x8d\x81\xad\xdc\xef\x27\xf7\xfb\xc1\xfd\x5e\xba\xdf\x2f\xef\xd8\xf5\x2c\xb0\x08\x48\x61\x4a\x8b\xfb\x42\x94\x08\x91\x84\xb3\x14\x4e\xa6\x3f\xbf\x82\x1f\x7f\x04\x01\xaf\xbd\xcc\xa4\x44\x79\x63\x0b\x38\x86\x36\xde\x24\x4c\x3c\xa1\x37\x95\x38\x3a\xda\x89\xf1\x48\x38\xf7\x4f\xe1\x0d\x48\x38\x05\x99\x58\xf5\xab\x78\xc0\x3c\x3a\x89\x63\x38\x02\xe6\x52\xd1\x49\xbf\x12\xd7\x4e\xb1\xc9\x04\x6a\xb9\x92\xea\x5e\x82\x11\xdf\xd0\x00\xd7\x08\x1a\x2b\xa5\x2d\xe6\xc0\x8d\x2b\x45\x6b\xfe\x00\xb5\x90\xf6\x6f\xaf\x3a\x43\x38\x9e\x0b\xf1\x0d\xa3\x5b\xb5\xf0\x00\x03\x88\x5b\xb5\x48\x48\x16\x9c\xc1\x94\x34\x6a\xd7\xaf\xe1\xbc\x5e\x2f\x50\x27\x9f\xde\xfe\xf3\x8f\x8b\xb7\xbf\xce\xff\xf8\x78\x7e\x39\xff\x30\xff\x7d\xc7\xc4\x5c\x47\xb9\x92\x38\x06\xab\x2c\x2f\x7b\x95\xaa\xb6\xa8\xfb\x0e\xcf\x34\x72\x8b\xc1\xe7\x11\xcb\xc5\x9d\xf7\xb6\x23\x4c\xb2\x92\x1b\x73\xce\xd7\x48\x25\x64\xc1\x35\x6b\xed\x2f\xe5\x61\x72\x1c\x61\x62\xec\xa6\xc4\xe4\x5e\xe4\xb6\x80\x14\x22\x07\xca\xe9\xf6\x06\x3e\x71\x5b\x24\x6b\x21\xa3\x93\xe9\x74\x0c\x0e\x35\xfc\x15\x4e\xa6\xd3\x18\x26\x0d\xfa\x53\x98\x3a\xdb\xff\x85\x75\xd0\x78\x55\xa1\xcc\xdf\x17\xa2\xcc\x23\x77\x49\x3c\xeb\xec\xe7\x48\x76\x4c\x52\x5b\xab\x64\x54\xf2\x05\x96\x63\xe0\x6e\xb3\xb3\xcb\xe2\x05\x5d\x3c\xa7\x57\x67\xb1\x93\x03\x4e\x9c\x3f\x50\x32\x2b\x45\xb6\x82\x14\xba\xf4\x6f\xa2\xce\x5f\xd7\x94\x07\x8d\x4b\x8d\xa6\x88\x93\x8c\x53\x3d\x6d\x13\xd3\xa7\x6d\x4f\x8d\xc5\x50\x05\x8d\x32\x47\xdd\x05\xcb\x7e\xfa\x3e\xe7\x05\x2c\x87\xae\xbc\x55\x0b\x6a\x70\x5e\x04\x75\x8d\x43\x84\x10\xdd\x50\x0c\xed\xb4\x21\x21\xfd\xe6\x73\x62\x4c\xc5\x83\x0d\x89\x70\x28\x87\x76\x58\x7b\x34\xb4\x30\x85\x3e\xd5\x7e\x67\x81\xc7\x47\xb7\x76\x57\x85\xef\x5a\x97\x1d\xa7\xb0\x25\x42\xda\xdf\x77\xa0\xfb\xb1\x42\x84\x71\xab\xbb\x77\x8c\x39\x08\xb7\x8b\x7b\xb2\x14\x9b\xdc\xaa\x85\x99\x50\x21\xa0\x9b\x44\xde\xb4\x76\x5a\xf5\x5a\x08\x23\xe9\x77\xc8\x1a\xa8\xfd\xa3\xaf\x35\xd6\xd4\x86\x06\x01\x62\x06\x48\x43\xc4\xb2\x2f\xbc\x36\xc8\xc6\xc3\xb0\x6a\x42\x84\x46\x13\xf6\xe5\xf3\xc5\x25\x1b\x13\xb8\x23\x60\x93\xca\xd1\xc7\x33\xd8\xc6\x71\x7f\x5a\xd8\x85\xe0\xe8\x0e\x83\xf0\x3b\x9a\x7a\xfd\xbf\x60\xd0\x9e\xe1\xbf\x83\x58\x72\x51\x1e\x0c\xc2\xea\xcd\x9f\xc5\xf0\xc2\x05\xbb\xd0\x32\xb5\xae\x4a\xb4\x98\x33\x78\x03\xec\x7d\x89\x5c\x33\x6a\x71\xef\xb9\xcc\xb0\xdc\x05\xd2\x1f\x08\x1c\x98\x5f\xe6\xbf\xcd\x2f\xe7\x0e\x4e\xe8\xc7\xf1\x93\x01\x19\x10\x35\x79\xda\x3f\x22\xda\x2e\x56\xd7\x68\xf9\x21\x79\x4a\x74\xc3\xfc\xa2\x9d\x36\x4f\x29\xbd\x42\x8e\x78\x6d\x9b\xe8\x1d\xb6\xa3\x46\x25\x62\x71\x45\x39\x85\x17\x0d\xd4\xb6\xa8\x53\xf7\x99\xab\x7b\x59\x2a\x9e\x63\xee\x1b\xad\xbb\xf5\x28\x05\x06\xff\xfe\x97\x6b\xa1\x7e\x0a\x20\xc9\xbe\xad\x4e\x7a\xbb\x8d\x2c\x7f\x12\xd1\x41\xb4\xd3\x19\x5a\x92\x5e\x7f\x76\x2d\x22\x76\x8a\x6e\x01\x4b\x83\x6d\xc4\x75\x68\x1a\xb5\x9e\xc1\xb3\x43\xbc\x17\xb9\x15\x1e\x24\x22\xd0\x51\x14\x1a\xd6\x08\x71\x7e\x19\x16\x37\x5a\x3d\xe1\x77\xa2\xf4\x7e\x6f\x2e\x76\x73\x58\xdf\x25\x3e\x6f\x0e\x09\x07\x08\xb4\xc3\x90\xf0\x7b\x6c\x40\xb0\x5f\x78\xdd\xb5\x9e\x66\x07\xa2\x67\x09\x06\x7a\x31\x80\x76\x18\x69\x34\xf9\xff\x02\x69\xdc\x39\x7d\xcf\x31\x59\x51\xcb\x95\x69\x46\x25\xbf\x6a\xa6\xbf\x33\x38\xe9\x5b\x2e\x90\x1e\x64\xb9\x20\x68\x60\x39\xbf\x17\x2c\xd7\xbb\x6d\xa9\xf4\x9c\x67\x45\x6f\xf8\xcf\xba\x19\x3f\xd0\xec\x1a\x22\x4b\xfc\x94\x96\x25\x28\x73\x38\x86\x8c\xcc\xa2\x6d\x1c\xf7\x07\xf9\x3d\x13\x7a\x61\xbb\x8f\x33\x9a\x43\xb6\xa3\xe6\xad\xdb\xb4\xb5\xef\xa1\xe6\xdd\xe1\x29\x5c\x35\x0d\xe9\x7a\x3c\x02\xf0\x1d\x88\x76\xfd\x17\x1b\xb7\x2d\xc1\x9d\xb7\x4e\x21\x92\x76\x41\x54\x21\x76\xae\x47\xdb\xd9\x60\x3a\x71\x53\x4d\x34\x18\x64\x5d\x25\xfc\x30\xa7\xaa\xec\xbb\x26\xdb\x7b\x22\xd1\x6e\x63\xa8\xee\x8d\x42\x4f\xad\xa0\xbd\xd3\x88\x12\xaa\x99\xf9\x01\x3e\x2f\x6e\x31\xb3\xc9\x0a\x37\x26\x6a\x94\x8d\x9f\xf0\xc0\x0a\x37\x9d\x0f\x48\x4e\x29\x8c\x7d\xe1\xbd\x43\xf4\xed\x83\x53\x18\x9b\xb8\xc9\xf2\xef\x97\x9f\x7e\x23\xcf\xb7\x6f\x51\x82\x9c\x2c\x45\x69\x51\xf7\x15\xe9\xae\x6a\xf5\x6f\xc0\x5d\xad\x70\x73\x9d\x08\x99\xe3\xc3\xe7\x65\x74\x1b\xa2\x3f\x86\xb3\x56\x25\xf2\xf6\x13\x1a\x0c\x84\x7a\x2b\x1c\xa5\x70\xeb\x2b\x0c\x0d\x16\x2d\x7b\x40\xdc\x0f\x94\x66\x60\x8c\xbb\x37\x63\xfb\xe5\xde\xa2\x8e\x23\x23\x5a\x8d\x32\xe4\x4b\xff\xc2\x3d\x1b\xfc\xf4\xba\x02\x97\x0d\x29\xc3\x75\x65\x37\xec\xec\x5c\xd9\x42\xc8\x1b\x28\x50\xe3\xeb\x49\x75\xf6\xd3\xf0\x79\xda\x5c\xf8\xec\x0b\x93\x2c\xe1\xa2\x62\x50\x7c\x7c\x29\xdd\x2f\xa3\xfe\x4d\xfb\xac\x30\x9e\xe7\x2c\x4e\x94\x34\xf5\x62\x2d\xec\x60\x14\x47\xaf\x17\x26\x95\xc6\x3b\x94\xf6\x17\x5c\xf2\xba\xb4\x51\x3b\xda\x69\xfc\x1a\xf2\x05\xa0\xd6\xe5\xe9\xf3\x90\x6b\x5d\xb2\x38\xb9\xe3\x65\x8d\x89\xd5\x62\x1d\xc5\x63\xc7\x45\xa3\xe5\x0b\x6c\x74\xfc\x24\x5f\x2e\x34\x66\x56\xe9\xcd\x0b\xcc\x2d\xcd\x8e\x84\xe6\xc5\xd0\x9f\x7f\x42\xa6\x8d\x49\xa5\xbd\x74\x6b\xcb\x72\x62\xb9\xbe\x41\x9b\x68\x34\x18\xcc\xd0\xfb\x47\x23\xe4\xb2\x37\xfa\x13\x0f\x15\x4a\xfd\x96\xea\x89\x73\xfa\x87\x46\x5a\xd4\x77\xbc\xdc\xbb\xfc\x25\xbe\xed\x98\x3a\xfc\x34\x9e\x8d\xfe\x33\x00\xe2\x1b\x92\x74\x11\x14\x00\x00"),
+		},
+		"/index.html": &vfsgen۰CompressedFileInfo{
+			name:             "index.html",
+			modTime:          time.Date(2026, 10, 18, 20, 33, 5, 476046899, time.UTC),
+			uncompressedSize: 936,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x9c\x93\x4f\x6f\x1c\x2d\x0c\xc6\xef\xf9\x14\xbc\x3e\xbd\x95\xb2\x83\x36\xa7\x2a\x1a\xa6\x8a\x92\xde\x2a\xb5\x95\x7a\xe9\x91\x05\x6f\x70\xca\x00\x01\xcf\xfe\xf9\xf6\x15\xb0\xdb\x56\xda\x55\x54\xf5\x34\xf8\x31\xfe\xd9\x7a\xf0\x8c\xff\x3d\x7d\x7e\xfc\xf6\xfd\xcb\x47\xe1\x78\xf6\xd3\xcd\x58\x3f\xc2\xeb\xf0\xac\x00\x03\x54\x01\xb5\x9d\x6e\x84\x18\x67\x64\x2d\x8c\xd3\xb9\x20\x2b\x58\x78\xbb\x7a\x0f\xbf\x13\x41\xcf\xa8\x60\x47\xb8\x4f\x31\x33\x08\x13\x03\x63\x60\x05\x7b\xb2\xec\x94\xc5\x1d\x19\x5c\xb5\xe0\x56\x50\x20\x26\xed\x57\xc5\x68\x8f\x6a\xdd\x31\x4c\xec\x71\x7a\xfa\x34\xca\x7e\xaa\x9a\xa7\xf0\x43\x64\xf4\x0a\x0a\x1f\x3d\x16\x87\xc8\x20\x5c\xc6\xed\x49\x19\x4c\x29\x75\x4a\xd9\xc7\x1c\x37\xd1\x1e\x5b\x65\x8d\x31\xd7\x63\x0d\xd6\x8d\xeb\xd6\xa7\xb8\x24\x1d\x04\xd9\xca\xd0\x5c\x60\x1a\x65\x55\x5a\x9d\x3c\x17\xd6\x60\x1b\xf3\xdc\xee\x69\x6b\xe1\x54\x4b\x21\x2d\xdc\xc4\x25\x7b\x10\x7c\x4c\xa8\x80\xf1\xc0\x20\x92\xd7\x06\x5d\xf4\x16\xb3\x02\xc7\x9c\xca\xbd\x94\xfb\xfd\x7e\x58\xb2\x1f\x4c\x9c\xe5\x36\xc6\x01\x0f\x7c\x2b\x66\xfd\x1c\x90\xef\x3f\x1c\x58\x0d\xc3\x00\x22\xe3\xeb\x42\x19\xed\x45\x8f\x6a\xeb\x1b\x4d\xb6\xe4\xb1\x59\x2f\xfe\x8f\x89\x29\x06\xed\xdf\x5d\x0e\x6a\x29\xa3\xe1\x98\x8f\x6f\x90\x7e\xdd\xb9\x42\xda\x2c\xcc\x31\x9c\x6a\xcb\xb2\x99\x89\x61\x7a\xb0\x76\x94\x3d\xd3\x8d\xab\x66\xb5\x53\x6a\x4d\x31\xe7\x98\x41\x38\xb2\x16\xc3\x34\xca\xd4\x2d\x2d\x68\x2a\xfd\xfc\x30\x77\xd3\x83\x61\xda\xe1\x28\xdd\xdd\x49\xb3\xb4\xeb\x9e\xb7\x04\x08\xe3\x75\x29\x0a\x5e\xe2\xa6\xbd\x94\xa5\x5d\xef\xf7\x07\xe9\x92\xfa\x75\xc1\x05\xed\x15\xea\x6b\x4b\xfc\x23\xf5\x31\xce\xc9\x23\x5f\x05\x9b\x73\xee\x2f\xd8\x0d\x6e\x32\x25\x16\x25\x1b\x05\x3a\xa5\xe1\xa5\xef\x61\x53\xeb\x42\xf7\x4d\x1e\x65\xff\x2f\x7f\x0e\x00\xfb\x32\x69\x35\xa8\x03\x00\x00"),
+		},
+		"/style.css": &vfsgen۰CompressedFileInfo{
+			name:             "style.css",
+			modTime:          time.Date(2026, 10, 18, 20, 33, 5, 523592384, time.UTC),
+			uncompressedSize: 1429,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8c\x54\xc1\x6e\xdb\x3a\x10\xbc\xfb\x2b\x16\x31\x1e\xf0\x5a\x98\x86\xa4\x24\xaa\xcd\xdc\x7a\x28\xd0\x43\x2f\x0d\xfa\x01\xa4\xb8\x92\x98\x50\x24\x41\x52\xb1\x94\x20\xff\x5e\x88\x92\x6d\xc9\x4d\xda\x00\xbe\x88\xbb\x9c\xd9\xd9\x19\xfa\x33\xbc\xac\x00\xb8\xe9\x88\x97\xcf\x52\x57\x14\xb8\x71\x02\x1d\xe1\xa6\xbb\x5b\xbd\xae\x56\xdc\x88\x3e\xf6\x34\xcc\x55\x52\x53\x48\x80\xb5\xc1\xdc\xc5\x93\x8e\x1c\xa4\x08\x35\x85\x7d\x9e\xd8\x6e\x38\xb3\x4c\x88\x08\x93\xe6\xe3\x41\x69\x74\x20\x25\x6b\xa4\xea\x29\x10\x66\xad\x42\xe2\x7b\x1f\xb0\xd9\xc0\x57\x25\xf5\xe3\x0f\x56\xdc\xc7\xef\x6f\x46\x87\x0d\x5c\xdd\x63\x65\x10\x7e\x7d\xbf\xda\xc0\x4f\xc3\x4d\x30\x1b\xf0\x4c\x7b\xe2\xd1\xc9\xf2\x84\xe8\xe5\x33\x52\x48\x6f\x46\x92\xc2\x28\xe3\x28\xac\xb3\x2c\x1b\x3e\x39\x2b\x1e\x2b\x67\x5a\x2d\x28\xac\xcb\xbc\xfc\x52\xee\xa3\x96\x1a\x99\x40\x17\xd5\x08\xe9\xad\x62\x3d\x85\x52\x61\x84\x60\x4a\x56\x9a\xc8\x80\x8d\xa7\xc0\x99\x47\x25\x35\x0e\x85\x87\xd6\x07\x59\xf6\xa4\x30\x3a\xa0\x0e\x14\xbc\x65\x05\x12\x8e\xe1\x80\xa8\x47\xdc\xf4\x62\x43\xc9\x24\x7f\xa8\x65\x8b\x5a\x76\x63\x3b\x48\x60\x67\xbb\x4b\x29\xf9\x52\xca\xed\xed\x6d\xbc\x5f\x1a\xd7\xbc\x3d\x71\xc5\x2c\x1d\x81\x5e\x57\x2b\xa9\x6d\x1b\x62\xdf\x50\xa6\x90\x2e\xcc\x98\xe8\x46\x6b\x29\xa4\xb6\x03\x6f\x94\x14\xb0\x2e\x8a\xe2\x5c\x21\x8e\x09\xd9\x7a\x0a\x37\x13\xea\xba\x75\x6a\x06\x7a\x1d\x0f\x79\x1b\x82\xd1\xf0\x32\x27\xc8\x6d\x07\x69\xf6\x2e\xcb\x6e\xb7\x7b\x8f\xe5\xd2\xad\x32\x7a\x5c\xb4\xce\x0f\x6b\xb0\x46\xea\x80\x6e\xc6\x4b\x6b\xf3\x34\x79\xb8\xb8\x88\x88\xe3\xc8\xe8\x9c\x19\xeb\xc7\x55\x16\xc9\xf5\x3e\xe3\xb1\xba\x7d\x30\x7c\xe6\x07\xe1\x26\x04\xd3\x9c\x16\x74\x4e\xef\x42\xcb\x47\x26\x8e\x4f\xa8\x66\xc2\x1c\x86\x00\x0c\xda\x33\xdb\x81\xab\x38\xfb\x3f\xd9\xc0\xf4\xdb\xa6\x9f\xce\x63\x6c\x87\x38\xbe\x6d\xed\xbf\x32\x77\x61\xfe\x08\xa7\x59\x83\x11\x6e\x58\x50\xa9\x86\x41\x6a\x29\xc4\xd8\x1f\xb0\x0b\xe4\x5c\x40\xa5\xa4\xf5\xd2\x0f\xa5\x43\x2d\x03\x92\xc8\x40\x41\x9b\x83\x63\xf6\x94\xcd\x03\xca\xaa\x0e\x14\xf2\x24\x99\x31\x35\x18\xd8\x22\xd5\x31\xd4\xf3\xf4\xe6\x79\x3e\xeb\x2f\x99\x54\x28\xde\xf5\x84\xb3\xd1\xaf\x7a\x22\xdb\x7d\x78\xf7\x78\x8d\x39\xb2\xbb\x37\x35\x1f\x91\x85\x7c\x5a\xa0\xa7\x49\xf2\xdf\x1f\x40\x19\xee\x72\x31\x06\x68\x5b\xd4\xad\x7e\xf4\x7f\x79\x73\x53\x36\xa6\x08\x05\x63\xcf\x0f\xe6\x78\xf9\xa4\x69\xf6\x1a\x8f\x03\x9c\x7a\xb1\xb1\xa1\x5f\x6c\x65\xbf\x8f\x7f\x54\xbf\x07\x00\xa1\x10\x5f\xe4\x95\x05\x00\x00"),
+		},
+	}
+	fs["/"].(*vfsgen۰DirInfo).entries = []os.FileInfo{
+		fs["/app.js"].(os.FileInfo),
+		fs["/index.html"].(os.FileInfo),
+		fs["/style.css"].(os.FileInfo),
+	}
+
+	return fs
+}()
+
+type vfsgen۰FS map[string]interface{}
+
+func (fs vfsgen۰FS) Open(path string) (http.File, error) {
+	path = pathpkg.Clean("/" + path)
+	f, ok := fs[path]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	switch f := f.(type) {
+	case *vfsgen۰CompressedFileInfo:
+		gr, err := gzip.NewReader(bytes.NewReader(f.compressedContent))
+		if err != nil {
+			// This should never happen because we generate the gzip bytes such that they are always valid.
+			panic("unexpected error reading own gzip compressed bytes: " + err.Error())
+		}
+		return &vfsgen۰CompressedFile{
+			vfsgen۰CompressedFileInfo: f,
+			gr:                        gr,
+		}, nil
+	case *vfsgen۰DirInfo:
+		return &vfsgen۰Dir{
+			vfsgen۰DirInfo: f,
+		}, nil
+	default:
+		// This should never happen because we generate only the above types.
+		panic(fmt.Sprintf("unexpected type %T", f))
+	}
+}
+
+// vfsgen۰CompressedFileInfo is a static definition of a gzip compressed file.
+type vfsgen۰CompressedFileInfo struct {
+	name              string
+	modTime           time.Time
+	compressedContent []byte
+	uncompressedSize  int64
+}
+
+func (f *vfsgen۰CompressedFileInfo) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("cannot Readdir from file %s", f.name)
+}
+func (f *vfsgen۰CompressedFileInfo) Stat() (os.FileInfo, error) { return f, nil }
+
+func (f *vfsgen۰CompressedFileInfo) GzipBytes() []byte {
+	return f.compressedContent
+}
+
+func (f *vfsgen۰CompressedFileInfo) Name() string       { return f.name }
+func (f *vfsgen۰CompressedFileInfo) Size() int64        { return f.uncompressedSize }
+func (f *vfsgen۰CompressedFileInfo) Mode() os.FileMode  { return 0444 }
+func (f *vfsgen۰CompressedFileInfo) ModTime() time.Time { return f.modTime }
+func (f *vfsgen۰CompressedFileInfo) IsDir() bool        { return false }
+func (f *vfsgen۰CompressedFileInfo) Sys() interface{}   { return nil }
+
+// vfsgen۰CompressedFile is an opened compressedFile instance.
+type vfsgen۰CompressedFile struct {
+	*vfsgen۰CompressedFileInfo
+	gr      *gzip.Reader
+	grPos   int64 // Actual gr uncompressed position.
+	seekPos int64 // Seek uncompressed position.
+}
+
+func (f *vfsgen۰CompressedFile) Read(p []byte) (n int, err error) {
+	if f.grPos > f.seekPos {
+		// Rewind to beginning.
+		err = f.gr.Reset(bytes.NewReader(f.compressedContent))
+		if err != nil {
+			return 0, err
+		}
+		f.grPos = 0
+	}
+	if f.grPos < f.seekPos {
+		// Fast-forward.
+		_, err = io.CopyN(ioutil.Discard, f.gr, f.seekPos-f.grPos)
+		if err != nil {
+			return 0, err
+		}
+		f.grPos = f.seekPos
+	}
+	n, err = f.gr.Read(p)
+	f.grPos += int64(n)
+	f.seekPos = f.grPos
+	return n, err
+}
+func (f *vfsgen۰CompressedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		f.seekPos = 0 + offset
+	case io.SeekCurrent:
+		f.seekPos += offset
+	case io.SeekEnd:
+		f.seekPos = f.uncompressedSize + offset
+	default:
+		panic(fmt.Errorf("invalid whence value: %v", whence))
+	}
+	return f.seekPos, nil
+}
+func (f *vfsgen۰CompressedFile) Close() error {
+	return f.gr.Close()
+}
+
+// vfsgen۰DirInfo is a static definition of a directory.
+type vfsgen۰DirInfo struct {
+	name    string
+	modTime time.Time
+	entries []os.FileInfo
+}
+
+func (d *vfsgen۰DirInfo) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("cannot Read from directory %s", d.name)
+}
+func (d *vfsgen۰DirInfo) Close() error               { return nil }
+func (d *vfsgen۰DirInfo) Stat() (os.FileInfo, error) { return d, nil }
+
+func (d *vfsgen۰DirInfo) Name() string       { return d.name }
+func (d *vfsgen۰DirInfo) Size() int64        { return 0 }
+func (d *vfsgen۰DirInfo) Mode() os.FileMode  { return 0755 | os.ModeDir }
+func (d *vfsgen۰DirInfo) ModTime() time.Time { return d.modTime }
+func (d *vfsgen۰DirInfo) IsDir() bool        { return true }
+func (d *vfsgen۰DirInfo) Sys() interface{}   { return nil }
+
+// vfsgen۰Dir is an opened dir instance.
+type vfsgen۰Dir struct {
+	*vfsgen۰DirInfo
+	pos int // Position within entries for Seek and Readdir.
+}
+
+func (d *vfsgen۰Dir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.pos = 0
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported Seek in directory %s", d.name)
+}
+
+func (d *vfsgen۰Dir) Readdir(count int) ([]os.FileInfo, error) {
+	if d.pos >= len(d.entries) && count > 0 {
+		return nil, io.EOF
+	}
+	if count <= 0 || count > len(d.entries)-d.pos {
+		count = len(d.entries) - d.pos
+	}
+	e := d.entries[d.pos : d.pos+count]
+	d.pos += count
+	return e, nil
+}
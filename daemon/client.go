@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	netUrl "net/url"
+	"strconv"
+	"time"
+)
+
+// Client talks to a running daemon over the unix socket
+type Client struct {
+	http *http.Client
+}
+
+// NewClient return a client of the daemon serving the socket inside the directory
+func NewClient(dir string) *Client {
+	socket := SocketPath(dir)
+	return &Client{
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					conn, err := (&net.Dialer{}).DialContext(ctx, "unix", socket)
+					if err != nil {
+						return nil, fmt.Errorf("daemon: not running, start it with `dl daemon`: %v", err)
+					}
+					return conn, nil
+				},
+			},
+		},
+	}
+}
+
+// Add queue a new download
+func (c *Client) Add(req Request) (Job, error) {
+	var job Job
+	return job, c.do(http.MethodPost, "/jobs", req, &job)
+}
+
+// List return the jobs of the queue
+func (c *Client) List() ([]Job, error) {
+	var jobs []Job
+	return jobs, c.do(http.MethodGet, "/jobs", nil, &jobs)
+}
+
+// Pause stop a queued or active job
+func (c *Client) Pause(id int) (Job, error) {
+	var job Job
+	return job, c.do(http.MethodPost, "/jobs/"+strconv.Itoa(id)+"/pause", nil, &job)
+}
+
+// Resume put a paused or failed job back to the queue
+func (c *Client) Resume(id int) (Job, error) {
+	var job Job
+	return job, c.do(http.MethodPost, "/jobs/"+strconv.Itoa(id)+"/resume", nil, &job)
+}
+
+// Remove drop a job from the queue
+func (c *Client) Remove(id int) error {
+	return c.do(http.MethodDelete, "/jobs/"+strconv.Itoa(id), nil, nil)
+}
+
+// do send the request body as json and decode the json response into v
+func (c *Client) do(method, path string, body, v interface{}) error {
+	var r io.Reader
+	if body != nil {
+		bb, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(bb)
+	}
+
+	// the host is ignored by the unix socket dialer
+	req, err := http.NewRequest(method, "http://dl"+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var uerr *netUrl.Error
+		if errors.As(err, &uerr) {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e errorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("daemon: %s", resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
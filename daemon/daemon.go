@@ -0,0 +1,564 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thedevsaddam/dl/downloader"
+	"github.com/thedevsaddam/dl/logger"
+)
+
+const (
+	queueFileName  = "queue.json"
+	socketFileName = "dl.sock"
+	saveInterval   = 2 * time.Second
+	speedInterval  = time.Second
+)
+
+// ErrNotFound is returned for an unknown job id
+var ErrNotFound = errors.New("daemon: job not found")
+
+// Factory return a download manager configured for the job e.g: from the config file
+type Factory func(job Job) *downloader.DownloadManager
+
+// Options represents the configuration of the daemon
+type Options struct {
+	Dir     string        // directory holding the queue and the socket e.g: ~/.dl
+	Jobs    int           // number of downloads running at the same time, default: 1
+	Factory Factory       // build the download manager of a job
+	OnDone  func(job Job) // called once a job is completed or failed
+	Log     logger.Logger
+
+	Listen         string // tcp address serving the aria2 compatible JSON-RPC e.g: 127.0.0.1:6800; disabled if empty
+	Secret         string // token the JSON-RPC clients must send as "token:<secret>"; required off the loopback
+	Root           string // directory the JSON-RPC clients download into, default: the working directory
+	AllowOriginAll bool   // allow browser clients of any origin
+	Version        string // version reported to the JSON-RPC clients
+}
+
+// Daemon owns the persistent download queue
+type Daemon struct {
+	opt Options
+	log logger.LevelLogger
+
+	mu       sync.Mutex
+	jobs     []*Job // ordered by id
+	nextID   int
+	cancels  map[int]context.CancelFunc // stop the active downloads
+	managers map[int]*downloader.DownloadManager
+	subs     map[chan Job]struct{} // receive the jobs on status change
+	metrics  *metrics
+
+	wake chan struct{}
+}
+
+// state represents the content of the queue file
+type state struct {
+	NextID int    `json:"next_id"`
+	Jobs   []*Job `json:"jobs"`
+}
+
+// SocketPath return the unix socket the daemon listens to
+func SocketPath(dir string) string {
+	return filepath.Join(dir, socketFileName)
+}
+
+// New return a daemon with the queue reloaded from the previous run
+func New(opt Options) (*Daemon, error) {
+	if opt.Dir == "" {
+		return nil, errors.New("daemon: directory can't be empty")
+	}
+	if opt.Factory == nil {
+		return nil, errors.New("daemon: factory can't be nil")
+	}
+	if opt.Listen != "" && opt.Secret == "" && !loopback(opt.Listen) {
+		return nil, errors.New("daemon: a secret is required to listen on " + opt.Listen)
+	}
+	if opt.Jobs <= 0 {
+		opt.Jobs = 1
+	}
+
+	d := &Daemon{
+		opt:      opt,
+		log:      logger.Leveled(opt.Log),
+		nextID:   1,
+		cancels:  make(map[int]context.CancelFunc),
+		managers: make(map[int]*downloader.DownloadManager),
+		subs:     make(map[chan Job]struct{}),
+		metrics:  newMetrics(),
+		wake:     make(chan struct{}, 1),
+	}
+
+	if err := os.MkdirAll(opt.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	bb, err := ioutil.ReadFile(filepath.Join(opt.Dir, queueFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var st state
+	if err := json.Unmarshal(bb, &st); err != nil {
+		return nil, err
+	}
+	for _, j := range st.Jobs {
+		// the downloads interrupted by the shutdown continue from the saved chunks
+		if j.Status == StatusActive {
+			j.Status = StatusQueued
+		}
+		if j.ID >= d.nextID {
+			d.nextID = j.ID + 1
+		}
+	}
+	if st.NextID > d.nextID {
+		d.nextID = st.NextID
+	}
+	d.jobs = st.Jobs
+	return d, nil
+}
+
+// loopback report whether the address only accepts the connections of the machine
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return loopbackHost(host)
+}
+
+// loopbackHost report whether the host e.g: of a Host header, with or without a port, names the machine itself
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Run serve the socket and process the queue until the context is cancelled
+func (d *Daemon) Run(ctx context.Context) error {
+	l, err := listen(SocketPath(d.opt.Dir))
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: d.handler()}
+
+	var rpc *http.Server
+	if d.opt.Listen != "" {
+		tl, err := net.Listen("tcp", d.opt.Listen)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		rpc = &http.Server{
+			Handler:     d.webHandler(),
+			BaseContext: func(net.Listener) context.Context { return ctx }, // close the websockets on shutdown
+		}
+		go func() {
+			if err := rpc.Serve(tl); !errors.Is(err, http.ErrServerClosed) {
+				d.log.Error("web server stopped", "error", err)
+			}
+		}()
+		d.log.Info("web UI and JSON-RPC listening", "addr", tl.Addr())
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < d.opt.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	d.signal()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(l)
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+	srv.Close()
+	if rpc != nil {
+		rpc.Close()
+	}
+	os.Remove(SocketPath(d.opt.Dir))
+
+	// active downloads are cancelled through the context; wait for their progress to be saved
+	wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if saveErr := d.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
+
+// signal wake up an idle worker
+func (d *Daemon) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work download the queued jobs one by one
+func (d *Daemon) work(ctx context.Context) {
+	// a job interrupted by the shutdown goes back to the queue, it must not be claimed again
+	for ctx.Err() == nil {
+		if job := d.claim(); job != nil {
+			d.run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		}
+	}
+}
+
+// claim mark the oldest queued job active and return a copy of it
+func (d *Daemon) claim() *Job {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, j := range d.jobs {
+		// a job resumed right after pausing waits for the previous run to stop
+		if _, running := d.cancels[j.ID]; j.Status != StatusQueued || running {
+			continue
+		}
+		j.Status = StatusActive
+		j.Error = ""
+		j.UpdatedAt = time.Now()
+		d.saveOrLog()
+		d.publish(j)
+		d.signal() // let the other workers look for the next job
+		job := *j
+		return &job
+	}
+	return nil
+}
+
+// run download the job and record the outcome
+func (d *Daemon) run(ctx context.Context, job *Job) {
+	jctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	dm := d.opt.Factory(*job)
+	if job.Location != "" {
+		dm.ApplyOption(downloader.WithResume(job.Location, job.Chunks))
+	}
+	dm.ApplyOption(downloader.WithProgressHandler(d.metrics.counter(job.URL)))
+
+	d.mu.Lock()
+	d.cancels[job.ID] = cancel
+	d.managers[job.ID] = dm
+	d.mu.Unlock()
+	d.log.Info("started job", "job", job.ID, "url", job.URL)
+
+	// measure the speed and save the chunk progress periodically so that a crash loses a few seconds at most
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(speedInterval)
+		defer ticker.Stop()
+		last, _ := dm.GetProgress()
+		savedAt := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				d.mu.Lock()
+				if j := d.find(job.ID); j != nil {
+					d.snapshot(j, dm)
+					if j.Downloaded >= last {
+						j.Speed = uint64(float64(j.Downloaded-last) / speedInterval.Seconds())
+					}
+					last = j.Downloaded
+					if time.Since(savedAt) >= saveInterval {
+						d.saveOrLog()
+						savedAt = time.Now()
+					}
+				}
+				d.mu.Unlock()
+			}
+		}
+	}()
+
+	res, err := dm.DownloadContext(jctx, job.URL)
+	close(done)
+
+	d.mu.Lock()
+	delete(d.cancels, job.ID)
+	delete(d.managers, job.ID)
+	d.metrics.finish(dm)
+
+	j := d.find(job.ID)
+	if j == nil {
+		// removed while downloading; the partial file is of no use
+		d.mu.Unlock()
+		if location := dm.GetLocation(); location != "" && res == nil {
+			os.Remove(location)
+		}
+		return
+	}
+
+	d.snapshot(j, dm)
+	j.Speed = 0
+	paused := j.Status == StatusPaused
+	switch {
+	case res != nil:
+		// a pause arriving after the last byte is too late; so is a stopped seeding
+		j.Status = StatusCompleted
+		j.Digest = res.Digest
+		j.Chunks = nil
+		d.metrics.complete()
+		d.log.Info("completed job", "job", j.ID, "path", j.Location)
+	case j.Status == StatusPaused:
+		d.log.Info("paused job", "job", j.ID)
+	case j.Status == StatusQueued || ctx.Err() != nil:
+		// resumed while stopping or the daemon is shutting down; continue with the next run
+		j.Status = StatusQueued
+		d.signal()
+	default:
+		j.Status = StatusFailed
+		j.Error = err.Error()
+		d.metrics.fail(err)
+		d.log.Error("job failed", "job", j.ID, "url", j.URL, "error", j.Error)
+	}
+	j.UpdatedAt = time.Now()
+	d.saveOrLog()
+	if !paused || j.Status != StatusPaused {
+		d.publish(j)
+	}
+	result := *j
+	d.mu.Unlock()
+
+	if d.opt.OnDone != nil && (result.Status == StatusCompleted || result.Status == StatusFailed) {
+		d.opt.OnDone(result)
+	}
+}
+
+// snapshot copy the progress of the download manager to the job; MUST hold the lock
+func (d *Daemon) snapshot(j *Job, dm *downloader.DownloadManager) {
+	if location := dm.GetLocation(); location != "" {
+		j.Location = location
+	}
+	// the chunks are planned once the meta information is fetched; keep the saved ones until then
+	if chunks := dm.Chunks(); len(chunks) > 0 {
+		j.Chunks = chunks
+	}
+	j.Downloaded, j.Size = dm.GetProgress()
+}
+
+// find return the job of the id; MUST hold the lock
+func (d *Daemon) find(id int) *Job {
+	for _, j := range d.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// Get return the job of the id with the current progress
+func (d *Daemon) Get(id int) (Job, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	j := d.find(id)
+	if j == nil {
+		return Job{}, ErrNotFound
+	}
+	if dm, ok := d.managers[j.ID]; ok {
+		d.snapshot(j, dm)
+	}
+	return *j, nil
+}
+
+// Add append a new download to the queue
+func (d *Daemon) Add(req Request) (Job, error) {
+	req.URL = strings.TrimSpace(req.URL)
+	req.Name = strings.TrimSpace(req.Name)
+	req.Directory = strings.TrimSpace(req.Directory)
+	if req.URL == "" {
+		return Job{}, errors.New("daemon: url can't be empty")
+	}
+	// the daemon runs in its own working directory
+	if req.Directory != "" && !filepath.IsAbs(req.Directory) {
+		return Job{}, errors.New("daemon: directory must be an absolute path")
+	}
+	if req.Name != "" && filepath.Base(req.Name) != req.Name {
+		return Job{}, errors.New("daemon: name must be a file name")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	j := &Job{
+		ID:        d.nextID,
+		URL:       req.URL,
+		Name:      req.Name,
+		Directory: req.Directory,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	d.nextID++
+	d.jobs = append(d.jobs, j)
+	if err := d.save(); err != nil {
+		return Job{}, err
+	}
+	d.signal()
+	return *j, nil
+}
+
+// List return the jobs of the queue with the current progress
+func (d *Daemon) List() []Job {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	jobs := make([]Job, 0, len(d.jobs))
+	for _, j := range d.jobs {
+		if dm, ok := d.managers[j.ID]; ok {
+			d.snapshot(j, dm)
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs
+}
+
+// Pause stop a queued or active job; the progress is kept to resume later
+func (d *Daemon) Pause(id int) (Job, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	j := d.find(id)
+	if j == nil {
+		return Job{}, ErrNotFound
+	}
+	if j.Status != StatusQueued && j.Status != StatusActive {
+		return Job{}, errors.New("daemon: only queued or active jobs can be paused")
+	}
+	if cancel, ok := d.cancels[id]; ok {
+		cancel()
+	}
+	j.Status = StatusPaused
+	j.UpdatedAt = time.Now()
+	d.publish(j)
+	return *j, d.save()
+}
+
+// Resume put a paused or failed job back to the queue
+func (d *Daemon) Resume(id int) (Job, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	j := d.find(id)
+	if j == nil {
+		return Job{}, ErrNotFound
+	}
+	if j.Status != StatusPaused && j.Status != StatusFailed {
+		return Job{}, errors.New("daemon: only paused or failed jobs can be resumed")
+	}
+	j.Status = StatusQueued
+	j.Error = ""
+	j.UpdatedAt = time.Now()
+	if err := d.save(); err != nil {
+		return Job{}, err
+	}
+	d.signal()
+	return *j, nil
+}
+
+// Remove drop the job from the queue; the partial file of an unfinished job is deleted
+func (d *Daemon) Remove(id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, j := range d.jobs {
+		if j.ID != id {
+			continue
+		}
+		d.jobs = append(d.jobs[:i], d.jobs[i+1:]...)
+		removed := *j
+		removed.Status = StatusRemoved
+		d.publish(&removed)
+		if cancel, ok := d.cancels[id]; ok {
+			cancel() // the worker deletes the file once the download stopped
+		} else if j.Status != StatusCompleted && j.Location != "" {
+			os.Remove(j.Location)
+		}
+		return d.save()
+	}
+	return ErrNotFound
+}
+
+// subscribe return a channel receiving the jobs on status change and a func to unsubscribe
+func (d *Daemon) subscribe() (<-chan Job, func()) {
+	ch := make(chan Job, 16)
+	d.mu.Lock()
+	d.subs[ch] = struct{}{}
+	d.mu.Unlock()
+	return ch, func() {
+		d.mu.Lock()
+		delete(d.subs, ch)
+		d.mu.Unlock()
+	}
+}
+
+// publish send the job to the subscribers; slow subscribers miss it; MUST hold the lock
+func (d *Daemon) publish(j *Job) {
+	for ch := range d.subs {
+		select {
+		case ch <- *j:
+		default:
+		}
+	}
+}
+
+// save write the queue to the disk atomically; MUST hold the lock
+func (d *Daemon) save() error {
+	bb, err := json.MarshalIndent(state{NextID: d.nextID, Jobs: d.jobs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fn := filepath.Join(d.opt.Dir, queueFileName)
+	tmp := fn + ".tmp"
+	if err := ioutil.WriteFile(tmp, bb, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// saveOrLog save the queue and log the failure; MUST hold the lock
+func (d *Daemon) saveOrLog() {
+	if err := d.save(); err != nil {
+		d.log.Error("failed to save queue", "error", err)
+	}
+}
@@ -0,0 +1,364 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+func testContent() []byte {
+	b := make([]byte, 2<<20+123)
+	rand.New(rand.NewSource(1)).Read(b)
+	return b
+}
+
+// slowWriter sends the body in small pieces so that a download can be paused halfway
+type slowWriter struct {
+	http.ResponseWriter
+}
+
+func (w slowWriter) Write(b []byte) (int, error) {
+	n := 0
+	for len(b) > 0 {
+		k := 16 << 10
+		if k > len(b) {
+			k = len(b)
+		}
+		m, err := w.ResponseWriter.Write(b[:k])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		b = b[k:]
+		time.Sleep(5 * time.Millisecond)
+	}
+	return n, nil
+}
+
+// newSlowServer serve the content with range support at every path, slowly
+func newSlowServer(content []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(slowWriter{w}, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+// stalledServer serve the first bytes of the content then stall until the client goes away
+func newStalledServer(content []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Method != http.MethodGet {
+			return
+		}
+		w.Write(content[:64<<10])
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+}
+
+// testDaemon return a running daemon storing the queue in its own directory and the downloads in dst, and a func
+// stopping it like a shutdown
+func testDaemon(t *testing.T, opt Options, dst string) (*Daemon, func()) {
+	t.Helper()
+	if opt.Dir == "" {
+		opt.Dir = t.TempDir()
+	}
+	opt.Factory = func(job Job) *downloader.DownloadManager {
+		dir := dst
+		if job.Directory != "" {
+			dir = job.Directory
+		}
+		options := []downloader.OptionFunc{downloader.WithFilePath(dir), downloader.WithSkipSubPathMap(), downloader.WithConcurrency(4)}
+		if job.Name != "" {
+			options = append(options, downloader.WithFilename(job.Name))
+		}
+		return downloader.New(options...)
+	}
+	d, err := New(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := d.Run(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	stop := func() {
+		cancel()
+		<-stopped
+	}
+	t.Cleanup(stop)
+	return d, stop
+}
+
+// waitJob poll the job until the condition holds
+func waitJob(t *testing.T, d *Daemon, id int, cond func(Job) bool) Job {
+	t.Helper()
+	deadline := time.Now().Add(20 * time.Second)
+	for time.Now().Before(deadline) {
+		j, err := d.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond(j) {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	j, _ := d.Get(id)
+	t.Fatalf("job %d didn't reach the expected state: %+v", id, j)
+	return j
+}
+
+func checkFile(t *testing.T, fn string, content []byte) {
+	t.Helper()
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Fatalf("%s: %d bytes differ from the content of %d bytes", fn, len(b), len(content))
+	}
+}
+
+func TestResumeWhileListing(t *testing.T) {
+	content := testContent()
+	srv := newSlowServer(content)
+	t.Cleanup(srv.Close)
+	dst := t.TempDir()
+	d, _ := testDaemon(t, Options{}, dst)
+
+	job, err := d.Add(Request{URL: srv.URL + "/file.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, d, job.ID, func(j Job) bool { return j.Downloaded > 0 })
+	if _, err := d.Pause(job.ID); err != nil {
+		t.Skip("completed before pausing")
+	}
+	// the run stops once its manager is gone
+	waitJob(t, d, job.ID, func(j Job) bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		_, running := d.managers[j.ID]
+		return !running
+	})
+	resumed, err := d.Resume(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Chunks) == 0 {
+		t.Fatal("paused job has no chunk progress")
+	}
+
+	// the resumed job is encoded e.g: as a reply, and the queue is listed while the chunks are written
+	stop := make(chan struct{})
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			json.Marshal(resumed)
+			d.List()
+		}
+	}()
+	j := waitJob(t, d, job.ID, func(j Job) bool { return j.Status == StatusCompleted || j.Status == StatusFailed })
+	close(stop)
+	<-polled
+
+	if j.Status != StatusCompleted {
+		t.Fatalf("job failed: %s", j.Error)
+	}
+	checkFile(t, filepath.Join(dst, "file.bin"), content)
+}
+
+// waitStopped wait until the run of the job is over
+func waitStopped(t *testing.T, d *Daemon, id int) Job {
+	t.Helper()
+	return waitJob(t, d, id, func(j Job) bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		_, running := d.managers[j.ID]
+		return !running
+	})
+}
+
+func TestAddValidation(t *testing.T) {
+	d, err := New(Options{Dir: t.TempDir(), Factory: func(Job) *downloader.DownloadManager { return downloader.New() }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs := t.TempDir()
+	tests := []struct {
+		req Request
+		err bool
+	}{
+		{req: Request{URL: "https://example.com/file.bin"}},
+		{req: Request{URL: " https://example.com/file.bin ", Name: "other.bin", Directory: abs}},
+		{req: Request{URL: "  "}, err: true},
+		{req: Request{URL: "https://example.com/file.bin", Directory: "downloads"}, err: true},
+		{req: Request{URL: "https://example.com/file.bin", Name: "../file.bin"}, err: true},
+		{req: Request{URL: "https://example.com/file.bin", Name: "sub/file.bin"}, err: true},
+	}
+	for _, tt := range tests {
+		j, err := d.Add(tt.req)
+		if (err != nil) != tt.err {
+			t.Errorf("Add(%+v) error %v, want error %v", tt.req, err, tt.err)
+			continue
+		}
+		if err == nil && (j.Status != StatusQueued || j.URL != "https://example.com/file.bin") {
+			t.Errorf("Add(%+v) = %+v", tt.req, j)
+		}
+	}
+	if jobs := d.List(); len(jobs) != 2 || jobs[0].ID != 1 || jobs[1].ID != 2 {
+		t.Fatalf("queue %+v, want the 2 valid jobs", jobs)
+	}
+}
+
+func TestReloadQueue(t *testing.T) {
+	dir := t.TempDir()
+	// the queue of a daemon killed while downloading
+	queue := `{"next_id": 9, "jobs": [
+		{"id": 3, "url": "https://example.com/a.bin", "status": "active", "location": "/tmp/a.bin", "chunks": [{"start": 0, "end": 10, "done": 4}]},
+		{"id": 5, "url": "https://example.com/b.bin", "status": "paused"},
+		{"id": 7, "url": "https://example.com/c.bin", "status": "completed"}
+	]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, queueFileName), []byte(queue), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := New(Options{Dir: dir, Factory: func(Job) *downloader.DownloadManager { return downloader.New() }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := d.List()
+	if len(jobs) != 3 || jobs[0].Status != StatusQueued || jobs[1].Status != StatusPaused || jobs[2].Status != StatusCompleted {
+		t.Fatalf("reloaded %+v", jobs)
+	}
+	if len(jobs[0].Chunks) != 1 || jobs[0].Chunks[0].Done != 4 || jobs[0].Location != "/tmp/a.bin" {
+		t.Fatalf("reloaded %+v, want the chunk progress kept", jobs[0])
+	}
+	if j, err := d.Add(Request{URL: "https://example.com/d.bin"}); err != nil || j.ID != 9 {
+		t.Fatalf("added %+v, %v, want the next id 9", j, err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	content := testContent()
+	srv := newSlowServer(content)
+	t.Cleanup(srv.Close)
+	dir, dst := t.TempDir(), t.TempDir()
+
+	d, stop := testDaemon(t, Options{Dir: dir}, dst)
+	job, err := d.Add(Request{URL: srv.URL + "/file.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, d, job.ID, func(j Job) bool { return j.Downloaded > 0 && len(j.Chunks) > 0 })
+	stop()
+	j, _ := d.Get(job.ID)
+	if j.Status == StatusCompleted {
+		t.Skip("completed before the shutdown")
+	}
+	if j.Status != StatusQueued || j.Location == "" {
+		t.Fatalf("job %+v, want queued with its location after the shutdown", j)
+	}
+
+	// the next daemon continues the download from the saved chunks
+	d, _ = testDaemon(t, Options{Dir: dir}, dst)
+	j = waitJob(t, d, job.ID, func(j Job) bool { return j.Status == StatusCompleted || j.Status == StatusFailed })
+	if j.Status != StatusCompleted {
+		t.Fatalf("job failed: %s", j.Error)
+	}
+	checkFile(t, filepath.Join(dst, "file.bin"), content)
+}
+
+func TestPauseResumeRemove(t *testing.T) {
+	content := testContent()
+	srv := newStalledServer(content)
+	t.Cleanup(srv.Close) // after the daemon stopped, the stalled handlers wait for its connections to close
+	dst := t.TempDir()
+	d, _ := testDaemon(t, Options{}, dst)
+
+	// pausing keeps the partial file for resuming, removing a paused job deletes it
+	paused, err := d.Add(Request{URL: srv.URL + "/paused.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, d, paused.ID, func(j Job) bool { return j.Status == StatusActive && j.Downloaded > 0 })
+	if _, err := d.Pause(paused.ID); err != nil {
+		t.Fatal(err)
+	}
+	j := waitStopped(t, d, paused.ID)
+	if j.Status != StatusPaused || j.Location == "" {
+		t.Fatalf("job %+v, want paused with its location", j)
+	}
+	if _, err := os.Stat(j.Location); err != nil {
+		t.Fatalf("partial file of the paused job: %v", err)
+	}
+	if _, err := d.Pause(paused.ID); err == nil {
+		t.Fatal("expected an error pausing a paused job")
+	}
+	if err := d.Remove(paused.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(j.Location); !os.IsNotExist(err) {
+		t.Fatalf("partial file of the removed job: %v", err)
+	}
+	if _, err := d.Get(paused.ID); err != ErrNotFound {
+		t.Fatalf("removed job: %v", err)
+	}
+
+	// removing an active job deletes its partial file once the download stopped
+	active, err := d.Add(Request{URL: srv.URL + "/active.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j = waitJob(t, d, active.ID, func(j Job) bool { return j.Status == StatusActive && j.Downloaded > 0 && j.Location != "" })
+	if err := d.Remove(active.ID); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for _, err := os.Stat(j.Location); !os.IsNotExist(err); _, err = os.Stat(j.Location) {
+		if time.Now().After(deadline) {
+			t.Fatalf("partial file of the removed active job: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := d.Remove(active.ID); err != ErrNotFound {
+		t.Fatalf("removing twice: %v", err)
+	}
+
+	// a resumed job goes back to the queue and stalls again
+	resumed, err := d.Add(Request{URL: srv.URL + "/resumed.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, d, resumed.ID, func(j Job) bool { return j.Status == StatusActive && j.Downloaded > 0 })
+	if _, err := d.Resume(resumed.ID); err == nil {
+		t.Fatal("expected an error resuming an active job")
+	}
+	d.Pause(resumed.ID)
+	waitStopped(t, d, resumed.ID)
+	if j, err := d.Resume(resumed.ID); err != nil || j.Status != StatusQueued {
+		t.Fatalf("resumed %+v, %v", j, err)
+	}
+	waitJob(t, d, resumed.ID, func(j Job) bool { return j.Status == StatusActive })
+}
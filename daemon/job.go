@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+// Status represents the state of a job in the queue
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusActive    Status = "active"
+	StatusPaused    Status = "paused"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	StatusRemoved   Status = "removed" // only reported to the subscribers
+)
+
+// Job represents a download in the queue
+type Job struct {
+	ID         int                `json:"id"`
+	URL        string             `json:"url"`
+	Name       string             `json:"name,omitempty"`      // destination file name; resolved from the url if empty
+	Directory  string             `json:"directory,omitempty"` // destination directory; the configured one if empty
+	Status     Status             `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	Location   string             `json:"location,omitempty"` // where the file is stored once the download started
+	Size       uint64             `json:"size"`
+	Digest     string             `json:"digest,omitempty"` // hex encoded SHA-256 of the completed download
+	Downloaded uint64             `json:"downloaded"`
+	Speed      uint64             `json:"speed,omitempty"`  // bytes per second of an active job
+	Chunks     []downloader.Chunk `json:"chunks,omitempty"` // progress of the chunks to continue after a restart
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
+
+// Request represents a new download added to the queue
+type Request struct {
+	URL       string `json:"url"`
+	Name      string `json:"name,omitempty"`
+	Directory string `json:"directory,omitempty"`
+}
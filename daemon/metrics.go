@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	netUrl "net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+// metrics holds the counters of the finished work; the gauges are read from the active downloads
+type metrics struct {
+	mu        sync.Mutex
+	bytes     map[string]uint64 // downloaded bytes by host
+	retries   uint64
+	failures  map[string]uint64 // failed jobs by error type
+	completed uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{bytes: make(map[string]uint64), failures: make(map[string]uint64)}
+}
+
+func (m *metrics) add(host string, bytes uint64) {
+	if bytes == 0 {
+		return
+	}
+	m.mu.Lock()
+	m.bytes[host] += bytes
+	m.mu.Unlock()
+}
+
+// finish add the retries of the stopped download; MUST hold the lock of the daemon removing its manager
+func (m *metrics) finish(dm *downloader.DownloadManager) {
+	m.mu.Lock()
+	m.retries += uint64(dm.Stats().Retries)
+	m.mu.Unlock()
+}
+
+func (m *metrics) fail(err error) {
+	m.mu.Lock()
+	m.failures[errorType(err)]++
+	m.mu.Unlock()
+}
+
+func (m *metrics) complete() {
+	m.mu.Lock()
+	m.completed++
+	m.mu.Unlock()
+}
+
+// counter return a progress handler adding the downloaded bytes to the counter of the host
+func (m *metrics) counter(rawURL string) func(downloader.Event) {
+	host := urlHost(rawURL)
+	var last uint64
+	return func(e downloader.Event) {
+		n := e.Stats.Downloaded
+		// the resumed bytes were counted by the previous run; a stream discounts the bytes of a failed segment
+		if e.Type == downloader.MetaFetched || n < last {
+			last = n
+		}
+		m.add(host, n-last)
+		last = n
+	}
+}
+
+// urlHost return the host of the url; the scheme if it has none e.g: magnet or data
+func urlHost(rawURL string) string {
+	u, err := netUrl.Parse(rawURL)
+	switch {
+	case err != nil:
+		return "unknown"
+	case u.Host != "":
+		return strings.ToLower(u.Hostname())
+	case u.Scheme != "":
+		return strings.ToLower(u.Scheme)
+	}
+	return "file" // a torrent file path
+}
+
+// errorType return the class of the error reported as the reason of a failure
+func errorType(err error) string {
+	var netErr net.Error
+	var pathErr *os.PathError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &pathErr): // checked first, it has a Timeout method too
+		return "filesystem"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+	return "other"
+}
+
+// handleMetrics write the metrics in the Prometheus text format
+func (d *Daemon) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	m := d.metrics
+	d.mu.Lock()
+	jobs := make(map[string]uint64)
+	for _, s := range []Status{StatusQueued, StatusActive, StatusPaused, StatusCompleted, StatusFailed} {
+		jobs[string(s)] = 0
+	}
+	for _, j := range d.jobs {
+		jobs[string(j.Status)]++
+	}
+	active, connections, retries := len(d.managers), 0, uint64(0)
+	for _, dm := range d.managers {
+		st := dm.Stats()
+		connections += st.Connections
+		retries += uint64(st.Retries)
+	}
+	m.mu.Lock() // before unlocking the daemon so that the retries of a stopping download are counted once
+	d.mu.Unlock()
+	var total uint64
+	for _, n := range m.bytes {
+		total += n
+	}
+	bytes, failures := copyCounts(m.bytes), copyCounts(m.failures)
+	retries += m.retries
+	completed := m.completed
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	writeMetric(bw, "dl_downloaded_bytes_total", "counter", "Bytes downloaded.", total)
+	writeMetricVec(bw, "dl_host_downloaded_bytes_total", "counter", "Bytes downloaded by host.", "host", bytes)
+	writeMetric(bw, "dl_active_downloads", "gauge", "Downloads in progress.", active)
+	writeMetric(bw, "dl_active_connections", "gauge", "Open connections of the chunks and stream segments.", connections)
+	writeMetric(bw, "dl_retries_total", "counter", "Failed meta requests and stream segments tried again.", retries)
+	writeMetric(bw, "dl_completed_total", "counter", "Completed downloads.", completed)
+	writeMetricVec(bw, "dl_failures_total", "counter", "Failed downloads by error type.", "type", failures)
+	writeMetricVec(bw, "dl_jobs", "gauge", "Jobs of the queue by status.", "status", jobs)
+	bw.Flush()
+}
+
+func copyCounts(m map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func writeMetric(w *bufio.Writer, name, typ, help string, v interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, typ, name, v)
+}
+
+// writeMetricVec write a sample per label value; sorted to keep the output stable
+func writeMetricVec(w *bufio.Writer, name, typ, help, label string, values map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, label, escapeLabel(k), values[k])
+	}
+}
+
+// escapeLabel escape a label value as the text format requires
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,216 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+// timeoutError is a net.Error of a timed out operation
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: context.DeadlineExceeded, want: "timeout"},
+		{err: fmt.Errorf("fetching: %w", context.DeadlineExceeded), want: "timeout"},
+		{err: &net.OpError{Op: "read", Err: timeoutError{}}, want: "timeout"},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: "network"},
+		{err: &os.PathError{Op: "open", Path: "/tmp/file.bin", Err: os.ErrPermission}, want: "filesystem"},
+		{err: fmt.Errorf("writing: %w", &os.PathError{Op: "write", Path: "/tmp/file.bin", Err: timeoutError{}}), want: "filesystem"},
+		{err: errors.New("404 Not Found"), want: "other"},
+	}
+	for _, tt := range tests {
+		if got := errorType(tt.err); got != tt.want {
+			t.Errorf("errorType(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestURLHost(t *testing.T) {
+	tests := map[string]string{
+		"https://CDN.Example.com:8443/file.bin": "cdn.example.com",
+		"http://[::1]:8080/file.bin":            "::1",
+		"magnet:?xt=urn:btih:abc":               "magnet",
+		"/home/user/file.torrent":               "file",
+		"%zz":                                   "unknown",
+	}
+	for u, want := range tests {
+		if got := urlHost(u); got != want {
+			t.Errorf("urlHost(%q) = %s, want %s", u, got, want)
+		}
+	}
+}
+
+func TestMetricsCounter(t *testing.T) {
+	m := newMetrics()
+	count := m.counter("https://example.com/file.bin")
+	for _, e := range []downloader.Event{
+		{Type: downloader.MetaFetched, Stats: downloader.Stats{Downloaded: 100}}, // resumed, counted by the previous run
+		{Type: downloader.ChunkProgress, Stats: downloader.Stats{Downloaded: 150}},
+		{Type: downloader.ChunkProgress, Stats: downloader.Stats{Downloaded: 300}},
+		{Type: downloader.ChunkRetried, Stats: downloader.Stats{Downloaded: 250}}, // the bytes of the failed segment are discounted
+		{Type: downloader.ChunkDone, Stats: downloader.Stats{Downloaded: 400}},
+	} {
+		count(e)
+	}
+	if got := m.bytes["example.com"]; got != 350 {
+		t.Fatalf("counted %d bytes, want 350", got)
+	}
+}
+
+func TestMetricsExposition(t *testing.T) {
+	d, err := New(Options{Dir: t.TempDir(), Factory: func(Job) *downloader.DownloadManager { return downloader.New() }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Add(Request{URL: "https://example.com/a.bin"})
+	d.Add(Request{URL: "https://example.com/b.bin"})
+	d.metrics.add("example.com", 1000)
+	d.metrics.add(`we"ird\host`, 24)
+	d.metrics.fail(context.DeadlineExceeded)
+	d.metrics.fail(errors.New("404 Not Found"))
+	d.metrics.fail(errors.New("410 Gone"))
+	d.metrics.complete()
+
+	w := httptest.NewRecorder()
+	d.handleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `# HELP dl_downloaded_bytes_total Bytes downloaded.
+# TYPE dl_downloaded_bytes_total counter
+dl_downloaded_bytes_total 1024
+# HELP dl_host_downloaded_bytes_total Bytes downloaded by host.
+# TYPE dl_host_downloaded_bytes_total counter
+dl_host_downloaded_bytes_total{host="example.com"} 1000
+dl_host_downloaded_bytes_total{host="we\"ird\\host"} 24
+# HELP dl_active_downloads Downloads in progress.
+# TYPE dl_active_downloads gauge
+dl_active_downloads 0
+# HELP dl_active_connections Open connections of the chunks and stream segments.
+# TYPE dl_active_connections gauge
+dl_active_connections 0
+# HELP dl_retries_total Failed meta requests and stream segments tried again.
+# TYPE dl_retries_total counter
+dl_retries_total 0
+# HELP dl_completed_total Completed downloads.
+# TYPE dl_completed_total counter
+dl_completed_total 1
+# HELP dl_failures_total Failed downloads by error type.
+# TYPE dl_failures_total counter
+dl_failures_total{type="other"} 2
+dl_failures_total{type="timeout"} 1
+# HELP dl_jobs Jobs of the queue by status.
+# TYPE dl_jobs gauge
+dl_jobs{status="active"} 0
+dl_jobs{status="completed"} 0
+dl_jobs{status="failed"} 0
+dl_jobs{status="paused"} 0
+dl_jobs{status="queued"} 2
+`
+	if got := w.Body.String(); got != want {
+		t.Fatalf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type %s", ct)
+	}
+
+	w = httptest.NewRecorder()
+	d.handleMetrics(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status %d", w.Code)
+	}
+}
+
+// metricValue return the value of the sample in the exposition
+func metricValue(t *testing.T, d *Daemon, sample string) uint64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	d.handleMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.HasPrefix(line, sample+" ") {
+			v, err := strconv.ParseUint(strings.TrimPrefix(line, sample+" "), 10, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("no sample %s in %s", sample, w.Body.String())
+	return 0
+}
+
+func TestMetricsDownload(t *testing.T) {
+	content := testContent()
+	// the first connection of every chunk is dropped halfway; /missing.bin fails
+	var mu sync.Mutex
+	dropped := make(map[int]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.bin" {
+			http.NotFound(w, r)
+			return
+		}
+		var min, max int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &min, &max)
+		mu.Lock()
+		drop := r.Method == http.MethodGet && max > 0 && !dropped[max]
+		dropped[max] = true
+		mu.Unlock()
+		if drop {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", min, max, len(content)))
+			w.Header().Set("Content-Length", strconv.Itoa(max-min+1))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[min : min+(max-min)/2])
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	d, _ := testDaemon(t, Options{}, t.TempDir())
+
+	ok, err := d.Add(Request{URL: srv.URL + "/file.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := d.Add(Request{URL: srv.URL + "/missing.bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j := waitJob(t, d, ok.ID, func(j Job) bool { return j.Status == StatusCompleted || j.Status == StatusFailed }); j.Status != StatusCompleted {
+		t.Fatalf("job failed: %s", j.Error)
+	}
+	waitJob(t, d, missing.ID, func(j Job) bool { return j.Status == StatusFailed })
+
+	// the dropped halves are counted as downloaded too
+	if got := metricValue(t, d, `dl_host_downloaded_bytes_total{host="127.0.0.1"}`); got < uint64(len(content)) {
+		t.Errorf("counted %d bytes of 127.0.0.1, want at least %d", got, len(content))
+	}
+	if got := metricValue(t, d, "dl_retries_total"); got != 4 {
+		t.Errorf("%d retries, want 4 chunks", got)
+	}
+	if got := metricValue(t, d, "dl_completed_total"); got != 1 {
+		t.Errorf("%d completed", got)
+	}
+	if got := metricValue(t, d, `dl_failures_total{type="other"}`); got != 1 {
+		t.Errorf("%d failures", got)
+	}
+	if got := metricValue(t, d, `dl_jobs{status="failed"}`); got != 1 {
+		t.Errorf("%d failed jobs", got)
+	}
+}
@@ -0,0 +1,797 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	netUrl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON-RPC error codes; aria2 reports every failure of a method with code 1
+const (
+	rpcCodeFailure        = 1
+	rpcCodeParse          = -32700
+	rpcCodeInvalidRequest = -32600
+	rpcCodeNoMethod       = -32601
+	rpcCodeInvalidParams  = -32602
+)
+
+// rpcRequest represents a JSON-RPC 2.0 request
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+// rpcResponse represents a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcNotification represents a JSON-RPC 2.0 notification sent over the websocket
+type rpcNotification struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcError represents a JSON-RPC 2.0 error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcMethod represents an aria2 method; the secret token is already removed from the params
+type rpcMethod func(d *Daemon, params []json.RawMessage) (interface{}, error)
+
+var rpcMethods map[string]rpcMethod
+
+func init() {
+	// assigned in init as system.multicall refers to the map
+	rpcMethods = map[string]rpcMethod{
+		"aria2.addUri":               rpcAddURI,
+		"aria2.addTorrent":           rpcAddTorrent,
+		"aria2.remove":               rpcRemove,
+		"aria2.forceRemove":          rpcRemove,
+		"aria2.pause":                rpcPause,
+		"aria2.forcePause":           rpcPause,
+		"aria2.pauseAll":             rpcPauseAll,
+		"aria2.forcePauseAll":        rpcPauseAll,
+		"aria2.unpause":              rpcUnpause,
+		"aria2.unpauseAll":           rpcUnpauseAll,
+		"aria2.tellStatus":           rpcTellStatus,
+		"aria2.getFiles":             rpcGetFiles,
+		"aria2.getUris":              rpcGetURIs,
+		"aria2.getOption":            rpcGetOption,
+		"aria2.tellActive":           rpcTellActive,
+		"aria2.tellWaiting":          rpcTellWaiting,
+		"aria2.tellStopped":          rpcTellStopped,
+		"aria2.removeDownloadResult": rpcRemoveDownloadResult,
+		"aria2.purgeDownloadResult":  rpcPurgeDownloadResult,
+		"aria2.getGlobalStat":        rpcGetGlobalStat,
+		"aria2.getGlobalOption":      rpcGetGlobalOption,
+		"aria2.getVersion":           rpcGetVersion,
+		"aria2.getSessionInfo":       rpcGetSessionInfo,
+		"aria2.saveSession":          rpcOK,
+		"system.multicall":           rpcMulticall,
+		"system.listMethods":         rpcListMethods,
+		"system.listNotifications":   rpcListNotifications,
+	}
+}
+
+// aria2 notifications by the job status
+var rpcNotifications = map[Status]string{
+	StatusActive:    "aria2.onDownloadStart",
+	StatusPaused:    "aria2.onDownloadPause",
+	StatusCompleted: "aria2.onDownloadComplete",
+	StatusFailed:    "aria2.onDownloadError",
+	StatusRemoved:   "aria2.onDownloadStop",
+}
+
+// handleRPC serve the aria2 compatible JSON-RPC api over http and websocket
+func (d *Daemon) handleRPC(w http.ResponseWriter, r *http.Request) {
+	if !d.checkOrigin(w, r) {
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+	case isWebSocket(r):
+		d.serveWebSocket(w, r)
+	case r.Method == http.MethodPost:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, wsMaxMessageSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json-rpc")
+		w.Write(d.processRPC(body))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// serveWebSocket answer the requests of a websocket client and push the download notifications
+func (d *Daemon) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	c, err := upgradeWebSocket(w, r)
+	if err != nil {
+		d.log.Error("websocket handshake failed", "remote", r.RemoteAddr, "error", err)
+		return
+	}
+	defer c.Close()
+
+	events, unsubscribe := d.subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-r.Context().Done():
+				c.Close() // unblock the reader on shutdown
+				return
+			case j := <-events:
+				method, ok := rpcNotifications[j.Status]
+				if !ok {
+					continue
+				}
+				bb, _ := json.Marshal(rpcNotification{
+					JSONRPC: "2.0",
+					Method:  method,
+					Params:  []interface{}{map[string]string{"gid": gid(j.ID)}},
+				})
+				if c.writeText(bb) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return
+		}
+		if err := c.writeText(d.processRPC(msg)); err != nil {
+			return
+		}
+	}
+}
+
+// processRPC handle a single or a batch request and return the encoded response
+func (d *Daemon) processRPC(body []byte) []byte {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return encodeRPC(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcCodeParse, "Parse error"}})
+		}
+		responses := make([]rpcResponse, 0, len(batch))
+		for _, raw := range batch {
+			responses = append(responses, d.callRPC(raw))
+		}
+		return encodeRPC(responses)
+	}
+	return encodeRPC(d.callRPC(body))
+}
+
+// callRPC decode and dispatch a single request
+func (d *Daemon) callRPC(raw []byte) rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcCodeParse, "Parse error"}}
+	}
+	if req.ID == nil {
+		req.ID = json.RawMessage("null")
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "" {
+		resp.Error = &rpcError{rpcCodeInvalidRequest, "Invalid Request"}
+		return resp
+	}
+
+	result, err := d.invoke(req.Method, req.Params)
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{rpcCodeFailure, err.Error()}
+		}
+		resp.Error = rerr
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// invoke check the secret token and call the method
+func (d *Daemon) invoke(name string, params []json.RawMessage) (interface{}, error) {
+	method, ok := rpcMethods[name]
+	if !ok {
+		return nil, &rpcError{rpcCodeNoMethod, "Method not found"}
+	}
+
+	// the system methods don't take a token, the calls of a multicall are checked one by one
+	if strings.HasPrefix(name, "aria2.") {
+		token := ""
+		if len(params) > 0 {
+			var s string
+			if json.Unmarshal(params[0], &s) == nil && strings.HasPrefix(s, "token:") {
+				token = strings.TrimPrefix(s, "token:")
+				params = params[1:]
+			}
+		}
+		if d.opt.Secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(d.opt.Secret)) != 1 {
+			return nil, &rpcError{rpcCodeFailure, "Unauthorized"}
+		}
+	}
+	return method(d, params)
+}
+
+func encodeRPC(v interface{}) []byte {
+	bb, _ := json.Marshal(v)
+	return bb
+}
+
+// gid return the aria2 style 16 hex digit identifier of the job
+func gid(id int) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// parseGID return the job id of the aria2 style identifier
+func parseGID(s string) (int, error) {
+	id, err := strconv.ParseUint(s, 16, 63)
+	if err != nil {
+		return 0, fmt.Errorf("GID %s is not found", s)
+	}
+	return int(id), nil
+}
+
+// param decode the i-th param into v; a missing optional param leaves v untouched
+func param(params []json.RawMessage, i int, v interface{}, required bool) error {
+	if i >= len(params) {
+		if required {
+			return &rpcError{rpcCodeInvalidParams, "Invalid params"}
+		}
+		return nil
+	}
+	if err := json.Unmarshal(params[i], v); err != nil {
+		return &rpcError{rpcCodeInvalidParams, "Invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+// gidParam return the job id of the first param
+func gidParam(params []json.RawMessage) (int, error) {
+	var s string
+	if err := param(params, 0, &s, true); err != nil {
+		return 0, err
+	}
+	return parseGID(s)
+}
+
+// rpcOptions represents the supported aria2 input options
+type rpcOptions struct {
+	Dir string `json:"dir"`
+	Out string `json:"out"`
+}
+
+// confine return the directory of the options; the dir of the JSON-RPC clients must stay inside the download root
+// and the out must be a file name. An empty dir is the default location
+func (d *Daemon) confine(opt rpcOptions) (string, error) {
+	if opt.Out != "" && (opt.Out != filepath.Base(opt.Out) || opt.Out == "." || opt.Out == "..") {
+		return "", fmt.Errorf("invalid out: %s", opt.Out)
+	}
+	if opt.Dir == "" {
+		return "", nil
+	}
+	root, err := filepath.Abs(d.opt.Root)
+	if err != nil {
+		return "", err
+	}
+	dir := opt.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	dir = filepath.Clean(dir)
+	if rel, err := filepath.Rel(root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("dir %s is outside the download directory %s", opt.Dir, root)
+	}
+	return dir, nil
+}
+
+func rpcAddURI(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	var uris []string
+	var opt rpcOptions
+	if err := param(params, 0, &uris, true); err != nil {
+		return nil, err
+	}
+	if err := param(params, 1, &opt, false); err != nil {
+		return nil, err
+	}
+	// aria2 takes mirrors of the same file, the first one is downloaded
+	if len(uris) == 0 {
+		return nil, errors.New("No URI to download")
+	}
+	u, err := netUrl.ParseRequestURI(uris[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid URI: %v", err)
+	}
+	// the local files aren't exposed to the JSON-RPC clients
+	if u.Scheme == "" || strings.EqualFold(u.Scheme, "file") {
+		return nil, fmt.Errorf("unsupported URI: %s", uris[0])
+	}
+	dir, err := d.confine(opt)
+	if err != nil {
+		return nil, err
+	}
+	j, err := d.Add(Request{URL: uris[0], Name: opt.Out, Directory: dir})
+	if err != nil {
+		return nil, err
+	}
+	return gid(j.ID), nil
+}
+
+func rpcAddTorrent(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	var encoded string
+	var uris []string
+	var opt rpcOptions
+	if err := param(params, 0, &encoded, true); err != nil {
+		return nil, err
+	}
+	if err := param(params, 1, &uris, false); err != nil {
+		return nil, err
+	}
+	if err := param(params, 2, &opt, false); err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent: %v", err)
+	}
+	target, err := d.confine(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the torrent file along with the queue, the job downloads it through a file:// url
+	dir := filepath.Join(d.opt.Dir, "torrents")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(data)
+	fn := filepath.Join(dir, hex.EncodeToString(sum[:])+".torrent")
+	if err := ioutil.WriteFile(fn, data, 0644); err != nil {
+		return nil, err
+	}
+
+	u := netUrl.URL{Scheme: "file", Path: filepath.ToSlash(fn)}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	j, err := d.Add(Request{URL: u.String(), Directory: target})
+	if err != nil {
+		return nil, err
+	}
+	return gid(j.ID), nil
+}
+
+func rpcRemove(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Remove(id); err != nil {
+		return nil, err
+	}
+	return gid(id), nil
+}
+
+func rpcPause(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Pause(id); err != nil {
+		return nil, err
+	}
+	return gid(id), nil
+}
+
+func rpcUnpause(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Resume(id); err != nil {
+		return nil, err
+	}
+	return gid(id), nil
+}
+
+func rpcPauseAll(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	for _, j := range d.List() {
+		if j.Status == StatusQueued || j.Status == StatusActive {
+			d.Pause(j.ID)
+		}
+	}
+	return "OK", nil
+}
+
+func rpcUnpauseAll(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	for _, j := range d.List() {
+		if j.Status == StatusPaused {
+			d.Resume(j.ID)
+		}
+	}
+	return "OK", nil
+}
+
+func rpcTellStatus(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	if err := param(params, 1, &keys, false); err != nil {
+		return nil, err
+	}
+	j, err := d.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("GID %s is not found", gid(id))
+	}
+	return filterKeys(aria2Status(j), keys), nil
+}
+
+func rpcGetFiles(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	j, err := d.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("GID %s is not found", gid(id))
+	}
+	return aria2Files(j), nil
+}
+
+func rpcGetURIs(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	j, err := d.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("GID %s is not found", gid(id))
+	}
+	return []map[string]string{{"uri": j.URL, "status": "used"}}, nil
+}
+
+func rpcGetOption(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	j, err := d.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("GID %s is not found", gid(id))
+	}
+	opt := map[string]string{}
+	if dir := jobDir(j); dir != "" {
+		opt["dir"] = dir
+	}
+	if j.Name != "" {
+		opt["out"] = j.Name
+	}
+	return opt, nil
+}
+
+func rpcTellActive(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	var keys []string
+	if err := param(params, 0, &keys, false); err != nil {
+		return nil, err
+	}
+	return tellJobs(d, keys, 0, -1, StatusActive), nil
+}
+
+func rpcTellWaiting(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	return tellPage(d, params, StatusQueued, StatusPaused)
+}
+
+func rpcTellStopped(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	return tellPage(d, params, StatusCompleted, StatusFailed)
+}
+
+// tellPage answer the paginated tellWaiting and tellStopped methods
+func tellPage(d *Daemon, params []json.RawMessage, statuses ...Status) (interface{}, error) {
+	var offset, num int
+	var keys []string
+	if err := param(params, 0, &offset, true); err != nil {
+		return nil, err
+	}
+	if err := param(params, 1, &num, true); err != nil {
+		return nil, err
+	}
+	if err := param(params, 2, &keys, false); err != nil {
+		return nil, err
+	}
+	return tellJobs(d, keys, offset, num, statuses...), nil
+}
+
+// tellJobs return num statuses of the jobs starting from offset; a negative offset counts from the end in reverse
+func tellJobs(d *Daemon, keys []string, offset, num int, statuses ...Status) []map[string]interface{} {
+	jobs := make([]Job, 0)
+	for _, j := range d.List() {
+		for _, s := range statuses {
+			if j.Status == s {
+				jobs = append(jobs, j)
+				break
+			}
+		}
+	}
+
+	if offset < 0 {
+		for i, k := 0, len(jobs)-1; i < k; i, k = i+1, k-1 {
+			jobs[i], jobs[k] = jobs[k], jobs[i]
+		}
+		offset = -offset - 1
+	}
+	if offset > len(jobs) {
+		offset = len(jobs)
+	}
+	jobs = jobs[offset:]
+	if num >= 0 && num < len(jobs) {
+		jobs = jobs[:num]
+	}
+
+	result := make([]map[string]interface{}, 0, len(jobs))
+	for _, j := range jobs {
+		result = append(result, filterKeys(aria2Status(j), keys))
+	}
+	return result
+}
+
+func rpcRemoveDownloadResult(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params)
+	if err != nil {
+		return nil, err
+	}
+	j, err := d.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("GID %s is not found", gid(id))
+	}
+	if j.Status != StatusCompleted && j.Status != StatusFailed {
+		return nil, fmt.Errorf("Could not remove download result of GID#%s", gid(id))
+	}
+	if err := d.Remove(id); err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
+
+func rpcPurgeDownloadResult(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	for _, j := range d.List() {
+		if j.Status == StatusCompleted || j.Status == StatusFailed {
+			d.Remove(j.ID)
+		}
+	}
+	return "OK", nil
+}
+
+func rpcGetGlobalStat(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	var speed uint64
+	var active, waiting, stopped int
+	for _, j := range d.List() {
+		switch j.Status {
+		case StatusActive:
+			active++
+			speed += j.Speed
+		case StatusQueued, StatusPaused:
+			waiting++
+		default:
+			stopped++
+		}
+	}
+	return map[string]string{
+		"downloadSpeed":   strconv.FormatUint(speed, 10),
+		"uploadSpeed":     "0",
+		"numActive":       strconv.Itoa(active),
+		"numWaiting":      strconv.Itoa(waiting),
+		"numStopped":      strconv.Itoa(stopped),
+		"numStoppedTotal": strconv.Itoa(stopped),
+	}, nil
+}
+
+func rpcGetGlobalOption(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	return map[string]string{
+		"max-concurrent-downloads": strconv.Itoa(d.opt.Jobs),
+	}, nil
+}
+
+func rpcGetVersion(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"version":         d.opt.Version,
+		"enabledFeatures": []string{"BitTorrent", "HTTPS"},
+	}, nil
+}
+
+func rpcGetSessionInfo(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	sum := sha1.Sum([]byte(d.opt.Dir))
+	return map[string]string{"sessionId": hex.EncodeToString(sum[:])}, nil
+}
+
+// rpcOK answer the methods which are satisfied already e.g: the queue is always saved
+func rpcOK(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	return "OK", nil
+}
+
+func rpcMulticall(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	var calls []struct {
+		MethodName string            `json:"methodName"`
+		Params     []json.RawMessage `json:"params"`
+	}
+	if err := param(params, 0, &calls, true); err != nil {
+		return nil, err
+	}
+
+	// every result is wrapped in an array, the failures are reported as a fault struct
+	results := make([]interface{}, 0, len(calls))
+	for _, c := range calls {
+		if c.MethodName == "system.multicall" {
+			results = append(results, &rpcError{rpcCodeFailure, "Recursive system.multicall forbidden."})
+			continue
+		}
+		result, err := d.invoke(c.MethodName, c.Params)
+		if err != nil {
+			var rerr *rpcError
+			if !errors.As(err, &rerr) {
+				rerr = &rpcError{rpcCodeFailure, err.Error()}
+			}
+			results = append(results, rerr)
+			continue
+		}
+		results = append(results, []interface{}{result})
+	}
+	return results, nil
+}
+
+func rpcListMethods(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	methods := make([]string, 0, len(rpcMethods))
+	for m := range rpcMethods {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods, nil
+}
+
+func rpcListNotifications(d *Daemon, params []json.RawMessage) (interface{}, error) {
+	notifications := make([]string, 0, len(rpcNotifications))
+	for _, n := range rpcNotifications {
+		notifications = append(notifications, n)
+	}
+	sort.Strings(notifications)
+	return notifications, nil
+}
+
+// aria2Status return the job in the shape of aria2.tellStatus; the chunks are reported as pieces
+func aria2Status(j Job) map[string]interface{} {
+	status := map[Status]string{
+		StatusQueued:    "waiting",
+		StatusActive:    "active",
+		StatusPaused:    "paused",
+		StatusCompleted: "complete",
+		StatusFailed:    "error",
+	}[j.Status]
+
+	size, downloaded := j.Size, j.Downloaded
+	if size == ^uint64(0) { // unknown size
+		size = 0
+	}
+	if j.Status == StatusCompleted {
+		downloaded = size
+	}
+
+	// a bit per chunk, the highest bit of the first byte is the first chunk
+	bitfield := make([]byte, (len(j.Chunks)+7)/8)
+	connections := 0
+	for i, c := range j.Chunks {
+		if c.Done >= c.End-c.Start {
+			bitfield[i/8] |= 0x80 >> uint(i%8)
+		} else if j.Status == StatusActive {
+			connections++
+		}
+	}
+	pieceLength := uint64(0)
+	if len(j.Chunks) > 0 {
+		pieceLength = j.Chunks[0].End - j.Chunks[0].Start
+	}
+
+	s := map[string]interface{}{
+		"gid":             gid(j.ID),
+		"status":          status,
+		"totalLength":     strconv.FormatUint(size, 10),
+		"completedLength": strconv.FormatUint(downloaded, 10),
+		"uploadLength":    "0",
+		"downloadSpeed":   strconv.FormatUint(j.Speed, 10),
+		"uploadSpeed":     "0",
+		"connections":     strconv.Itoa(connections),
+		"numPieces":       strconv.Itoa(len(j.Chunks)),
+		"pieceLength":     strconv.FormatUint(pieceLength, 10),
+		"bitfield":        hex.EncodeToString(bitfield),
+		"dir":             jobDir(j),
+		"files":           aria2Files(j),
+	}
+	if j.Status == StatusFailed {
+		s["errorCode"] = "1"
+		s["errorMessage"] = j.Error
+	}
+	return s
+}
+
+// aria2Files return the file list of aria2.getFiles
+func aria2Files(j Job) []map[string]interface{} {
+	size, downloaded := j.Size, j.Downloaded
+	if size == ^uint64(0) {
+		size = 0
+	}
+	if j.Status == StatusCompleted {
+		downloaded = size
+	}
+	p := j.Location
+	if p == "" && j.Name != "" && j.Directory != "" {
+		p = filepath.Join(j.Directory, j.Name)
+	}
+	return []map[string]interface{}{{
+		"index":           "1",
+		"path":            p,
+		"length":          strconv.FormatUint(size, 10),
+		"completedLength": strconv.FormatUint(downloaded, 10),
+		"selected":        "true",
+		"uris":            []map[string]string{{"uri": j.URL, "status": "used"}},
+	}}
+}
+
+// jobDir return the directory of the job as known so far
+func jobDir(j Job) string {
+	if j.Location != "" {
+		return filepath.Dir(j.Location)
+	}
+	return j.Directory
+}
+
+// filterKeys keep only the requested keys; no keys means everything
+func filterKeys(m map[string]interface{}, keys []string) map[string]interface{} {
+	if len(keys) == 0 {
+		return m
+	}
+	filtered := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+// rpcCall send the request through processRPC and return the result or the error of the response
+func rpcCall(t *testing.T, d *Daemon, method string, params ...interface{}) (json.RawMessage, *rpcError) {
+	t.Helper()
+	if params == nil {
+		params = []interface{}{}
+	}
+	req, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": "qwer", "method": method, "params": params})
+	var resp struct {
+		ID     string          `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.Unmarshal(d.processRPC(req), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "qwer" {
+		t.Fatalf("%s: response id %q", method, resp.ID)
+	}
+	return resp.Result, resp.Error
+}
+
+func TestConfine(t *testing.T) {
+	root := t.TempDir()
+	d := &Daemon{opt: Options{Root: root}}
+	tests := []struct {
+		opt  rpcOptions
+		want string
+		err  bool
+	}{
+		{opt: rpcOptions{}, want: ""},
+		{opt: rpcOptions{Out: "file.bin"}, want: ""},
+		{opt: rpcOptions{Dir: "movies"}, want: filepath.Join(root, "movies")},
+		{opt: rpcOptions{Dir: "movies/../music/"}, want: filepath.Join(root, "music")},
+		{opt: rpcOptions{Dir: filepath.Join(root, "movies")}, want: filepath.Join(root, "movies")},
+		{opt: rpcOptions{Dir: root}, want: root},
+		{opt: rpcOptions{Dir: ".."}, err: true},
+		{opt: rpcOptions{Dir: "movies/../../etc"}, err: true},
+		{opt: rpcOptions{Dir: "/etc"}, err: true},
+		{opt: rpcOptions{Dir: root + "-other"}, err: true},
+		{opt: rpcOptions{Out: "../file.bin"}, err: true},
+		{opt: rpcOptions{Out: "sub/file.bin"}, err: true},
+		{opt: rpcOptions{Out: ".."}, err: true},
+		{opt: rpcOptions{Out: "."}, err: true},
+	}
+	for _, tt := range tests {
+		got, err := d.confine(tt.opt)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("confine(%+v) = %q, %v, want %q, error %v", tt.opt, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestRPCToken(t *testing.T) {
+	d, err := New(Options{Dir: t.TempDir(), Secret: "s3cret", Factory: func(Job) *downloader.DownloadManager { return downloader.New() }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, params := range [][]interface{}{nil, {"token:guess"}, {"s3cret"}} {
+		if _, rerr := rpcCall(t, d, "aria2.getVersion", params...); rerr == nil || rerr.Message != "Unauthorized" {
+			t.Errorf("getVersion with %v: error %v, want Unauthorized", params, rerr)
+		}
+	}
+	if _, rerr := rpcCall(t, d, "aria2.getVersion", "token:s3cret"); rerr != nil {
+		t.Fatal(rerr)
+	}
+	// the system methods don't take the token
+	if _, rerr := rpcCall(t, d, "system.listMethods"); rerr != nil {
+		t.Fatal(rerr)
+	}
+
+	// every call of a multicall is checked on its own
+	result, rerr := rpcCall(t, d, "system.multicall", []interface{}{
+		map[string]interface{}{"methodName": "aria2.getVersion", "params": []interface{}{"token:s3cret"}},
+		map[string]interface{}{"methodName": "aria2.getVersion", "params": []interface{}{"token:guess"}},
+		map[string]interface{}{"methodName": "aria2.addUri", "params": []interface{}{[]string{"https://example.com/file.bin"}}},
+		map[string]interface{}{"methodName": "system.multicall", "params": []interface{}{[]interface{}{}}},
+	})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	var results []json.RawMessage
+	if err := json.Unmarshal(result, &results); err != nil || len(results) != 4 {
+		t.Fatalf("multicall results %s: %v", result, err)
+	}
+	if results[0][0] != '[' {
+		t.Errorf("authorized call: %s", results[0])
+	}
+	for i, want := range []string{"Unauthorized", "Unauthorized", "Recursive system.multicall forbidden."} {
+		var fault rpcError
+		if err := json.Unmarshal(results[i+1], &fault); err != nil || fault.Message != want {
+			t.Errorf("call %d: %s, want the fault %q", i+1, results[i+1], want)
+		}
+	}
+	if jobs := d.List(); len(jobs) != 0 {
+		t.Fatalf("unauthorized call added %+v", jobs)
+	}
+}
+
+func TestRPCRoundTrip(t *testing.T) {
+	content := testContent()
+	srv := newStalledServer(content)
+	t.Cleanup(srv.Close)
+	root := t.TempDir()
+	d, _ := testDaemon(t, Options{Root: root, Secret: "s3cret"}, t.TempDir())
+
+	// the local files and the directories outside the root are refused
+	for _, params := range [][]interface{}{
+		{"token:s3cret", []string{"file:///etc/passwd"}},
+		{"token:s3cret", []string{"/etc/passwd"}},
+		{"token:s3cret", []string{srv.URL + "/file.bin"}, map[string]string{"dir": "../outside"}},
+		{"token:s3cret", []string{srv.URL + "/file.bin"}, map[string]string{"out": "../file.bin"}},
+		{"token:s3cret", []string{}},
+	} {
+		if _, rerr := rpcCall(t, d, "aria2.addUri", params...); rerr == nil {
+			t.Errorf("addUri %v: expected an error", params)
+		}
+	}
+
+	result, rerr := rpcCall(t, d, "aria2.addUri", "token:s3cret", []string{srv.URL + "/file.bin"}, map[string]string{"dir": "movies", "out": "renamed.bin"})
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	var g string
+	json.Unmarshal(result, &g)
+	id, err := parseGID(g)
+	if err != nil || len(g) != 16 {
+		t.Fatalf("gid %q: %v", g, err)
+	}
+	j, err := d.Get(id)
+	if err != nil || j.Directory != filepath.Join(root, "movies") || j.Name != "renamed.bin" {
+		t.Fatalf("added %+v, %v", j, err)
+	}
+
+	status := func() map[string]interface{} {
+		t.Helper()
+		result, rerr := rpcCall(t, d, "aria2.tellStatus", "token:s3cret", g, []string{"gid", "status", "totalLength", "completedLength"})
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		var s map[string]interface{}
+		json.Unmarshal(result, &s)
+		if s["gid"] != g || len(s) != 4 {
+			t.Fatalf("status %v, want the 4 keys of %s", s, g)
+		}
+		return s
+	}
+	waitJob(t, d, id, func(j Job) bool { return j.Status == StatusActive && j.Downloaded > 0 })
+	if s := status(); s["status"] != "active" || s["completedLength"] == "0" {
+		t.Fatalf("status %v, want active", s)
+	}
+
+	if _, rerr := rpcCall(t, d, "aria2.pause", "token:s3cret", g); rerr != nil {
+		t.Fatal(rerr)
+	}
+	waitStopped(t, d, id)
+	if s := status(); s["status"] != "paused" {
+		t.Fatalf("status %v, want paused", s)
+	}
+	if _, rerr := rpcCall(t, d, "aria2.unpause", "token:s3cret", g); rerr != nil {
+		t.Fatal(rerr)
+	}
+	waitJob(t, d, id, func(j Job) bool { return j.Status == StatusActive })
+	if _, rerr := rpcCall(t, d, "aria2.remove", "token:s3cret", g); rerr != nil {
+		t.Fatal(rerr)
+	}
+	if _, rerr := rpcCall(t, d, "aria2.tellStatus", "token:s3cret", g); rerr == nil {
+		t.Fatal("expected an error for the removed gid")
+	}
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"net/http"
+	netUrl "net/url"
+	"strings"
+)
+
+// webHandler return the handler of the tcp listener:
+//
+//	/         web UI
+//	/api/     the socket api guarded by the secret as bearer token
+//	/jsonrpc  aria2 compatible JSON-RPC
+//	/metrics  Prometheus metrics guarded by the secret as bearer token
+func (d *Daemon) webHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(WebFS))
+	mux.Handle("/api/", http.StripPrefix("/api", d.authorize(d.handler())))
+	mux.HandleFunc("/jsonrpc", d.handleRPC)
+	mux.Handle("/metrics", d.authorize(http.HandlerFunc(d.handleMetrics)))
+	return mux
+}
+
+// authorize reject the requests without the secret
+func (d *Daemon) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.checkOrigin(w, r) {
+			return
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if d.opt.Secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(d.opt.Secret)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkOrigin set the CORS headers; without a secret the other machines and the web pages of other origins are kept
+// away from the downloads, including the pages of a domain rebound to the loopback address through the Host header
+func (d *Daemon) checkOrigin(w http.ResponseWriter, r *http.Request) bool {
+	if d.opt.Secret == "" && (!loopback(r.RemoteAddr) || !loopbackHost(r.Host)) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	if d.opt.AllowOriginAll {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		return true
+	}
+	if origin := r.Header.Get("Origin"); d.opt.Secret == "" && origin != "" && !sameOrigin(origin, r.Host) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// sameOrigin report whether the browser origin points to the host serving the request
+func sameOrigin(origin, host string) bool {
+	u, err := netUrl.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, host)
+}
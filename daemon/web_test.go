@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thedevsaddam/dl/downloader"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name                 string
+		secret               string
+		allowAll             bool
+		remote, host, origin string
+		authorization        string
+		want                 int
+	}{
+		{name: "local", remote: "127.0.0.1:5000", host: "127.0.0.1:6800", want: http.StatusOK},
+		{name: "localhost", remote: "[::1]:5000", host: "localhost:6800", want: http.StatusOK},
+		{name: "ipv6 host", remote: "[::1]:5000", host: "[::1]:6800", want: http.StatusOK},
+		{name: "host without port", remote: "127.0.0.1:5000", host: "localhost", want: http.StatusOK},
+		{name: "same origin", remote: "127.0.0.1:5000", host: "localhost:6800", origin: "http://localhost:6800", want: http.StatusOK},
+		{name: "remote", remote: "192.168.1.10:5000", host: "192.168.1.2:6800", want: http.StatusForbidden},
+		{name: "rebound domain", remote: "127.0.0.1:5000", host: "attacker.example.com:6800", want: http.StatusForbidden},
+		{name: "rebound domain of the origin", remote: "127.0.0.1:5000", host: "attacker.example.com:6800", origin: "http://attacker.example.com:6800", want: http.StatusForbidden},
+		{name: "other origin", remote: "127.0.0.1:5000", host: "localhost:6800", origin: "http://attacker.example.com", want: http.StatusForbidden},
+		{name: "other origin allowed", allowAll: true, remote: "127.0.0.1:5000", host: "localhost:6800", origin: "http://attacker.example.com", want: http.StatusOK},
+		{name: "secret", secret: "s3cret", remote: "192.168.1.10:5000", host: "dl.example.com", authorization: "Bearer s3cret", want: http.StatusOK},
+		{name: "wrong secret", secret: "s3cret", remote: "192.168.1.10:5000", host: "dl.example.com", authorization: "Bearer guess", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		d, err := New(Options{
+			Dir:            t.TempDir(),
+			Factory:        func(Job) *downloader.DownloadManager { return downloader.New() },
+			Secret:         tt.secret,
+			AllowOriginAll: tt.allowAll,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.RemoteAddr, r.Host = tt.remote, tt.host
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if tt.authorization != "" {
+			r.Header.Set("Authorization", tt.authorization)
+		}
+		w := httptest.NewRecorder()
+		d.webHandler().ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if tt.allowAll && w.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("%s: CORS headers missing", tt.name)
+		}
+	}
+}
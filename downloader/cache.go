@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Cache keeps the downloaded files to serve the unchanged resources without downloading them again
+type Cache interface {
+	// Get link the cached file of the url and ETag to dst; ok is false if it isn't cached
+	Get(url, etag, dst string) (digest string, ok bool, err error)
+	// Put add the downloaded file of the url and ETag; digest is its hex encoded SHA-256
+	Put(url, etag, path, digest string) error
+}
+
+// cacheable report whether the download can be served from or added to the cache
+func (d *DownloadManager) cacheable() bool {
+	return d.option.cache != nil && d.torrent == nil && d.playlist == nil && d.option.resumeLocation == "" &&
+		d.header.Get("ETag") != ""
+}
+
+// fromCache link the cached file to the location; nil if it isn't cached. The caller emits the completion
+func (d *DownloadManager) fromCache(url string, startedAt time.Time) *Result {
+	if !d.cacheable() {
+		return nil
+	}
+	digest, ok, err := d.option.cache.Get(url, d.header.Get("ETag"), d.location)
+	if err != nil {
+		d.option.log.Warn("failed to read cache", "url", url, "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	fi, err := os.Stat(d.location)
+	if err != nil {
+		d.option.log.Warn("failed to read cache", "url", url, "error", err)
+		return nil
+	}
+
+	size := uint64(fi.Size())
+	atomic.StoreUint64(&d.fileSize, size)
+	atomic.StoreUint64(&d.totalDownloaded, size)
+	atomic.StoreInt32(&d.totalChunkCompleted, 1)
+	d.setTotalChunks(1)
+	d.startChunks()
+	res := &Result{Path: d.location, Size: size, Digest: digest, Duration: time.Since(startedAt)}
+	d.option.log.Info("served from cache", "path", res.Path, "size", res.Size, "digest", res.Digest)
+	return res
+}
+
+// toCache add the downloaded file to the cache; a failure doesn't fail the download
+func (d *DownloadManager) toCache(url string, res *Result) {
+	if !d.cacheable() || res.Digest == "" {
+		return
+	}
+	if err := d.option.cache.Put(url, d.header.Get("ETag"), res.Path, res.Digest); err != nil {
+		d.option.log.Warn("failed to add file to cache", "path", res.Path, "error", err)
+	}
+}
@@ -0,0 +1,114 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Chunk represents the byte range [Start, End) of the file downloaded by a single worker
+type Chunk struct {
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+	Done  uint64 `json:"done"` // bytes written to the file from Start
+}
+
+// complete report whether the whole range is written
+func (c Chunk) complete() bool {
+	return c.Done >= c.End-c.Start
+}
+
+// Chunks return a snapshot of the chunk progress; it's safe to call while downloading
+func (d *DownloadManager) Chunks() []Chunk {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// End of a file of unknown size is set once done, under the lock
+	snapshot := make([]Chunk, len(d.chunks))
+	for i := range d.chunks {
+		snapshot[i] = Chunk{
+			Start: d.chunks[i].Start,
+			End:   d.chunks[i].End,
+			Done:  atomic.LoadUint64(&d.chunks[i].Done),
+		}
+	}
+	return snapshot
+}
+
+// planChunks split the file into equal ranges, the last one takes the remainder; a file of unknown size is
+// a single chunk read until the end, its End is set once done
+func planChunks(size uint64, concurrency int) []Chunk {
+	if size == ^uint64(0) {
+		return []Chunk{{Start: 0, End: size}}
+	}
+	chunkLen := size / uint64(concurrency)
+	chunks := make([]Chunk, concurrency)
+	for i := range chunks {
+		chunks[i].Start = chunkLen * uint64(i)
+		chunks[i].End = chunkLen * uint64(i+1)
+	}
+	chunks[concurrency-1].End = size
+	return chunks
+}
+
+// planDownload plan the chunks unless resumed; the chunks of a server ignoring range requests are replaced by
+// a single one from the start
+func (d *DownloadManager) planDownload(ctx context.Context, url string) error {
+	size := atomic.LoadUint64(&d.fileSize)
+	d.mu.Lock()
+	if d.chunks == nil {
+		d.chunks = planChunks(size, d.option.concurrency)
+	}
+	chunks := d.chunks
+	d.mu.Unlock()
+
+	if size == 0 || size == ^uint64(0) || (len(chunks) == 1 && chunks[0].Done == 0) {
+		return nil // a single request from the start works either way
+	}
+	ignored, err := d.rangesIgnored(ctx, url)
+	if err != nil || !ignored {
+		return err
+	}
+	d.mu.Lock()
+	d.chunks = []Chunk{{Start: 0, End: size}}
+	d.mu.Unlock()
+	return nil
+}
+
+// rangesIgnored report whether the server answers range requests with the whole resource, probed with a request
+// of the first byte; the local resources are always read by range
+func (d *DownloadManager) rangesIgnored(ctx context.Context, url string) (bool, error) {
+	if _, remote := d.fetcher.(httpFetcher); !remote {
+		return false, nil
+	}
+	var body io.ReadCloser
+	err := retryContext(ctx, 3, 200*time.Millisecond, func() (err error) {
+		body, err = d.fetcher.fetch(ctx, url, 0, 1)
+		return err
+	})
+	if errors.Is(err, errRangeIgnored) {
+		d.option.log.Warn("server ignores range requests, downloading through a single connection", "url", url)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, body.Close()
+}
+
+// validChunks report whether the chunks were planned for a file of the size
+func validChunks(chunks []Chunk, size uint64) bool {
+	if len(chunks) == 0 {
+		return false
+	}
+	next := uint64(0)
+	for _, c := range chunks {
+		if c.Start != next || c.End < c.Start || c.Done > c.End-c.Start {
+			return false
+		}
+		next = c.End
+	}
+	return next == size
+}
@@ -0,0 +1,202 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	netUrl "net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	maxIndexSize = 16 << 20 // an autoindex page larger than this is not a directory listing
+)
+
+var hrefPattern = regexp.MustCompile(`(?i)<a\s[^>]*?href\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+
+// CrawlOptions represents the filters applied while crawling a directory listing
+type CrawlOptions struct {
+	Accept   []string // glob patterns of file names to download; empty accepts everything
+	Reject   []string // glob patterns of file names to skip
+	MaxDepth int      // maximum sub-directory depth; 0 means unlimited
+}
+
+// Entry represents a file found in a directory listing
+type Entry struct {
+	URL  string // absolute url of the file
+	Path string // slash separated path relative to the crawled directory
+}
+
+// CrawlError is returned along with the found files when sub-directories couldn't be listed; they are skipped
+type CrawlError struct {
+	Dirs []string // urls of the skipped directories
+	Errs []error  // listing error of every skipped directory
+}
+
+func (e *CrawlError) Error() string {
+	failed := make([]string, len(e.Dirs))
+	for i, d := range e.Dirs {
+		failed[i] = fmt.Sprintf("%s: %v", d, e.Errs[i])
+	}
+	return fmt.Sprintf("dl: failed to list %d directories: %s", len(e.Dirs), strings.Join(failed, "; "))
+}
+
+// Crawl parse the Apache/nginx autoindex page at root and recursively return the files below it; a sub-directory
+// that can't be listed is skipped and reported by a *CrawlError returned with the other files
+func Crawl(ctx context.Context, client HTTPClient, root string, opt CrawlOptions) ([]Entry, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base, err := netUrl.Parse(root)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("dl: recursive download requires a http(s) url: %s", root)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+		base.RawPath = ""
+	}
+	base.RawQuery, base.Fragment = "", ""
+
+	entries := make([]Entry, 0)
+	visited := map[string]bool{base.String(): true}
+	type dir struct {
+		u     *netUrl.URL
+		depth int
+	}
+	queue := []dir{{base, 0}}
+	var skipped *CrawlError
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		links, err := listDirectory(ctx, client, cur.u)
+		if err != nil && (cur.u == base || ctx.Err() != nil) {
+			return nil, fmt.Errorf("dl: failed to list %s: %v", cur.u, err)
+		}
+		if err != nil {
+			if skipped == nil {
+				skipped = &CrawlError{}
+			}
+			skipped.Dirs = append(skipped.Dirs, cur.u.String())
+			skipped.Errs = append(skipped.Errs, err)
+			continue
+		}
+
+		for _, link := range links {
+			if visited[link.String()] || !strings.HasPrefix(link.Path, base.Path) || link.Path == base.Path {
+				continue
+			}
+			visited[link.String()] = true
+
+			rel := strings.TrimPrefix(link.Path, base.Path)
+			if !isSafeRelPath(rel) {
+				continue
+			}
+
+			if strings.HasSuffix(link.Path, "/") {
+				if opt.MaxDepth == 0 || cur.depth+1 <= opt.MaxDepth {
+					queue = append(queue, dir{link, cur.depth + 1})
+				}
+				continue
+			}
+
+			if !matchGlobs(path.Base(rel), opt.Accept, opt.Reject) {
+				continue
+			}
+			entries = append(entries, Entry{URL: link.String(), Path: rel})
+		}
+	}
+	if skipped != nil {
+		return entries, skipped
+	}
+	return entries, nil
+}
+
+// listDirectory return the links of an autoindex page that stay on the same host
+func listDirectory(ctx context.Context, client HTTPClient, u *netUrl.URL) ([]*netUrl.URL, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "" && mediaType != "text/html" {
+		return nil, fmt.Errorf("not a directory listing: %s", mediaType)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxIndexSize))
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*netUrl.URL, 0)
+	for _, m := range hrefPattern.FindAllStringSubmatch(string(body), -1) {
+		href := strings.TrimSpace(m[1] + m[2] + m[3])
+		// skip column sorting links, anchors and non http links
+		if href == "" || strings.HasPrefix(href, "?") || strings.HasPrefix(href, "#") {
+			continue
+		}
+		ref, err := netUrl.Parse(strings.Replace(href, "&amp;", "&", -1))
+		if err != nil {
+			continue
+		}
+		link := u.ResolveReference(ref)
+		if link.Host != u.Host || link.Scheme != u.Scheme {
+			continue
+		}
+		link.RawQuery, link.Fragment = "", ""
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// matchGlobs report whether the name is accepted and not rejected by the glob patterns
+func matchGlobs(name string, accept, reject []string) bool {
+	for _, p := range reject {
+		if ok, _ := path.Match(p, name); ok {
+			return false
+		}
+	}
+	if len(accept) == 0 {
+		return true
+	}
+	for _, p := range accept {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeRelPath report whether the relative path stays inside the destination directory
+func isSafeRelPath(rel string) bool {
+	if rel == "" || strings.HasPrefix(rel, "/") || strings.Contains(rel, "\\") {
+		return false
+	}
+	for _, seg := range strings.Split(strings.TrimSuffix(rel, "/"), "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
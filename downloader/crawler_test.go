@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// autoindexServer serves nginx like directory listings of the pages; /pub/broken/ fails
+func autoindexServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	pages := map[string]string{
+		"/pub/": `<html><head><title>Index of /pub/</title></head><body>
+<a href="?C=N;O=D">Name</a> <a href="#top">top</a>
+<a href="../">../</a>
+<a href="a.txt">a.txt</a>
+<a class="file" href='b.iso'>b.iso</a>
+<A HREF=c.tar.gz>c.tar.gz</A>
+<a href="sub/">sub/</a>
+<a href="sub/">sub/ again</a>
+<a href="/pub/abs.txt">abs.txt</a>
+<a href="/other/outside.txt">outside.txt</a>
+<a href="http://other.example.com/pub/cross.txt">cross.txt</a>
+<a href="..%2Fescape.txt">escape.txt</a>
+<a href="a%5Cb.txt">backslash</a>
+<a href="mailto:admin@example.com">admin</a>
+<a href="broken/">broken/</a>
+<a href="sorted.txt?download=1">sorted.txt</a>
+</body></html>`,
+		"/pub/sub/":      `<a href="../">../</a><a href="c.txt">c.txt</a><a href="deep/">deep/</a><a href="/pub/">root</a>`,
+		"/pub/sub/deep/": `<a href="../">../</a><a href="d.txt">d.txt</a>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func entryPaths(entries []Entry) []string {
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		paths[i] = e.Path
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestCrawl(t *testing.T) {
+	srv := autoindexServer(t)
+
+	tests := []struct {
+		opt  CrawlOptions
+		want []string
+	}{
+		{opt: CrawlOptions{}, want: []string{"a.txt", "abs.txt", "b.iso", "c.tar.gz", "sorted.txt", "sub/c.txt", "sub/deep/d.txt"}},
+		{opt: CrawlOptions{MaxDepth: 1}, want: []string{"a.txt", "abs.txt", "b.iso", "c.tar.gz", "sorted.txt", "sub/c.txt"}},
+		{opt: CrawlOptions{Accept: []string{"*.txt"}, Reject: []string{"a*"}}, want: []string{"sorted.txt", "sub/c.txt", "sub/deep/d.txt"}},
+		{opt: CrawlOptions{Accept: []string{"*.iso", "*.gz"}}, want: []string{"b.iso", "c.tar.gz"}},
+	}
+	for _, tt := range tests {
+		entries, err := Crawl(context.Background(), nil, srv.URL+"/pub", tt.opt)
+		// the failing sub-directory is skipped and reported with the other files
+		var skipped *CrawlError
+		if !errors.As(err, &skipped) || len(skipped.Dirs) != 1 || skipped.Dirs[0] != srv.URL+"/pub/broken/" {
+			t.Fatalf("%+v: error %v, want the broken directory skipped", tt.opt, err)
+		}
+		if got := entryPaths(entries); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%+v: crawled %v, want %v", tt.opt, got, tt.want)
+		}
+		for _, e := range entries {
+			if e.URL != srv.URL+"/pub/"+e.Path {
+				t.Errorf("%s: url %s", e.Path, e.URL)
+			}
+		}
+	}
+
+	// the root listing is required
+	entries, err := Crawl(context.Background(), nil, srv.URL+"/missing/", CrawlOptions{})
+	var skipped *CrawlError
+	if err == nil || errors.As(err, &skipped) || len(entries) != 0 {
+		t.Fatalf("crawled %v, error %v, want the root to fail", entries, err)
+	}
+	if _, err := Crawl(context.Background(), nil, "ftp://example.com/pub/", CrawlOptions{}); err == nil {
+		t.Fatal("expected an error for a ftp url")
+	}
+}
+
+func TestMatchGlobs(t *testing.T) {
+	tests := []struct {
+		name           string
+		accept, reject []string
+		want           bool
+	}{
+		{name: "a.iso", want: true},
+		{name: "a.iso", accept: []string{"*.iso"}, want: true},
+		{name: "a.txt", accept: []string{"*.iso", "*.txt"}, want: true},
+		{name: "a.txt", accept: []string{"*.iso"}, want: false},
+		{name: "a.iso", reject: []string{"*.iso"}, want: false},
+		{name: "a.iso", accept: []string{"*.iso"}, reject: []string{"a.*"}, want: false},
+		{name: "b.iso", accept: []string{"*.iso"}, reject: []string{"a.*"}, want: true},
+		{name: "a.iso", accept: []string{"["}, want: false},
+	}
+	for _, tt := range tests {
+		if got := matchGlobs(tt.name, tt.accept, tt.reject); got != tt.want {
+			t.Errorf("matchGlobs(%q, %v, %v) = %v, want %v", tt.name, tt.accept, tt.reject, got, tt.want)
+		}
+	}
+}
+
+func TestIsSafeRelPath(t *testing.T) {
+	tests := map[string]bool{
+		"a.txt":        true,
+		"sub/a.txt":    true,
+		"sub/deep/":    true,
+		"":             false,
+		"/etc/passwd":  false,
+		"../a.txt":     false,
+		"sub/../a.txt": false,
+		"sub//a.txt":   false,
+		"./a.txt":      false,
+		`sub\a.txt`:    false,
+		"..":           false,
+	}
+	for rel, want := range tests {
+		if got := isSafeRelPath(rel); got != want {
+			t.Errorf("isSafeRelPath(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package downloader
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// dashMPD represents the subset of a DASH media presentation description used for downloading
+	dashMPD struct {
+		XMLName  xml.Name     `xml:"MPD"`
+		Type     string       `xml:"type,attr"`
+		Duration string       `xml:"mediaPresentationDuration,attr"`
+		BaseURL  string       `xml:"BaseURL"`
+		Periods  []dashPeriod `xml:"Period"`
+	}
+
+	dashPeriod struct {
+		Duration       string              `xml:"duration,attr"`
+		BaseURL        string              `xml:"BaseURL"`
+		AdaptationSets []dashAdaptationSet `xml:"AdaptationSet"`
+	}
+
+	dashAdaptationSet struct {
+		MimeType        string               `xml:"mimeType,attr"`
+		ContentType     string               `xml:"contentType,attr"`
+		BaseURL         string               `xml:"BaseURL"`
+		SegmentTemplate *dashSegmentTemplate `xml:"SegmentTemplate"`
+		SegmentList     *dashSegmentList     `xml:"SegmentList"`
+		Representations []dashRepresentation `xml:"Representation"`
+	}
+
+	dashRepresentation struct {
+		ID              string               `xml:"id,attr"`
+		MimeType        string               `xml:"mimeType,attr"`
+		Bandwidth       int                  `xml:"bandwidth,attr"`
+		Width           int                  `xml:"width,attr"`
+		Height          int                  `xml:"height,attr"`
+		BaseURL         string               `xml:"BaseURL"`
+		SegmentTemplate *dashSegmentTemplate `xml:"SegmentTemplate"`
+		SegmentList     *dashSegmentList     `xml:"SegmentList"`
+	}
+
+	dashSegmentTemplate struct {
+		Media          string `xml:"media,attr"`
+		Initialization string `xml:"initialization,attr"`
+		StartNumber    *int64 `xml:"startNumber,attr"`
+		Timescale      int64  `xml:"timescale,attr"`
+		Duration       int64  `xml:"duration,attr"`
+		Timeline       []struct {
+			T *int64 `xml:"t,attr"`
+			D int64  `xml:"d,attr"`
+			R int64  `xml:"r,attr"`
+		} `xml:"SegmentTimeline>S"`
+	}
+
+	dashSegmentList struct {
+		Initialization *struct {
+			SourceURL string `xml:"sourceURL,attr"`
+		} `xml:"Initialization"`
+		SegmentURLs []struct {
+			Media string `xml:"media,attr"`
+		} `xml:"SegmentURL"`
+	}
+)
+
+var dashTemplateIdentifier = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time)(%0\d+d)?\$`)
+
+// isDASHManifest report whether the content is a DASH manifest
+func isDASHManifest(data []byte) bool {
+	return strings.Contains(string(data[:minInt(len(data), 1024)]), "<MPD")
+}
+
+// parseDASH return the variants of the first period and a func to build the playlist of a chosen variant
+func parseDASH(data []byte, base string) ([]streamVariant, func(int) (*streamPlaylist, error), error) {
+	mpd := dashMPD{}
+	if err := xml.Unmarshal(data, &mpd); err != nil {
+		return nil, nil, fmt.Errorf("dl: invalid DASH manifest: %v", err)
+	}
+	if mpd.Type == "dynamic" {
+		return nil, nil, errors.New("dl: live DASH streams are not supported")
+	}
+	if len(mpd.Periods) == 0 {
+		return nil, nil, errors.New("dl: DASH manifest has no period")
+	}
+	period := mpd.Periods[0]
+
+	type candidate struct {
+		set *dashAdaptationSet
+		rep *dashRepresentation
+	}
+	candidates := make([]candidate, 0)
+	for i := range period.AdaptationSets {
+		set := &period.AdaptationSets[i]
+		for j := range set.Representations {
+			candidates = append(candidates, candidate{set: set, rep: &set.Representations[j]})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil, errors.New("dl: DASH manifest has no representation")
+	}
+
+	variants := make([]streamVariant, len(candidates))
+	for i, c := range candidates {
+		variants[i] = streamVariant{bandwidth: c.rep.Bandwidth, kind: dashContentType(c.set, c.rep)}
+		if c.rep.Width > 0 && c.rep.Height > 0 {
+			variants[i].resolution = fmt.Sprintf("%dx%d", c.rep.Width, c.rep.Height)
+		}
+	}
+
+	duration := parseISODuration(period.Duration)
+	if duration == 0 {
+		duration = parseISODuration(mpd.Duration)
+	}
+
+	build := func(i int) (*streamPlaylist, error) {
+		set, rep := candidates[i].set, candidates[i].rep
+		baseURL := base
+		for _, b := range []string{mpd.BaseURL, period.BaseURL, set.BaseURL, rep.BaseURL} {
+			if b = strings.TrimSpace(b); b == "" {
+				continue
+			}
+			u, err := resolveReference(baseURL, b)
+			if err != nil {
+				return nil, err
+			}
+			baseURL = u
+		}
+
+		pl := &streamPlaylist{ext: ".mp4"}
+		if strings.Contains(rep.MimeType+set.MimeType, "mp2t") {
+			pl.ext = ".ts"
+		}
+
+		tmpl := rep.SegmentTemplate
+		if tmpl == nil {
+			tmpl = set.SegmentTemplate
+		}
+		list := rep.SegmentList
+		if list == nil {
+			list = set.SegmentList
+		}
+
+		switch {
+		case tmpl != nil:
+			return dashTemplatePlaylist(pl, tmpl, rep, baseURL, duration)
+		case list != nil:
+			if list.Initialization != nil && list.Initialization.SourceURL != "" {
+				u, err := resolveReference(baseURL, list.Initialization.SourceURL)
+				if err != nil {
+					return nil, err
+				}
+				pl.init = &streamSegment{url: u, length: -1}
+			}
+			for _, s := range list.SegmentURLs {
+				u, err := resolveReference(baseURL, s.Media)
+				if err != nil {
+					return nil, err
+				}
+				pl.segments = append(pl.segments, streamSegment{url: u, length: -1})
+			}
+		default:
+			// a single file addressed by the BaseURL
+			pl.segments = append(pl.segments, streamSegment{url: baseURL, length: -1})
+		}
+
+		if len(pl.segments) == 0 {
+			return nil, errors.New("dl: DASH representation has no segments")
+		}
+		return pl, nil
+	}
+
+	return variants, build, nil
+}
+
+// dashContentType return the content type of the representation e.g: video, audio or text
+func dashContentType(set *dashAdaptationSet, rep *dashRepresentation) string {
+	for _, t := range []string{rep.MimeType, set.MimeType, set.ContentType} {
+		if t != "" {
+			return strings.ToLower(strings.SplitN(t, "/", 2)[0])
+		}
+	}
+	return ""
+}
+
+// selectDASHVariant return the index of the video representation matching the selector; a selector prefixed by audio
+// e.g: audio or audio:lowest chooses among the audio representations, which are stored separately from the video
+func selectDASHVariant(variants []streamVariant, selector string) (int, error) {
+	selector = strings.ToLower(strings.TrimSpace(selector))
+	kinds := []string{"video", "", "audio"} // untyped representations may be muxed, audio only streams are fine too
+	if selector == "audio" || strings.HasPrefix(selector, "audio:") {
+		selector = strings.TrimPrefix(strings.TrimPrefix(selector, "audio"), ":")
+		kinds = []string{"audio"}
+	}
+
+	for _, kind := range kinds {
+		indexes := make([]int, 0, len(variants))
+		filtered := make([]streamVariant, 0, len(variants))
+		for i, v := range variants {
+			if v.kind == kind {
+				indexes = append(indexes, i)
+				filtered = append(filtered, v)
+			}
+		}
+		if len(filtered) == 0 {
+			continue
+		}
+		i, err := selectVariant(filtered, selector)
+		if err != nil {
+			return 0, err
+		}
+		return indexes[i], nil
+	}
+	return 0, fmt.Errorf("dl: DASH manifest has no %s representation", kinds[0])
+}
+
+// dashTemplatePlaylist expand a segment template to the list of segments
+func dashTemplatePlaylist(pl *streamPlaylist, tmpl *dashSegmentTemplate, rep *dashRepresentation, base string, duration float64) (*streamPlaylist, error) {
+	number := int64(1)
+	if tmpl.StartNumber != nil {
+		number = *tmpl.StartNumber
+	}
+	timescale := tmpl.Timescale
+	if timescale == 0 {
+		timescale = 1
+	}
+
+	expand := func(t string, number, time int64) (string, error) {
+		s := dashTemplateIdentifier.ReplaceAllStringFunc(t, func(m string) string {
+			sm := dashTemplateIdentifier.FindStringSubmatch(m)
+			format := "%d"
+			if sm[2] != "" {
+				format = sm[2]
+			}
+			switch sm[1] {
+			case "RepresentationID":
+				return rep.ID
+			case "Number":
+				return fmt.Sprintf(format, number)
+			case "Bandwidth":
+				return fmt.Sprintf(format, rep.Bandwidth)
+			}
+			return fmt.Sprintf(format, time)
+		})
+		return resolveReference(base, strings.Replace(s, "$$", "$", -1))
+	}
+
+	if tmpl.Initialization != "" {
+		u, err := expand(tmpl.Initialization, number, 0)
+		if err != nil {
+			return nil, err
+		}
+		pl.init = &streamSegment{url: u, length: -1}
+	}
+
+	if len(tmpl.Timeline) > 0 {
+		var t int64
+		for _, s := range tmpl.Timeline {
+			if s.T != nil {
+				t = *s.T
+			}
+			repeat := s.R
+			if repeat < 0 {
+				// repeat until the end of the period
+				repeat = int64(math.Ceil((duration*float64(timescale)-float64(t))/float64(s.D))) - 1
+			}
+			for r := int64(0); r <= repeat; r++ {
+				u, err := expand(tmpl.Media, number, t)
+				if err != nil {
+					return nil, err
+				}
+				pl.segments = append(pl.segments, streamSegment{url: u, length: -1})
+				number++
+				t += s.D
+			}
+		}
+		return pl, nil
+	}
+
+	if tmpl.Duration == 0 || duration == 0 {
+		return nil, errors.New("dl: DASH segment template without timeline requires a duration")
+	}
+	count := int64(math.Ceil(duration * float64(timescale) / float64(tmpl.Duration)))
+	for i := int64(0); i < count; i++ {
+		u, err := expand(tmpl.Media, number+i, i*tmpl.Duration)
+		if err != nil {
+			return nil, err
+		}
+		pl.segments = append(pl.segments, streamSegment{url: u, length: -1})
+	}
+	return pl, nil
+}
+
+// parseISODuration convert an ISO 8601 duration like PT1H2M3.5S to seconds
+func parseISODuration(s string) float64 {
+	m := regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`).FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0
+	}
+	seconds := 0.0
+	for i, unit := range []float64{86400, 3600, 60, 1} {
+		if v, err := strconv.ParseFloat(m[i+1], 64); err == nil {
+			seconds += v * unit
+		}
+	}
+	return seconds
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/thedevsaddam/dl/extract"
+)
+
+// decodeContent decode the file of a server sending gzip despite the identity encoding asked for; the chunks are
+// ranges of the encoded content, so it's decoded once the whole file is downloaded
+func (d *DownloadManager) decodeContent() error {
+	enc := strings.ToLower(d.header.Get("Content-Encoding"))
+	if d.torrent != nil || d.playlist != nil || (enc != "gzip" && enc != "x-gzip") {
+		return nil
+	}
+	d.option.log.Info("decoding content", "path", d.location, "encoding", enc)
+
+	in, err := os.Open(d.location)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("dl: failed to decode %s content: %v", enc, err)
+	}
+	tmp := d.location + ".dl-tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("dl: failed to decode %s content: %v", enc, err)
+	}
+	if err := os.Rename(tmp, d.location); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&d.fileSize, uint64(n))
+	atomic.StoreUint64(&d.totalDownloaded, uint64(n))
+	return nil
+}
+
+// decompress replace the downloaded .gz, .bz2, .xz or .zst file by its decompressed content, routed by
+// the decompressed name e.g: to the sub-directory of the inner extension; the digest stays the one of the downloaded file
+func (d *DownloadManager) decompress(url string, res *Result) error {
+	if !d.option.decompress || res.Digest == "" {
+		return nil
+	}
+	name, ok := extract.Decompressed(filepath.Base(res.Path))
+	if !ok {
+		return nil
+	}
+
+	dst := filepath.Join(filepath.Dir(res.Path), name)
+	if d.option.path != "" && !d.option.skipSubPathMap && d.option.resumeLocation == "" {
+		rel, err := d.destination(url, name)
+		if err != nil {
+			return err
+		}
+		dir, err := d.makeSubDir(filepath.Dir(rel))
+		if err != nil {
+			return err
+		}
+		dst = filepath.Join(dir, filepath.Base(rel))
+	}
+
+	d.option.log.Info("decompressing file", "path", res.Path, "to", dst)
+	n, err := extract.Decompress(res.Path, dst)
+	if err != nil {
+		d.option.log.Error("failed to decompress file", "path", res.Path, "error", err)
+		return err
+	}
+	if err := os.Remove(res.Path); err != nil {
+		d.option.log.Warn("failed to remove compressed file", "path", res.Path, "error", err)
+	}
+
+	d.mu.Lock()
+	d.location = dst
+	d.mu.Unlock()
+	res.Path = dst
+	res.Size = uint64(n)
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package downloader
+
+import "errors"
+
+// diskFree isn't supported; the space isn't checked
+func diskFree(dir string) (uint64, error) {
+	return 0, errors.New("dl: free disk space not supported")
+}
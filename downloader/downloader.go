@@ -2,23 +2,21 @@ package downloader
 
 import (
 	"context"
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"os"
-	"os/signal"
 	"path"
 	"path/filepath"
-	"strconv"
 	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 
-	"github.com/briandowns/spinner"
-	"github.com/schollz/progressbar/v3"
 	"github.com/thedevsaddam/dl/logger"
-	"github.com/thedevsaddam/retry"
+	"github.com/thedevsaddam/dl/torrent"
 )
 
 const (
@@ -27,22 +25,36 @@ const (
 
 // DownloadManager ...
 type DownloadManager struct {
-	option option
-	client HTTPClient
-
-	stop      chan os.Signal
-	completed chan bool
-
-	fileName            string // filename with extension
-	fileSize            uint64 // file size in bytes
-	totalDownloaded     uint64 // total file downloaded in bytes
-	totalChunkCompleted int32  // total completed chunks
-	location            string // where the file stored
-
-	totalTimeTaken time.Duration // total time taken to complete downloading
+	option  option
+	client  HTTPClient
+	fetcher fetcher // fetcher of the url scheme
+
+	state               int32       // State of the download
+	fileName            string      // filename with extension
+	fileSize            uint64      // file size in bytes
+	totalDownloaded     uint64      // total file downloaded in bytes
+	totalChunkCompleted int32       // total completed chunks
+	totalChunks         int         // total number of chunks or stream segments
+	connections         int32       // open connections of the chunks or stream segments
+	retries             int32       // failed attempts tried again
+	location            string      // where the file stored
+	header              http.Header // response header of the meta request
+	mimeType            string      // media type of the Content-Type header or sniffed from the first bytes
+	chunks              []Chunk     // byte ranges of a regular download
+	file                *os.File    // shared by the chunks of a regular download while downloading
+	buffers             *bufferPool // write buffers of the chunks
+
+	verify func() error // verify the downloaded file; nil means nothing to verify
+
+	playlist   *streamPlaylist // segments of a HLS/DASH stream; nil for regular downloads
+	segmentDir string          // temporary directory holding the stream segments
+
+	torrent *torrent.Torrent // torrent download; nil for regular downloads
 
 	errors []error // contains all the errors
 
+	emitMu sync.Mutex // the progress handlers are called one at a time
+
 	mu *sync.Mutex
 	wg *sync.WaitGroup
 }
@@ -55,19 +67,17 @@ func New(options ...OptionFunc) *DownloadManager {
 		client: http.DefaultClient,
 
 		errors: make([]error, 0),
-
-		stop:      make(chan os.Signal, 1),
-		completed: make(chan bool, 1),
 	}
 
 	// set default options
 	dm.option.concurrency = defaultConcurrency
+	dm.option.bufferSize = defaultBufferSize
 	dm.option.log = logger.New(dm.option.verbose) // enable verbose for applying options
 
 	// apply user provided options
 	for _, option := range options {
 		if err := option(dm); err != nil {
-			fmt.Println("Error:", err)
+			dm.option.log.Error("invalid option", "error", err)
 		}
 	}
 
@@ -88,256 +98,546 @@ func (d *DownloadManager) addError(e error) {
 
 // Errors return the error bag
 func (d *DownloadManager) Errors() []error {
-	return d.errors
-}
-
-// renderProgressBar paint & repaint progressbar
-func (d *DownloadManager) renderProgressBar(ctx context.Context, maxSize int) {
-	pb := progressbar.NewOptions(maxSize,
-		progressbar.OptionSetDescription(fmt.Sprintf("[red][0/%d][reset] Downloading:", d.option.concurrency)),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionSetPredictTime(true),
-		progressbar.OptionShowCount(),
-		progressbar.OptionThrottle(500*time.Millisecond),
-		progressbar.OptionOnCompletion(
-			func() {
-				fmt.Printf("\nFile name: %s\n", d.fileName)
-				fmt.Printf("File size: %s\n", humanaReadableBytes(float64(d.fileSize)))
-				fmt.Printf("Time elapsed: %s\n", d.totalTimeTaken)
-				fmt.Printf("Location: %s\n", d.location)
-			},
-		),
-	)
-
-	ticker := time.NewTicker(500 * time.Millisecond)
-
-	go func() {
-		for {
-			select {
-			case <-d.stop:
-				ticker.Stop()
-				return
-			case <-d.completed:
-				ticker.Stop()
-				d.option.log.Println("Download completed!")
-				return
-			case <-ticker.C:
-				pb.Describe(fmt.Sprintf("[cyan][%d/%d][reset] Downloading:", d.totalChunkCompleted, d.option.concurrency))
-				pb.Set64(int64(d.totalDownloaded))
-			}
-		}
-	}()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]error(nil), d.errors...)
 }
 
 // GetFileName return file name; the value will be available once the download start
 func (d *DownloadManager) GetFileName() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.fileName
 }
 
+// GetLocation return where the file is stored; the value will be available once the download start
+func (d *DownloadManager) GetLocation() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.location
+}
+
+// GetProgress return the downloaded bytes and the file size; it's safe to call while downloading
+func (d *DownloadManager) GetProgress() (downloaded, size uint64) {
+	return atomic.LoadUint64(&d.totalDownloaded), atomic.LoadUint64(&d.fileSize)
+}
+
 // GetFileSize return file size in human readable format; the value will be available once the download start
 func (d *DownloadManager) GetFileSize() string {
-	return humanaReadableBytes(float64(d.fileSize))
+	return humanaReadableBytes(float64(atomic.LoadUint64(&d.fileSize)))
 }
 
-// populateFileInfo do a HTTP/HEAD request and gather meta information; MUST call before download
+// populateFileInfo fetch the resource's meta information through the fetcher of the url scheme; MUST call before download
 func (d *DownloadManager) populateFileInfo(ctx context.Context, url string) error {
 	if d.fileName == "" {
-		d.fileName = path.Base(url)
+		d.setFileName(fileNameFromURL(url))
 	}
 
-	d.option.log.Printf("Info: fetching file's meta information: %s\n", url)
+	d.option.log.Info("fetching meta information", "url", url)
 	retryCount := 0
 
-	err := retry.DoFunc(20, 200*time.Millisecond, func() error {
+	// local resources will not heal by retrying
+	attempts := uint(20)
+	if _, remote := d.fetcher.(httpFetcher); !remote {
+		attempts = 1
+	}
+
+	err := retryContext(ctx, attempts, 200*time.Millisecond, func() error {
 		ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 		defer cancel()
 
 		if retryCount > 0 {
-			d.option.log.Printf("Info: retrying to fetch file's meta information [%d]", retryCount)
+			d.option.log.Warn("retrying to fetch meta information", "url", url, "attempt", retryCount)
+			atomic.AddInt32(&d.retries, 1)
 		}
 		retryCount++
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		size, header, err := d.fetcher.meta(ctx, url)
 		if err != nil {
-			d.option.log.Printf("Error: failed to create HTTP/HEAD request: %s\n", err.Error())
+			d.option.log.Warn("failed to fetch meta information", "url", url, "error", err)
 			return err
 		}
 
-		resp, err := d.client.Do(req)
-		if err != nil {
-			d.option.log.Printf("Error: failed to perform HTTP/HEAD request: %s\n", err.Error())
-			return err
-		}
+		atomic.StoreUint64(&d.fileSize, uint64(size))
+		d.header = header
 
-		d.fileSize = uint64(resp.ContentLength)
-
-		return resp.Body.Close()
+		return nil
 	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	return err
 }
 
-// downloadChunk download single chunk from the range
-func (d *DownloadManager) downloadChunk(ctx context.Context, url string, min, max, chunkNo int, errCh chan error) {
-	defer d.wg.Done()
+// retryContext call fn until it succeeds like retry.DoFunc; a cancelled context stops waiting for the next attempt
+// and the permanent errors e.g: 404 Not Found aren't retried
+func retryContext(ctx context.Context, attempts uint, sleep time.Duration, fn func() error) error {
+	for {
+		err := fn()
+		if attempts--; err == nil || attempts == 0 || permanent(err) {
+			return err
+		}
+		sleep += time.Duration(rand.Int63n(int64(sleep))) / 2 // jitter
+		t := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		sleep *= 2
+	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		d.option.log.Printf("Error[%d]: failed to create HTTP/GET request: %s\n", chunkNo, err.Error())
-		errCh <- err
-		return
+// resumable report whether the previous download can be continued from the chunk progress
+func (d *DownloadManager) resumable() bool {
+	if d.option.resumeLocation == "" {
+		return false
+	}
+	if d.fileSize == ^uint64(0) {
+		d.option.log.Warn("size is unknown, the previous download can't be continued", "path", d.option.resumeLocation)
+		return false
 	}
+	if !validChunks(d.option.resumeChunks, d.fileSize) {
+		d.option.log.Warn("file has changed since the previous download, starting over", "path", d.option.resumeLocation)
+		return false
+	}
+	_, err := os.Stat(d.option.resumeLocation)
+	return err == nil
+}
 
-	rangeHeader := "bytes=" + strconv.Itoa(min) + "-" + strconv.Itoa(max-1)
-	req.Header.Add("Range", rangeHeader)
-	resp, err := d.client.Do(req)
+// downloadChunk download single chunk from the range; a failed chunk is tried again from its written bytes
+func (d *DownloadManager) downloadChunk(ctx context.Context, url string, chunkNo int, errCh chan error) {
+	defer d.wg.Done()
+
+	chunk := &d.chunks[chunkNo]
+	log := d.option.log.With("chunk", chunkNo)
+	log.Debug("chunk started", "start", chunk.Start+atomic.LoadUint64(&chunk.Done), "end", chunk.End)
+	d.emit(Event{Type: ChunkStarted, Chunk: chunkNo, Bytes: atomic.LoadUint64(&chunk.Done)})
+
+	var lastErr error
+	err := retryContext(ctx, 3, 200*time.Millisecond, func() error {
+		if lastErr != nil {
+			log.Warn("retrying chunk", "bytes", atomic.LoadUint64(&chunk.Done), "error", lastErr)
+			atomic.AddInt32(&d.retries, 1)
+			d.emit(Event{Type: ChunkRetried, Chunk: chunkNo, Err: lastErr})
+		}
+		lastErr = d.fetchChunk(ctx, url, chunk)
+		return lastErr
+	})
 	if err != nil {
-		d.option.log.Printf("Error[%d]: failed to perform HTTP/GET request: %s\n", chunkNo, err.Error())
+		log.Error("failed to download chunk", "bytes", atomic.LoadUint64(&chunk.Done), "error", err)
 		errCh <- err
 		return
 	}
-	defer resp.Body.Close()
 
-	f, err := os.OpenFile(d.location, os.O_RDWR, 0644)
-	if err != nil {
-		d.option.log.Printf("Error[%d]: failed to open file: %s\n", chunkNo, err.Error())
-		errCh <- err
-		return
+	atomic.AddInt32(&d.totalChunkCompleted, 1)
+	log.Debug("chunk done", "bytes", atomic.LoadUint64(&chunk.Done))
+	d.emit(Event{Type: ChunkDone, Chunk: chunkNo, Bytes: atomic.LoadUint64(&chunk.Done)})
+}
+
+// fetchChunk write the range of the chunk after its written bytes to the file; the chunk of a file of unknown
+// size can't be continued, it's fetched from the start again
+func (d *DownloadManager) fetchChunk(ctx context.Context, url string, chunk *Chunk) error {
+	unknown := chunk.End == ^uint64(0)
+	max := int64(-1) // read until the end
+	if unknown {
+		if done := atomic.SwapUint64(&chunk.Done, 0); done > 0 {
+			atomic.AddUint64(&d.totalDownloaded, ^(done - 1))
+		}
+	} else {
+		max = int64(chunk.End)
 	}
-	defer f.Close()
+	min := chunk.Start + atomic.LoadUint64(&chunk.Done) // continue after the written bytes
 
-	_, err = f.Seek(int64(min), 0)
+	body, err := d.fetcher.fetch(ctx, url, int64(min), max)
 	if err != nil {
-		d.option.log.Printf("Error[%d]: failed to seek file: %s\n", chunkNo, err.Error())
-		errCh <- err
-		return
+		return err
+	}
+	defer body.Close()
+	atomic.AddInt32(&d.connections, 1)
+	defer atomic.AddInt32(&d.connections, -1)
+
+	buf := d.buffers.get()
+	defer d.buffers.put(buf)
+	w := &chunkWriter{f: d.file, off: int64(min), buf: *buf, done: &chunk.Done}
+
+	_, err = io.Copy(w, Reader{body, &d.totalDownloaded})
+	// the bytes received before a failure are kept, a resumed chunk doesn't fetch them again
+	if ferr := w.flush(); err == nil {
+		err = ferr
 	}
-
-	_, err = io.Copy(f, Reader{resp.Body, &d.totalDownloaded})
 	if err != nil {
-		d.option.log.Printf("Error[%d]: failed to copy file content: %s\n", chunkNo, err.Error())
-		errCh <- err
-		return
+		return err
 	}
 
-	atomic.AddInt32(&d.totalChunkCompleted, 1)
+	if unknown {
+		d.mu.Lock()
+		chunk.End = chunk.Start + atomic.LoadUint64(&chunk.Done)
+		d.mu.Unlock()
+		atomic.StoreUint64(&d.fileSize, chunk.End)
+	} else if atomic.LoadUint64(&chunk.Done) < chunk.End-chunk.Start {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
 }
 
-// Download download files based on configurations
+// Download download files based on configurations; the errors are available through Errors
+//
+// Deprecated: use DownloadContext
 func (d *DownloadManager) Download(url string) *DownloadManager {
-	signal.Notify(d.stop, syscall.SIGKILL, syscall.SIGINT, syscall.SIGQUIT)
-	go func() {
-		for range d.stop {
-			d.option.log.Println("Operation cancelled!")
-			fmt.Printf("\nOperation cancelled!\n")
-			// make cursor visible if interruption happened while fetching meta
-			io.WriteString(os.Stdout, "\033[?25h")
-			os.Exit(1)
-		}
-	}()
+	d.DownloadContext(context.Background(), url)
+	return d
+}
 
+// DownloadContext download the url; it doesn't print anything and stops once the context is cancelled
+func (d *DownloadManager) DownloadContext(ctx context.Context, url string) (*Result, error) {
 	startedAt := time.Now()
-	fmt.Println()
+	d.setState(StateFetchingMeta)
+	defer d.setState(StateDone)
+
+	if isS3URL(url) {
+		u, c, err := resolveS3URL(url, d.option.s3, d.client)
+		if err != nil {
+			d.option.log.Error("failed to resolve s3 url", "url", url, "error", err)
+			return nil, d.fail(err)
+		}
+		d.option.log.Info("resolved s3 url", "url", url, "resolved", u)
+		if d.fileName == "" {
+			d.setFileName(path.Base(url))
+		}
+		d.client = c
+		d.verify = func() error {
+			return verifyS3Object(d.location, d.fileSize, d.header)
+		}
+		url = u
+	}
+
+	if !isTorrentURL(url) {
+		f, err := newFetcher(url, d.client)
+		if err != nil {
+			d.option.log.Error("failed to open url", "url", url, "error", err)
+			return nil, d.fail(err)
+		}
+		d.fetcher = f
+	}
 
-	ctx := context.Background()
+	caller := ctx // cancelled by the caller only
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	s := spinner.New(spinner.CharSets[70], 100*time.Millisecond, spinner.WithHiddenCursor(true)) // code:39 is earth for the lib
-	s.Prefix = "Fetching file's meta information ( "
-	s.Suffix = ")"
-	s.Start()
-	if err := d.populateFileInfo(ctx, url); err != nil {
-		d.addError(err)
-		cancel()
-		return d
+	if isTorrentURL(url) {
+		if err := d.resolveTorrent(ctx, url); err != nil {
+			d.option.log.Error("failed to resolve torrent", "url", url, "error", err)
+			return nil, d.fail(err)
+		}
+	} else if err := d.populateFileInfo(ctx, url); err != nil {
+		d.option.log.Error("failed to fetch meta information", "url", url, "error", err)
+		return nil, d.fail(err)
+	}
+
+	if isStreamURL(url, d.header) {
+		pl, err := d.resolveStream(ctx, url)
+		if err != nil {
+			d.option.log.Error("failed to resolve stream", "url", url, "error", err)
+			return nil, d.fail(err)
+		}
+		d.mu.Lock()
+		d.playlist = pl
+		d.mu.Unlock()
+		if d.fileName == "" || d.fileName == fileNameFromURL(url) {
+			d.setFileName(streamFileName(url, pl.ext))
+		}
+		d.option.log.Info("resolved stream", "url", url, "segments", len(pl.segments))
+	}
+
+	if d.torrent == nil && d.playlist == nil {
+		d.detectType(ctx, url)
 	}
-	s.Stop()
-	fmt.Println()
 
-	chunkLen := int(d.fileSize) / d.option.concurrency
-	rem := int(d.fileSize) % d.option.concurrency
+	if d.option.output != nil {
+		return d.streamOutput(ctx, caller, url, startedAt)
+	}
 
 	fileName := d.fileName
 	if d.option.path != "" {
-		d.option.log.Printf("Info: Root directory: %s\n", d.option.path)
+		d.option.log.Debug("root directory", "path", d.option.path)
 
 		fileName = filepath.Join(d.option.path, d.fileName)
 
 		if !d.option.skipSubPathMap {
-			subPath := "other"
-			if sp := d.option.subPathMap.Get(filepath.Ext(d.fileName)); sp != "" {
-				subPath = sp
+			dst, err := d.destination(url, d.fileName)
+			if err != nil {
+				d.option.log.Error("failed to route file", "name", d.fileName, "error", err)
+				return nil, d.fail(err)
 			}
-
-			makeSubDir := filepath.Join(d.option.path, subPath)
-			if _, err := os.Stat(makeSubDir); os.IsNotExist(err) {
-				if err := os.MkdirAll(makeSubDir, os.ModePerm); err != nil {
-					d.option.log.Printf("Error: failed to create sub-directory: %s\n", err.Error())
-					d.addError(err)
-					cancel()
-					return d
-				}
-				d.option.log.Printf("Info: Created sub-directory: %s\n", subPath)
+			dir, err := d.makeSubDir(filepath.Dir(dst))
+			if err != nil {
+				return nil, d.fail(err)
+			}
+			fileName = filepath.Join(dir, filepath.Base(dst))
+		} else if d.option.resumeLocation == "" {
+			// e.g: the dir of a JSON-RPC client inside the download root
+			if _, err := d.makeSubDir(""); err != nil {
+				return nil, d.fail(err)
 			}
-
-			fileName = filepath.Join(d.option.path, subPath, d.fileName)
 		}
 	}
+	if d.option.resumeLocation != "" {
+		fileName = d.option.resumeLocation
+	}
+	d.mu.Lock()
 	d.location = fileName // set location value
+	d.mu.Unlock()
 
-	if _, err := os.Create(fileName); err != nil {
-		d.option.log.Printf("Error: failed to create file: %s\n", err.Error())
-		d.addError(err)
-		cancel()
-		return d
+	if res := d.fromCache(url, startedAt); res != nil {
+		if err := d.decompress(url, res); err != nil {
+			return nil, d.fail(err)
+		}
+		d.emit(Event{Type: Completed, Chunk: -1, Result: res})
+		return res, nil
 	}
-	d.option.log.Printf("Info: Created file: %s\n", fileName)
 
-	d.option.log.Printf("Downloading file with concurrency value: %d\n", d.option.concurrency)
+	if d.torrent == nil && d.playlist == nil {
+		if err := d.checkSpace(); err != nil {
+			d.option.log.Error("failed to check disk space", "path", fileName, "error", err)
+			return nil, d.fail(err)
+		}
+	}
+
+	if d.torrent == nil && d.playlist == nil && d.resumable() {
+		d.mu.Lock()
+		d.chunks = d.option.resumeChunks
+		d.mu.Unlock()
+		d.option.log.Info("resuming file", "path", fileName)
+	} else if d.torrent == nil {
+		// replace a hard linked file e.g: served from the cache, rather than truncating the shared content
+		os.Remove(fileName)
+		f, err := os.Create(fileName)
+		if err != nil {
+			d.option.log.Error("failed to create file", "path", fileName, "error", err)
+			return nil, d.fail(err)
+		}
+		if d.playlist == nil {
+			err = d.preallocate(f)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			d.option.log.Error("failed to create file", "path", fileName, "error", err)
+			os.Remove(fileName)
+			return nil, d.fail(err)
+		}
+		d.option.log.Info("created file", "path", fileName)
+	}
+
+	d.option.log.Info("downloading", "url", url, "size", atomic.LoadUint64(&d.fileSize), "concurrency", d.option.concurrency)
 
 	errsCh := make(chan error, d.option.concurrency)
-	defer close(errsCh)
+	errsRead := make(chan struct{})
 
 	// read errors
 	go func() {
-		for {
-			if err := <-errsCh; err != nil {
-				d.addError(err)
-				if err != context.Canceled {
-					cancel()
-				}
+		defer close(errsRead)
+		for err := range errsCh {
+			d.addError(err)
+			if err != context.Canceled {
+				cancel()
 			}
 		}
 	}()
 
-	for i := 0; i < d.option.concurrency; i++ {
-		d.wg.Add(1)
-		min := chunkLen * i
-		max := chunkLen * (i + 1)
-		if i == d.option.concurrency-1 {
-			max += rem
+	if d.playlist != nil {
+		dir, err := ioutil.TempDir(filepath.Dir(fileName), ".dl-segments-")
+		if err != nil {
+			d.option.log.Error("failed to create segment directory", "error", err)
+			close(errsCh)
+			return nil, d.fail(err)
 		}
-		go d.downloadChunk(ctx, url, min, max, i, errsCh)
-	}
-
-	// run async task for refreshing progressbar
-	// if file size is unknown then use infinite progress bar
-	if d.fileSize > 0 {
-		d.renderProgressBar(ctx, int(d.fileSize))
+		d.segmentDir = dir
+		total := len(d.playlist.segments)
+		if d.playlist.init != nil {
+			total++
+		}
+		d.setTotalChunks(total)
+		d.startChunks()
+		d.downloadSegments(ctx, errsCh)
+	} else if d.torrent != nil {
+		d.startChunks()
+		d.downloadTorrent(ctx, errsCh)
 	} else {
-		d.renderProgressBar(ctx, -1)
+		if err := d.planDownload(ctx, url); err != nil {
+			d.option.log.Error("failed to plan chunks", "url", url, "error", err)
+			close(errsCh)
+			return nil, d.fail(err)
+		}
+		// the chunks write at their offsets of a single handle
+		f, err := os.OpenFile(fileName, os.O_RDWR, 0644)
+		if err != nil {
+			d.option.log.Error("failed to open file", "path", fileName, "error", err)
+			close(errsCh)
+			return nil, d.fail(err)
+		}
+		d.file = f
+		d.buffers = newBufferPool(d.option.bufferSize)
+
+		for _, c := range d.chunks {
+			atomic.AddUint64(&d.totalDownloaded, c.Done)
+			if c.complete() {
+				atomic.AddInt32(&d.totalChunkCompleted, 1)
+			}
+		}
+		d.setTotalChunks(len(d.chunks))
+		d.startChunks()
+		for i, c := range d.chunks {
+			if c.complete() {
+				continue
+			}
+			d.wg.Add(1)
+			go d.downloadChunk(ctx, url, i, errsCh)
+		}
 	}
+
+	stopReport := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		d.reportProgress(stopReport)
+	}()
+
 	d.wg.Wait()
-	d.totalTimeTaken = time.Since(startedAt)
-	time.Sleep(600 * time.Millisecond)
-	fmt.Println()
-	d.completed <- true
+	close(stopReport)
+	<-reported
+	close(errsCh)
+	<-errsRead // every error is in the error bag
+	if d.file != nil {
+		if err := d.closeFile(); err != nil {
+			d.option.log.Error("failed to close file", "path", fileName, "error", err)
+			d.addError(err)
+		}
+	}
+	if d.playlist != nil {
+		if len(d.Errors()) == 0 {
+			if err := d.mergeSegments(); err != nil {
+				d.option.log.Error("failed to concatenate segments", "path", d.location, "error", err)
+				d.addError(err)
+			}
+		} else {
+			os.RemoveAll(d.segmentDir)
+		}
+	}
+	if d.verify != nil && len(d.Errors()) == 0 {
+		d.option.log.Info("verifying downloaded file", "path", fileName)
+		if err := d.verify(); err != nil {
+			d.option.log.Error("failed to verify file", "path", fileName, "error", err)
+			d.addError(err)
+		}
+	}
+	if len(d.Errors()) == 0 {
+		if err := d.decodeContent(); err != nil {
+			d.option.log.Error("failed to decode content", "path", fileName, "error", err)
+			d.addError(err)
+		}
+	}
+	if err := d.err(caller); err != nil {
+		if d.torrent != nil {
+			d.torrent.Close()
+		}
+		d.option.log.Error("download failed", "url", url, "error", err)
+		d.emit(Event{Type: Failed, Chunk: -1, Err: err})
+		return nil, err
+	}
+
+	res := &Result{
+		Path:     fileName,
+		Size:     atomic.LoadUint64(&d.fileSize),
+		Duration: time.Since(startedAt),
+	}
+	// multi-file torrents are stored in a directory
+	if fi, err := os.Stat(fileName); err == nil && fi.Mode().IsRegular() {
+		sum, err := fileDigest(fileName, sha256.New())
+		if err != nil {
+			return nil, d.fail(err)
+		}
+		res.Digest = hex.EncodeToString(sum)
+	}
 
-	close(d.completed)
-	close(d.stop)
+	d.toCache(url, res)
+	if err := d.decompress(url, res); err != nil {
+		return nil, d.fail(err)
+	}
 
-	return d
+	if d.torrent != nil && d.option.seedRatio > 0 {
+		d.setState(StateSeeding)
+	}
+	d.option.log.Info("download completed", "path", res.Path, "size", res.Size, "duration", res.Duration, "digest", res.Digest)
+	d.emit(Event{Type: Completed, Chunk: -1, Result: res})
+
+	if d.torrent != nil {
+		if err := d.torrent.Seed(ctx); err != nil {
+			d.addError(err)
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+// closeFile flush the file to the disk if asked and close it
+func (d *DownloadManager) closeFile() error {
+	var err error
+	if d.option.fsync {
+		d.option.log.Debug("syncing file", "path", d.file.Name())
+		err = d.file.Sync()
+	}
+	if cerr := d.file.Close(); err == nil {
+		err = cerr
+	}
+	d.file = nil
+	return err
+}
+
+// makeSubDir create the sub-directory of the root directory if it doesn't exist
+func (d *DownloadManager) makeSubDir(subPath string) (string, error) {
+	dir := filepath.Join(d.option.path, subPath)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			d.option.log.Error("failed to create sub-directory", "path", dir, "error", err)
+			return "", err
+		}
+		d.option.log.Info("created sub-directory", "path", dir)
+	}
+	return dir, nil
+}
+
+// fail add the error to the error bag, report it and return it
+func (d *DownloadManager) fail(err error) error {
+	d.addError(err)
+	d.emit(Event{Type: Failed, Chunk: -1, Err: err})
+	return err
+}
+
+// startChunks mark the meta information as fetched; the chunks are about to start
+func (d *DownloadManager) startChunks() {
+	d.setState(StateDownloading)
+	d.emit(Event{Type: MetaFetched, Chunk: -1})
+}
+
+// err return the error which stopped the download; the cancellation of the caller wins over the chunk errors
+func (d *DownloadManager) err(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	errs := d.Errors()
+	for _, err := range errs {
+		if err != context.Canceled {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
 }
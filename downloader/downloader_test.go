@@ -0,0 +1,212 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testContent return the same pseudo random content of a few MB, large enough to be split in chunks
+func testContent() []byte {
+	b := make([]byte, 3<<20+123)
+	rand.New(rand.NewSource(1)).Read(b)
+	return b
+}
+
+// testDownload download the url to a temporary directory and compare the file with the content
+func testDownload(t *testing.T, url string, content []byte, options ...OptionFunc) *Result {
+	t.Helper()
+	dir := t.TempDir()
+	options = append([]OptionFunc{WithFilePath(dir), WithSkipSubPathMap(), WithFilename("file.bin")}, options...)
+	res, err := New(options...).DownloadContext(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "file.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, content) {
+		t.Fatalf("downloaded %d bytes differ from the content of %d bytes", len(b), len(content))
+	}
+	sum := sha256.Sum256(content)
+	if res.Size != uint64(len(content)) || res.Digest != hex.EncodeToString(sum[:]) {
+		t.Fatalf("result size %d digest %s, want %d %x", res.Size, res.Digest, len(content), sum)
+	}
+	return res
+}
+
+func TestDownloadUnknownSize(t *testing.T) {
+	content := testContent()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			t.Errorf("unexpected range %q for a resource of unknown size", r.Header.Get("Range"))
+		}
+		w.(http.Flusher).Flush() // chunked transfer encoding, no Content-Length
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	}))
+	defer srv.Close()
+
+	testDownload(t, srv.URL+"/file.bin", content, WithConcurrency(4))
+}
+
+func TestDownloadChunkRetried(t *testing.T) {
+	content := testContent()
+	var mu sync.Mutex
+	dropped := make(map[int]bool) // by the end of the chunk
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the connection of every chunk is lost halfway once, the retry continues from there
+		var min, max int
+		rg := r.Header.Get("Range")
+		fmt.Sscanf(rg, "bytes=%d-%d", &min, &max)
+		mu.Lock()
+		drop := r.Method == http.MethodGet && max > 0 && !dropped[max]
+		dropped[max] = true
+		mu.Unlock()
+		if drop {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", min, max, len(content)))
+			w.Header().Set("Content-Length", strconv.Itoa(max-min+1))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[min : min+(max-min)/2])
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	var retried int32
+	res := testDownload(t, srv.URL+"/file.bin", content, WithConcurrency(4), WithProgressHandler(func(e Event) {
+		if e.Type == ChunkRetried {
+			atomic.AddInt32(&retried, 1)
+		}
+	}))
+	if retried != 4 {
+		t.Fatalf("%d chunks retried, want 4: %+v", retried, res)
+	}
+}
+
+func TestDownloadOutput(t *testing.T) {
+	content := testContent()
+	for _, unknown := range []bool{false, true} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if unknown {
+				w.(http.Flusher).Flush()
+				if r.Method == http.MethodGet {
+					w.Write(content)
+				}
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+		}))
+
+		var out bytes.Buffer
+		res, err := New(WithOutput(&out), WithConcurrency(3)).DownloadContext(context.Background(), srv.URL+"/file.bin")
+		srv.Close()
+		if err != nil {
+			t.Fatalf("unknown size %v: %v", unknown, err)
+		}
+		if !bytes.Equal(out.Bytes(), content) || res.Size != uint64(len(content)) || res.Path != "" {
+			t.Fatalf("unknown size %v: wrote %d bytes, result %+v", unknown, out.Len(), res)
+		}
+	}
+}
+
+func TestDownloadGzipEncoded(t *testing.T) {
+	content := testContent()
+	var encoded bytes.Buffer
+	zw := gzip.NewWriter(&encoded)
+	zw.Write(content)
+	zw.Close()
+	// the server sends gzip despite the identity encoding asked for
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(encoded.Bytes()))
+	}))
+	defer srv.Close()
+
+	file := testDownload(t, srv.URL+"/file.bin", content, WithConcurrency(4))
+
+	var out bytes.Buffer
+	res, err := New(WithOutput(&out), WithConcurrency(4)).DownloadContext(context.Background(), srv.URL+"/file.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), content) {
+		t.Fatalf("wrote %d bytes differing from the content of %d bytes", out.Len(), len(content))
+	}
+	if res.Size != file.Size || res.Digest != file.Digest {
+		t.Fatalf("output size %d digest %s, file %d %s", res.Size, res.Digest, file.Size, file.Digest)
+	}
+}
+
+func TestDownloadRangeIgnored(t *testing.T) {
+	content := testContent()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	}))
+	defer srv.Close()
+
+	testDownload(t, srv.URL+"/file.bin", content, WithConcurrency(4))
+
+	var out bytes.Buffer
+	if _, err := New(WithOutput(&out), WithConcurrency(4)).DownloadContext(context.Background(), srv.URL+"/file.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), content) {
+		t.Fatalf("wrote %d bytes differing from the content of %d bytes", out.Len(), len(content))
+	}
+}
+
+func TestDownloadBadResponse(t *testing.T) {
+	content := testContent()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/missing.bin":
+			http.NotFound(w, r)
+		case r.URL.Path == "/gone.bin" && r.Method == http.MethodGet:
+			http.Error(w, "gone", http.StatusGone)
+		case r.URL.Path == "/shifted.bin" && r.Header.Get("Range") != "":
+			// the range of another chunk
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 1-1/%d", len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[1:2])
+		default:
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+		}
+	}))
+	defer srv.Close()
+
+	for path, want := range map[string]string{
+		"/missing.bin": "404 Not Found",
+		"/gone.bin":    "410 Gone",
+		"/shifted.bin": "doesn't match",
+	} {
+		dir := t.TempDir()
+		dm := New(WithFilePath(dir), WithSkipSubPathMap(), WithConcurrency(4))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		res, err := dm.DownloadContext(ctx, srv.URL+path)
+		cancel()
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v and %+v", path, want, err, res)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package downloader
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often ChunkProgress events are emitted
+const progressInterval = 200 * time.Millisecond
+
+// EventType represents the type of a progress event
+type EventType int
+
+const (
+	MetaFetched   EventType = iota // the meta information is fetched, the chunks are about to start
+	ChunkStarted                   // a chunk or a stream segment started downloading
+	ChunkProgress                  // emitted periodically while a chunk is downloading
+	ChunkRetried                   // a chunk or a stream segment failed and is downloaded again
+	ChunkDone                      // a chunk or a stream segment is downloaded
+	Completed                      // the file is downloaded and verified; seeding torrents come afterwards
+	Failed                         // the download stopped with an error
+)
+
+var eventTypeNames = [...]string{"meta_fetched", "chunk_started", "chunk_progress", "chunk_retried", "chunk_done", "completed", "failed"}
+
+func (t EventType) String() string {
+	if t < 0 || int(t) >= len(eventTypeNames) {
+		return "unknown"
+	}
+	return eventTypeNames[t]
+}
+
+// Event represents a progress event of a download
+type Event struct {
+	Type   EventType
+	Chunk  int     // chunk or stream segment number; -1 if the event is about the whole download
+	Bytes  uint64  // downloaded bytes of the chunk of a regular download; Stats holds the totals
+	Err    error   // the error of ChunkRetried and Failed
+	Result *Result // the result of Completed
+	Stats  Stats   // snapshot of the download progress
+}
+
+// emit deliver the event to the progress handlers
+func (d *DownloadManager) emit(e Event) {
+	if len(d.option.handlers) == 0 {
+		return
+	}
+	e.Stats = d.Stats()
+
+	d.emitMu.Lock()
+	defer d.emitMu.Unlock()
+	for _, h := range d.option.handlers {
+		h(e)
+	}
+}
+
+// reportProgress emit ChunkProgress for the chunks making progress until stop is closed
+func (d *DownloadManager) reportProgress(stop <-chan struct{}) {
+	if len(d.option.handlers) == 0 {
+		return
+	}
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	var total uint64
+	last := make(map[int]uint64)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		chunks := d.Chunks()
+		if len(chunks) == 0 {
+			// streams and torrents report the whole download
+			if n := atomic.LoadUint64(&d.totalDownloaded); n != total {
+				total = n
+				d.emit(Event{Type: ChunkProgress, Chunk: -1})
+			}
+			continue
+		}
+		for i, c := range chunks {
+			if c.Done != last[i] && !c.complete() {
+				last[i] = c.Done
+				d.emit(Event{Type: ChunkProgress, Chunk: i, Bytes: c.Done})
+			}
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	netUrl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// fetcher represents a contract for reading a resource, the implementation is chosen by the url scheme
+type fetcher interface {
+	// meta return the size and meta information of the resource; size is -1 if unknown
+	meta(ctx context.Context, url string) (int64, http.Header, error)
+	// fetch return a reader for the byte range [min, max) of the resource; a negative max reads the whole resource
+	// without a range e.g: its size is unknown
+	fetch(ctx context.Context, url string, min, max int64) (io.ReadCloser, error)
+}
+
+// errRangeIgnored is returned for a range request answered with the whole resource
+var errRangeIgnored = errors.New("dl: server ignores range requests")
+
+// statusError represents a response other than 2xx
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "dl: unexpected status: " + e.status
+}
+
+// permanent report whether retrying the request can't help e.g: 404 Not Found
+func permanent(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code < 500 && se.code != http.StatusRequestTimeout && se.code != http.StatusTooManyRequests
+	}
+	return errors.Is(err, errRangeIgnored)
+}
+
+// httpFetcher fetches resources over HTTP/HTTPS using range requests
+type httpFetcher struct {
+	client HTTPClient
+}
+
+func (f httpFetcher) meta(ctx context.Context, url string) (int64, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create HTTP/HEAD request: %v", err)
+	}
+	// the ranges are of the encoded content, an encoding would break the chunk offsets
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to perform HTTP/HEAD request: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+		return -1, make(http.Header), nil // HEAD isn't supported, the size is unknown
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return 0, nil, &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+	return resp.ContentLength, resp.Header, nil
+}
+
+func (f httpFetcher) fetch(ctx context.Context, url string, min, max int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP/GET request: %v", err)
+	}
+
+	if max >= 0 {
+		rangeHeader := "bytes=" + strconv.FormatInt(min, 10) + "-" + strconv.FormatInt(max-1, 10)
+		req.Header.Add("Range", rangeHeader)
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform HTTP/GET request: %w", err)
+	}
+	if err := checkResponse(resp, min, max); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// checkResponse report an error unless the response is 2xx and carries the range [min, max) asked for: a 206 with
+// the matching Content-Range, or a 200 of exactly the range starting at 0
+func checkResponse(resp *http.Response, min, max int64) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+	if max < 0 {
+		return nil
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		if min == 0 && resp.ContentLength == max {
+			return nil
+		}
+		return errRangeIgnored
+	}
+
+	var first, last int64
+	cr := resp.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(cr, "bytes %d-%d/", &first, &last); err != nil || first != min || last != max-1 {
+		return fmt.Errorf("dl: content range %q doesn't match the requested bytes %d-%d", cr, min, max-1)
+	}
+	return nil
+}
+
+// fileFetcher copies local files referenced by file:// urls
+type fileFetcher struct{}
+
+func (f fileFetcher) meta(ctx context.Context, url string) (int64, http.Header, error) {
+	fn, err := fileURLPath(url)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return 0, nil, err
+	}
+	if fi.IsDir() {
+		return 0, nil, fmt.Errorf("%s is a directory", fn)
+	}
+
+	header := make(http.Header)
+	header.Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+	return fi.Size(), header, nil
+}
+
+func (f fileFetcher) fetch(ctx context.Context, url string, min, max int64) (io.ReadCloser, error) {
+	fn, err := fileURLPath(url)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	if max < 0 {
+		return fp, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(fp, min, max-min), fp}, nil
+}
+
+// dataFetcher decodes data: urls (RFC 2397)
+type dataFetcher struct {
+	data []byte
+}
+
+func (f *dataFetcher) meta(ctx context.Context, url string) (int64, http.Header, error) {
+	mediaType, data, err := decodeDataURL(url)
+	if err != nil {
+		return 0, nil, err
+	}
+	f.data = data
+
+	header := make(http.Header)
+	header.Set("Content-Type", mediaType)
+	return int64(len(data)), header, nil
+}
+
+func (f *dataFetcher) fetch(ctx context.Context, url string, min, max int64) (io.ReadCloser, error) {
+	if max < 0 {
+		max = int64(len(f.data))
+	}
+	if max > int64(len(f.data)) || min > max {
+		return nil, errors.New("range out of bounds")
+	}
+	return ioutil.NopCloser(bytes.NewReader(f.data[min:max])), nil
+}
+
+// urlScheme return the lower cased scheme of the url
+func urlScheme(url string) string {
+	if i := strings.Index(url, ":"); i > 0 {
+		return strings.ToLower(url[:i])
+	}
+	return ""
+}
+
+// newFetcher return the fetcher for the url scheme
+func newFetcher(url string, client HTTPClient) (fetcher, error) {
+	switch urlScheme(url) {
+	case "http", "https":
+		return httpFetcher{client: client}, nil
+	case "file":
+		return fileFetcher{}, nil
+	case "data":
+		return &dataFetcher{}, nil
+	}
+	return nil, fmt.Errorf("dl: unsupported url scheme: %q", urlScheme(url))
+}
+
+// fileNameFromURL return the default file name of the resource
+func fileNameFromURL(url string) string {
+	switch urlScheme(url) {
+	case "data":
+		mediaType := "text/plain"
+		if i := strings.Index(url, ","); i > 0 {
+			if mt, _, err := mime.ParseMediaType(strings.TrimSuffix(url[len("data:"):i], ";base64")); err == nil {
+				mediaType = mt
+			}
+		}
+		return "data" + extensionByType(mediaType)
+	case "file":
+		if fn, err := fileURLPath(url); err == nil {
+			return filepath.Base(fn)
+		}
+	}
+	return path.Base(url)
+}
+
+// fileURLPath return the local path of a file:// url
+func fileURLPath(url string) (string, error) {
+	u, err := netUrl.Parse(url)
+	if err != nil {
+		return "", err
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("dl: unsupported file url host: %s", u.Host)
+	}
+	p := u.Path
+	// file:///C:/foo.iso on windows
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p), nil
+}
+
+// decodeDataURL return the media type and decoded content of a data: url
+func decodeDataURL(url string) (string, []byte, error) {
+	i := strings.Index(url, ",")
+	if urlScheme(url) != "data" || i < 0 {
+		return "", nil, errors.New("dl: invalid data url")
+	}
+
+	params := url[len("data:"):i]
+	isBase64 := strings.HasSuffix(strings.ToLower(params), ";base64")
+	if isBase64 {
+		params = params[:len(params)-len(";base64")]
+	}
+
+	mediaType := "text/plain"
+	if params != "" {
+		if strings.HasPrefix(params, ";") {
+			params = mediaType + params
+		}
+		mt, _, err := mime.ParseMediaType(params)
+		if err != nil {
+			return "", nil, fmt.Errorf("dl: invalid data url media type: %v", err)
+		}
+		mediaType = mt
+	}
+
+	payload, err := netUrl.PathUnescape(url[i+1:])
+	if err != nil {
+		return "", nil, fmt.Errorf("dl: invalid data url: %v", err)
+	}
+	if !isBase64 {
+		return mediaType, []byte(payload), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("dl: invalid base64 data url: %v", err)
+	}
+	return mediaType, data, nil
+}
+
+// extensionByType return the preferred file extension of a media type; the types detected by http.DetectContentType
+// are listed, the system table may lack them
+func extensionByType(mediaType string) string {
+	preferred := map[string]string{
+		"text/plain":                   ".txt",
+		"text/html":                    ".html",
+		"image/jpeg":                   ".jpg",
+		"application/octet-stream":     ".bin",
+		"application/gzip":             ".gz",
+		"application/x-gzip":           ".gz",
+		"application/zip":              ".zip",
+		"application/x-rar-compressed": ".rar",
+		"application/ogg":              ".ogg",
+		"audio/mpeg":                   ".mp3",
+		"audio/wave":                   ".wav",
+		"audio/aiff":                   ".aiff",
+		"video/mp4":                    ".mp4",
+		"video/webm":                   ".webm",
+		"video/avi":                    ".avi",
+		"image/x-icon":                 ".ico",
+		"image/bmp":                    ".bmp",
+	}
+	if ext, ok := preferred[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,193 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// isHLSPlaylist report whether the content is a HLS playlist
+func isHLSPlaylist(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), []byte("#EXTM3U"))
+}
+
+// parseHLSMaster return the variants of a master playlist; empty if the playlist is a media playlist
+func parseHLSMaster(data []byte, base string) ([]streamVariant, error) {
+	variants := make([]streamVariant, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var pending *streamVariant
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			attrs := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bandwidth, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			pending = &streamVariant{bandwidth: bandwidth, resolution: attrs["RESOLUTION"]}
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case pending != nil:
+			uri, err := resolveReference(base, line)
+			if err != nil {
+				return nil, err
+			}
+			pending.uri = uri
+			variants = append(variants, *pending)
+			pending = nil
+		}
+	}
+	return variants, scanner.Err()
+}
+
+// parseHLSMedia return the segments of a media playlist
+func parseHLSMedia(data []byte, base string) (*streamPlaylist, error) {
+	pl := &streamPlaylist{ext: ".ts"}
+
+	var (
+		key         *segmentKey
+		sequence    int64
+		nextOffset  int64
+		byteRange   string
+		hasSegments bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence, _ = strconv.ParseInt(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"), 10, 64)
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attrs := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-KEY:"))
+			switch attrs["METHOD"] {
+			case "NONE":
+				key = nil
+			case "AES-128":
+				uri, err := resolveReference(base, attrs["URI"])
+				if err != nil {
+					return nil, err
+				}
+				key = &segmentKey{uri: uri}
+				if iv := attrs["IV"]; iv != "" {
+					b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X"))
+					if err != nil || len(b) != 16 {
+						return nil, fmt.Errorf("dl: invalid HLS key IV: %s", iv)
+					}
+					key.iv = b
+				}
+			default:
+				return nil, fmt.Errorf("dl: unsupported HLS encryption method: %s", attrs["METHOD"])
+			}
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			attrs := parseHLSAttributes(strings.TrimPrefix(line, "#EXT-X-MAP:"))
+			uri, err := resolveReference(base, attrs["URI"])
+			if err != nil {
+				return nil, err
+			}
+			pl.init = &streamSegment{url: uri, length: -1, key: key}
+			if br := attrs["BYTERANGE"]; br != "" {
+				length, offset, err := parseHLSByteRange(br, 0)
+				if err != nil {
+					return nil, err
+				}
+				pl.init.offset, pl.init.length = offset, length
+			}
+			if pl.init.key != nil && pl.init.key.iv == nil {
+				pl.init.key = &segmentKey{uri: key.uri, iv: sequenceIV(sequence)}
+			}
+			pl.ext = ".mp4"
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			byteRange = strings.TrimPrefix(line, "#EXT-X-BYTERANGE:")
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			uri, err := resolveReference(base, line)
+			if err != nil {
+				return nil, err
+			}
+			seg := streamSegment{url: uri, length: -1, key: key}
+			if byteRange != "" {
+				length, offset, err := parseHLSByteRange(byteRange, nextOffset)
+				if err != nil {
+					return nil, err
+				}
+				seg.offset, seg.length = offset, length
+				nextOffset = offset + length
+				byteRange = ""
+			}
+			if key != nil && key.iv == nil {
+				seg.key = &segmentKey{uri: key.uri, iv: sequenceIV(sequence)}
+			}
+			pl.segments = append(pl.segments, seg)
+			sequence++
+			hasSegments = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !hasSegments {
+		return nil, errors.New("dl: HLS playlist has no segments")
+	}
+	return pl, nil
+}
+
+// parseHLSAttributes parse an attribute list like BANDWIDTH=1280000,CODECS="avc1,mp4a"
+func parseHLSAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.Index(s, ","); comma >= 0 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[name] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attrs
+}
+
+// parseHLSByteRange parse <length>[@<offset>]; the offset defaults to the end of the previous sub-range
+func parseHLSByteRange(s string, nextOffset int64) (int64, int64, error) {
+	parts := strings.SplitN(s, "@", 2)
+	length, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("dl: invalid HLS byte range: %s", s)
+	}
+	offset := nextOffset
+	if len(parts) == 2 {
+		if offset, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("dl: invalid HLS byte range: %s", s)
+		}
+	}
+	return length, offset, nil
+}
+
+// sequenceIV return the IV derived from the media sequence number
+func sequenceIV(sequence int64) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint64(iv[8:], uint64(sequence))
+	return iv
+}
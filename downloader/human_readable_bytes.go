@@ -22,3 +22,8 @@ func humanaReadableBytes(s float64) string {
 
 	return fmt.Sprintf("%s %s", fmt.Sprintf(f, val), suffix)
 }
+
+// HumanReadableBytes convert the bytes to human readable form e.g: 1.5 MB
+func HumanReadableBytes(b uint64) string {
+	return humanaReadableBytes(float64(b))
+}
@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sniffLen is the number of leading bytes http.DetectContentType considers
+const sniffLen = 512
+
+// genericTypes are the media types telling nothing about the content
+var genericTypes = map[string]bool{
+	"":                           true,
+	"application/octet-stream":   true,
+	"binary/octet-stream":        true,
+	"application/unknown":        true,
+	"application/download":       true,
+	"application/force-download": true,
+}
+
+// detectType set the media type of the resource from the Content-Type header, or from its first bytes if the header
+// is missing or generic; a name taken from an url without extension gets the extension of the type e.g: report to report.pdf
+func (d *DownloadManager) detectType(ctx context.Context, url string) {
+	mt, _, _ := mime.ParseMediaType(d.header.Get("Content-Type"))
+	mt = strings.ToLower(mt)
+	if genericTypes[mt] && filepath.Ext(d.fileName) == "" {
+		if sniffed := d.sniffType(ctx, url); sniffed != "" {
+			d.option.log.Debug("sniffed media type", "url", url, "type", sniffed, "header", mt)
+			mt = sniffed
+		}
+	}
+	d.mu.Lock()
+	d.mimeType = mt
+	d.mu.Unlock()
+
+	if filepath.Ext(d.fileName) != "" || d.fileName != fileNameFromURL(url) || genericTypes[mt] {
+		return
+	}
+	if ext := extensionByType(mt); ext != "" {
+		d.option.log.Info("added extension of the media type", "name", d.fileName, "type", mt, "extension", ext)
+		d.setFileName(d.fileName + ext)
+	}
+}
+
+// sniffType return the media type detected from the first bytes of the resource; empty if it can't be read
+func (d *DownloadManager) sniffType(ctx context.Context, url string) string {
+	size := atomic.LoadUint64(&d.fileSize)
+	if size == 0 || size == ^uint64(0) {
+		return ""
+	}
+	n := sniffLen
+	if size < uint64(n) {
+		n = int(size)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	rc, err := d.fetcher.fetch(ctx, url, 0, int64(n))
+	if errors.Is(err, errRangeIgnored) {
+		rc, err = d.fetcher.fetch(ctx, url, 0, -1) // the first bytes of the whole resource are enough
+	}
+	if err != nil {
+		d.option.log.Warn("failed to sniff media type", "url", url, "error", err)
+		return ""
+	}
+	defer rc.Close()
+	bb, err := ioutil.ReadAll(io.LimitReader(rc, int64(n)))
+	if err != nil || len(bb) == 0 {
+		return ""
+	}
+	mt, _, _ := mime.ParseMediaType(http.DetectContentType(bb))
+	if genericTypes[mt] {
+		return ""
+	}
+	return mt
+}
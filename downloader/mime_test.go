@@ -0,0 +1,118 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/dl/values"
+)
+
+func TestDownloadMediaType(t *testing.T) {
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), bytes.Repeat([]byte{1}, 2000)...)
+	gz := append([]byte("\x1f\x8b\x08"), bytes.Repeat([]byte{2}, 2000)...)
+	pdf := []byte("%PDF-1.4\n" + strings.Repeat("x", 2000))
+	html := []byte("<!DOCTYPE html><html><body>page</body></html>")
+	bin := bytes.Repeat([]byte{0, 3}, 1000)
+
+	tests := []struct {
+		path        string
+		contentType string // none for no header at all
+		body        []byte
+		noRange     bool   // the whole resource is sent for a range request
+		name        string // WithFilename
+		want        string
+	}{
+		{path: "/report", contentType: "application/pdf", body: pdf, want: "document/report.pdf"},
+		{path: "/page", contentType: "Text/HTML; charset=utf-8", body: html, want: "document/page.html"},
+		// sniffed as the header is missing or generic
+		{path: "/clip", contentType: "none", body: png, want: "image/clip.png"},
+		{path: "/archive", contentType: "application/octet-stream", body: gz, want: "compressed/archive.gz"},
+		{path: "/archive", contentType: "binary/octet-stream", body: gz, noRange: true, want: "compressed/archive.gz"},
+		{path: "/blob", contentType: "none", body: bin, want: "other/blob"},
+		// the extension of the name is kept; a name given by the user isn't changed
+		{path: "/photo.bin", contentType: "image/png", body: png, want: "image/photo.bin"},
+		{path: "/notes.bin", contentType: "application/octet-stream", body: png, want: "other/notes.bin"},
+		{path: "/clip", contentType: "video/mp4", body: bin, name: "movie", want: "video/movie"},
+		{path: "/clip", contentType: "none", body: png, name: "picture", want: "image/picture"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		var sniffed int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.contentType == "none" {
+				w.Header()["Content-Type"] = nil // not detected by the server
+			} else {
+				w.Header().Set("Content-Type", tt.contentType)
+			}
+			if r.Method == http.MethodGet && r.Header.Get("Range") == "bytes=0-511" {
+				sniffed++
+			}
+			if tt.noRange {
+				w.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+				if r.Method == http.MethodGet {
+					w.Write(tt.body)
+				}
+				return
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(tt.body))
+		}))
+
+		dir := t.TempDir()
+		opts := []OptionFunc{
+			WithFilePath(dir),
+			WithSubPathMap(values.MapStrSliceString{"compressed": {".gz", ".zip"}}),
+			WithMimePathMap(values.MapStrSliceString{"image": {"image/*"}, "video": {"video/*"}, "document": {"text/*", "application/pdf"}}),
+		}
+		if tt.name != "" {
+			opts = append(opts, WithFilename(tt.name))
+		}
+		res, err := New(opts...).DownloadContext(context.Background(), srv.URL+tt.path)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.path, tt.contentType, err)
+		}
+		if rel, _ := filepath.Rel(dir, res.Path); rel != filepath.FromSlash(tt.want) {
+			t.Errorf("%s %s: stored as %s, want %s", tt.path, tt.contentType, rel, tt.want)
+		}
+		if b, _ := ioutil.ReadFile(res.Path); !bytes.Equal(b, tt.body) {
+			t.Errorf("%s %s: content differs", tt.path, tt.contentType)
+		}
+		// the first bytes are requested only to sniff a missing or generic type of an extensionless name
+		wantSniff := (tt.contentType == "none" || strings.HasSuffix(tt.contentType, "octet-stream")) && filepath.Ext(tt.path) == ""
+		if (sniffed > 0) != wantSniff {
+			t.Errorf("%s %s: sniffed %d times, want %v", tt.path, tt.contentType, sniffed, wantSniff)
+		}
+	}
+}
+
+func TestCategory(t *testing.T) {
+	d := New(
+		WithSubPathMap(values.MapStrSliceString{"video": {".mp4"}, "compressed": {".zip"}}),
+		WithMimePathMap(values.MapStrSliceString{"image": {"image/*"}, "document": {"text/*", "application/pdf"}, "audio": {"*/ogg"}}),
+	)
+	tests := []struct {
+		name, mimeType, want string
+	}{
+		{name: "a.mp4", mimeType: "image/png", want: "video"}, // the extension comes first
+		{name: "a.ZIP", want: "compressed"},
+		{name: "a", mimeType: "image/png", want: "image"},
+		{name: "a.bin", mimeType: "application/pdf", want: "document"},
+		{name: "a", mimeType: "text/plain", want: "document"},
+		{name: "a", mimeType: "application/ogg", want: "audio"},
+		{name: "a", mimeType: "application/octet-stream", want: "other"},
+		{name: "a", want: "other"},
+	}
+	for _, tt := range tests {
+		if got := d.category(tt.name, tt.mimeType); got != tt.want {
+			t.Errorf("category(%s, %s) = %s, want %s", tt.name, tt.mimeType, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"errors"
+	"io"
 	"strings"
 
 	"github.com/thedevsaddam/dl/logger"
@@ -13,9 +14,23 @@ type option struct {
 	concurrency    int
 	path           string                   // directory
 	subPathMap     values.MapStrSliceString // sub directory
+	mimePathMap    values.MapStrSliceString // sub directory of the media type patterns, if the extension has none
 	skipSubPathMap bool
-	log            logger.Logger
+	log            logger.LevelLogger
 	verbose        bool
+	s3             S3Options
+	variant        string  // HLS/DASH variant selector
+	seedRatio      float64 // keep seeding torrents until the ratio is reached
+	resumeLocation string  // file of a previous download to continue
+	resumeChunks   []Chunk // chunk progress of the previous download
+	handlers       []func(Event)
+	cache          Cache     // serve the unchanged resources from the cache; nil disables
+	decompress     bool      // decompress the downloaded .gz, .bz2, .xz and .zst files
+	rules          []route   // routing rules checked before the sub directory of the extension
+	noPrealloc     bool      // leave the file sparse rather than reserving its size up front
+	bufferSize     int       // write buffer of a chunk in bytes
+	fsync          bool      // flush the file to the disk once downloaded
+	output         io.Writer // write the content to the stream rather than a file; nil writes a file
 }
 
 // OptionFunc represents a contract for option func, it basically set options to jsonq instance options
@@ -51,6 +66,14 @@ func WithSubPathMap(m values.MapStrSliceString) OptionFunc {
 	}
 }
 
+// WithMimePathMap set the sub directories of the media type patterns e.g: video/*, used if the extension has no sub directory
+func WithMimePathMap(m values.MapStrSliceString) OptionFunc {
+	return func(dm *DownloadManager) error {
+		dm.option.mimePathMap = m
+		return nil
+	}
+}
+
 // WithSkipSubPathMap skip subdirectory resolving
 func WithSkipSubPathMap() OptionFunc {
 	return func(dm *DownloadManager) error {
@@ -78,10 +101,142 @@ func WithVerbose() OptionFunc {
 	}
 }
 
-// WithLogger set custom logger
+// WithLogger set custom logger; a logger.LevelLogger gets the leveled entries
 func WithLogger(l logger.Logger) OptionFunc {
 	return func(dm *DownloadManager) error {
-		dm.option.log = l
+		dm.option.log = logger.Leveled(l)
+		return nil
+	}
+}
+
+// WithS3Options set the endpoint, region and credentials profile used for s3:// urls
+func WithS3Options(o S3Options) OptionFunc {
+	return func(dm *DownloadManager) error {
+		dm.option.s3 = o
+		return nil
+	}
+}
+
+// WithVariant set the HLS/DASH variant to download: highest (default), lowest, a resolution e.g: 1280x720,
+// a height e.g: 720p or a bandwidth; audio or audio:<selector> chooses a separate DASH audio representation
+func WithVariant(v string) OptionFunc {
+	return func(dm *DownloadManager) error {
+		dm.option.variant = strings.TrimSpace(v)
+		return nil
+	}
+}
+
+// WithSeedRatio keep seeding torrents after downloading until the upload ratio is reached
+func WithSeedRatio(r float64) OptionFunc {
+	return func(dm *DownloadManager) error {
+		if r < 0 {
+			return errors.New("dl: seed ratio can't be negative")
+		}
+		dm.option.seedRatio = r
+		return nil
+	}
+}
+
+// WithResume continue a previous download stored at the location from the chunk progress;
+// the download starts over if the remote file has changed in size
+func WithResume(location string, chunks []Chunk) OptionFunc {
+	return func(dm *DownloadManager) error {
+		if location == "" {
+			return errors.New("dl: resume location can't be empty")
+		}
+		dm.option.resumeLocation = location
+		dm.option.resumeChunks = append([]Chunk(nil), chunks...) // the chunks are updated while downloading
+		return nil
+	}
+}
+
+// WithProgressHandler subscribe the handler to the progress events; handlers are called one at a time
+// from the downloading goroutines, so they should return quickly
+func WithProgressHandler(h func(Event)) OptionFunc {
+	return func(dm *DownloadManager) error {
+		if h == nil {
+			return errors.New("dl: progress handler can't be nil")
+		}
+		dm.option.handlers = append(dm.option.handlers, h)
+		return nil
+	}
+}
+
+// WithCache serve the unchanged resources from the cache and add the downloaded files to it;
+// only the regular downloads with an ETag are cached
+func WithCache(c Cache) OptionFunc {
+	return func(dm *DownloadManager) error {
+		if c == nil {
+			return errors.New("dl: cache can't be nil")
+		}
+		dm.option.cache = c
+		return nil
+	}
+}
+
+// WithDecompress replace the downloaded .gz, .bz2, .xz or .zst file by its decompressed content, named without
+// the extension and sorted by the inner extension e.g: foo.pdf.gz to document/foo.pdf
+func WithDecompress() OptionFunc {
+	return func(dm *DownloadManager) error {
+		dm.option.decompress = true
+		return nil
+	}
+}
+
+// WithRules route the downloads matching a rule to its destination inside the directory, the first matching rule wins;
+// the other downloads are stored in the sub directory of their extension
+func WithRules(rules []Rule) OptionFunc {
+	return func(dm *DownloadManager) error {
+		routes := make([]route, 0, len(rules))
+		for _, r := range rules {
+			rt, err := compileRule(r)
+			if err != nil {
+				return err
+			}
+			routes = append(routes, rt)
+		}
+		dm.option.rules = routes
+		return nil
+	}
+}
+
+// WithNoPrealloc leave the file sparse, the blocks are allocated as the chunks are written; by default the size
+// of the file is reserved before downloading
+func WithNoPrealloc() OptionFunc {
+	return func(dm *DownloadManager) error {
+		dm.option.noPrealloc = true
+		return nil
+	}
+}
+
+// WithBufferSize set the write buffer of a chunk in bytes, default 256 KB; larger buffers mean fewer writes
+// e.g: for network file systems, at the cost of memory per connection
+func WithBufferSize(size int) OptionFunc {
+	return func(dm *DownloadManager) error {
+		if size <= 0 {
+			return errors.New("dl: buffer size must be positive")
+		}
+		dm.option.bufferSize = size
+		return nil
+	}
+}
+
+// WithOutput write the content to the stream in order rather than to a file e.g: os.Stdout; the chunks are still
+// fetched concurrently. Torrents and HLS/DASH streams can't be written to an output stream
+func WithOutput(w io.Writer) OptionFunc {
+	return func(dm *DownloadManager) error {
+		if w == nil {
+			return errors.New("dl: output can't be nil")
+		}
+		dm.option.output = w
+		return nil
+	}
+}
+
+// WithFsync flush the file to the disk before the download completes, so it survives a crash or a power loss
+func WithFsync() OptionFunc {
+	return func(dm *DownloadManager) error {
+		dm.option.fsync = true
 		return nil
 	}
 }
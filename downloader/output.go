@@ -0,0 +1,255 @@
+package downloader
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// pieceSize is the range fetched by a connection when writing to an output stream; the pieces are written in order,
+// at most twice the concurrency of them are held in memory
+const pieceSize = 1 << 20
+
+// piece is a fetched range of the resource waiting for its turn to be written
+type piece struct {
+	buf *[]byte
+	n   int
+	err error
+}
+
+// streamOutput download the resource to the output stream: the pieces are fetched concurrently and written in order
+func (d *DownloadManager) streamOutput(ctx, caller context.Context, url string, startedAt time.Time) (*Result, error) {
+	if d.torrent != nil || d.playlist != nil {
+		return nil, d.fail(errors.New("dl: torrents and HLS/DASH streams can't be written to an output stream"))
+	}
+	if d.verify != nil {
+		d.option.log.Warn("output stream isn't verified against the object checksum", "url", url)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the digest and the size are of the bytes written to the output i.e: decoded, like the files
+	digest := sha256.New()
+	written := uint64(0)
+	out, decoded := d.decodeOutput(Writer{io.MultiWriter(d.option.output, digest), &written})
+
+	size := atomic.LoadUint64(&d.fileSize)
+	d.option.log.Info("downloading to output stream", "url", url, "size", size, "concurrency", d.option.concurrency)
+	var err error
+	sequential := size == ^uint64(0)
+	if !sequential && size > pieceSize {
+		sequential, err = d.rangesIgnored(ctx, url)
+	}
+	if err == nil && sequential {
+		err = d.streamSequential(ctx, url, out)
+	} else if err == nil {
+		err = d.streamPieces(ctx, url, size, out)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if derr := <-decoded; err == nil {
+		err = derr
+	}
+	if err != nil {
+		d.addError(err)
+	}
+
+	if err := d.err(caller); err != nil {
+		d.option.log.Error("download failed", "url", url, "error", err)
+		d.emit(Event{Type: Failed, Chunk: -1, Err: err})
+		return nil, err
+	}
+
+	res := &Result{
+		Size:     atomic.LoadUint64(&written),
+		Digest:   hex.EncodeToString(digest.Sum(nil)),
+		Duration: time.Since(startedAt),
+	}
+	d.option.log.Info("download completed", "size", res.Size, "duration", res.Duration, "digest", res.Digest)
+	d.emit(Event{Type: Completed, Chunk: -1, Result: res})
+	return res, nil
+}
+
+// decodeOutput return the writer of the downloaded bytes; the content of a server sending gzip despite
+// the identity encoding asked for is decoded on the way. The error of the decoding is sent once the writer is closed
+func (d *DownloadManager) decodeOutput(output io.Writer) (io.WriteCloser, <-chan error) {
+	decoded := make(chan error, 1)
+	enc := strings.ToLower(d.header.Get("Content-Encoding"))
+	if enc != "gzip" && enc != "x-gzip" {
+		decoded <- nil
+		return nopWriteCloser{output}, decoded
+	}
+
+	d.option.log.Info("decoding content", "encoding", enc)
+	pr, pw := io.Pipe()
+	go func() {
+		zr, err := gzip.NewReader(pr)
+		if err == nil {
+			_, err = io.Copy(output, zr)
+		}
+		if err != nil {
+			err = fmt.Errorf("dl: failed to decode %s content: %v", enc, err)
+		}
+		pr.CloseWithError(err)
+		decoded <- err
+	}()
+	return pw, decoded
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// streamSequential copy the resource through a single connection e.g: its size is unknown or the server ignores ranges
+func (d *DownloadManager) streamSequential(ctx context.Context, url string, w io.Writer) error {
+	d.setTotalChunks(1)
+	d.startChunks()
+	d.emit(Event{Type: ChunkStarted, Chunk: 0})
+
+	body, err := d.fetcher.fetch(ctx, url, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	atomic.AddInt32(&d.connections, 1)
+	defer atomic.AddInt32(&d.connections, -1)
+
+	stop := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		d.reportProgress(stop)
+	}()
+	_, err = io.Copy(w, Reader{body, &d.totalDownloaded})
+	close(stop)
+	<-reported
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint64(&d.fileSize, atomic.LoadUint64(&d.totalDownloaded))
+	atomic.AddInt32(&d.totalChunkCompleted, 1)
+	d.emit(Event{Type: ChunkDone, Chunk: 0})
+	return nil
+}
+
+// streamPieces fetch the pieces with the concurrency of the manager and write them in order; a piece is fetched only
+// once it's within twice the concurrency of the piece being written, which bounds the memory
+func (d *DownloadManager) streamPieces(ctx context.Context, url string, size uint64, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	total := int((size + pieceSize - 1) / pieceSize)
+	d.setTotalChunks(total)
+	d.startChunks()
+	buffers := newBufferPool(pieceSize)
+
+	// the pending pieces in the order they're written; its capacity is the window of fetched pieces
+	order := make(chan chan piece, 2*d.option.concurrency)
+	go func() {
+		defer close(order)
+		conns := make(chan struct{}, d.option.concurrency)
+		for i := 0; i < total; i++ {
+			ch := make(chan piece, 1)
+			select {
+			case order <- ch:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int) {
+				select {
+				case conns <- struct{}{}:
+				case <-ctx.Done():
+					ch <- piece{err: ctx.Err()}
+					return
+				}
+				defer func() { <-conns }()
+
+				min := uint64(i) * pieceSize
+				max := min + pieceSize
+				if max > size {
+					max = size
+				}
+				ch <- d.fetchPiece(ctx, url, i, min, max, buffers)
+			}(i)
+		}
+	}()
+
+	stop := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		d.reportProgress(stop)
+	}()
+	defer func() {
+		close(stop)
+		<-reported
+	}()
+
+	for ch := range order {
+		p := <-ch
+		if p.err != nil {
+			return p.err
+		}
+		_, err := w.Write((*p.buf)[:p.n])
+		buffers.put(p.buf)
+		if err != nil {
+			return err
+		}
+		n := atomic.AddInt32(&d.totalChunkCompleted, 1)
+		d.emit(Event{Type: ChunkDone, Chunk: int(n - 1), Bytes: uint64(p.n)})
+	}
+	return ctx.Err()
+}
+
+// fetchPiece read the byte range [min, max) of the resource into a buffer of the pool
+func (d *DownloadManager) fetchPiece(ctx context.Context, url string, i int, min, max uint64, buffers *bufferPool) piece {
+	d.emit(Event{Type: ChunkStarted, Chunk: i})
+	buf := buffers.get()
+	p := piece{buf: buf, n: int(max - min)}
+
+	var lastErr error
+	p.err = retryContext(ctx, 3, 200*time.Millisecond, func() error {
+		if lastErr != nil {
+			d.option.log.Warn("retrying piece", "piece", i, "error", lastErr)
+			atomic.AddInt32(&d.retries, 1)
+			d.emit(Event{Type: ChunkRetried, Chunk: i, Err: lastErr})
+		}
+		lastErr = d.readPiece(ctx, url, min, max, (*buf)[:p.n])
+		return lastErr
+	})
+	if p.err != nil {
+		d.option.log.Error("failed to download piece", "piece", i, "error", p.err)
+		buffers.put(buf)
+	}
+	return p
+}
+
+func (d *DownloadManager) readPiece(ctx context.Context, url string, min, max uint64, buf []byte) error {
+	body, err := d.fetcher.fetch(ctx, url, int64(min), int64(max))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	atomic.AddInt32(&d.connections, 1)
+	defer atomic.AddInt32(&d.connections, -1)
+
+	counter := uint64(0)
+	_, err = io.ReadFull(Reader{body, &counter}, buf)
+	atomic.AddUint64(&d.totalDownloaded, counter)
+	if err != nil && counter > 0 {
+		// discount partial progress; the piece will be downloaded again
+		atomic.AddUint64(&d.totalDownloaded, ^(counter - 1))
+	}
+	return err
+}
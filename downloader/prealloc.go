@@ -0,0 +1,50 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+)
+
+// checkSpace report an error wrapping syscall.ENOSPC if the file system of the location can't hold the file;
+// the space taken by an existing file at the location is counted as available
+func (d *DownloadManager) checkSpace() error {
+	size := atomic.LoadUint64(&d.fileSize)
+	if size == 0 || size == ^uint64(0) {
+		return nil
+	}
+	free, err := diskFree(filepath.Dir(d.location))
+	if err != nil {
+		// unknown e.g: unsupported platform, the download fails once the disk is full
+		d.option.log.Debug("failed to read free disk space", "path", d.location, "error", err)
+		return nil
+	}
+	need := size
+	if fi, err := os.Stat(d.location); err == nil && fi.Mode().IsRegular() {
+		if uint64(fi.Size()) >= need {
+			return nil
+		}
+		need -= uint64(fi.Size())
+	}
+	if need > free {
+		return fmt.Errorf("dl: not enough disk space in %s: %s needed, %s available: %w",
+			filepath.Dir(d.location), HumanReadableBytes(need), HumanReadableBytes(free), syscall.ENOSPC)
+	}
+	return nil
+}
+
+// preallocate reserve the size of the file up front, so the download fails fast rather than once the disk is full
+// and the chunks written out of order don't fragment the file
+func (d *DownloadManager) preallocate(f *os.File) error {
+	size := atomic.LoadUint64(&d.fileSize)
+	if d.option.noPrealloc || size == 0 || size == ^uint64(0) {
+		return nil
+	}
+	if err := allocate(f, int64(size)); err != nil {
+		return fmt.Errorf("dl: failed to pre-allocate %s: %w", HumanReadableBytes(size), err)
+	}
+	d.option.log.Debug("pre-allocated file", "path", f.Name(), "size", size)
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build linux
+// +build linux
+
+package downloader
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// allocate reserve the blocks of the file; the file systems without fallocate support get a sparse file
+func allocate(f *os.File, size int64) error {
+	err := syscall.Fallocate(int(f.Fd()), 0, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return f.Truncate(size)
+	}
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package downloader
+
+import "os"
+
+// allocate set the size of the file; the blocks are allocated as the chunks are written
+func allocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
@@ -17,3 +17,16 @@ func (r Reader) Read(b []byte) (int, error) {
 	atomic.AddUint64(r.downloaded, uint64(n))
 	return n, err
 }
+
+// Writer represents a custom writer counting the written bytes
+type Writer struct {
+	io.Writer
+
+	written *uint64
+}
+
+func (w Writer) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	atomic.AddUint64(w.written, uint64(n))
+	return n, err
+}
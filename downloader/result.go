@@ -0,0 +1,87 @@
+package downloader
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/thedevsaddam/dl/torrent"
+)
+
+// Result represents a completed download
+type Result struct {
+	Path     string        // where the file is stored; the directory of a multi-file torrent; empty if written to an output stream
+	Size     uint64        // size in bytes
+	Digest   string        // hex encoded SHA-256 of the downloaded file, compressed if decompressed afterwards; empty for a multi-file torrent
+	Duration time.Duration // time taken to download
+}
+
+// State represents the phase of a download
+type State int32
+
+const (
+	StateIdle         State = iota
+	StateFetchingMeta       // fetching the meta information
+	StateDownloading
+	StateSeeding // seeding a completed torrent
+	StateDone
+)
+
+// Stats represents a snapshot of the download progress
+type Stats struct {
+	State           State
+	FileName        string
+	Location        string
+	Size            uint64 // 0 if unknown
+	Downloaded      uint64
+	Chunks          int // total number of chunks, stream segments or torrent pieces
+	ChunksCompleted int
+	Connections     int // open connections of the chunks or stream segments
+	Retries         int // failed meta requests, chunks and stream segments tried again
+	Torrent         bool
+}
+
+// Stats return a snapshot of the download progress; it's safe to call while downloading
+func (d *DownloadManager) Stats() Stats {
+	d.mu.Lock()
+	st := Stats{
+		FileName: d.fileName,
+		Location: d.location,
+		Chunks:   d.totalChunks,
+		Torrent:  d.torrent != nil,
+	}
+	stream := d.playlist != nil
+	d.mu.Unlock()
+
+	st.State = State(atomic.LoadInt32(&d.state))
+	st.Downloaded, st.Size = d.GetProgress()
+	st.ChunksCompleted = int(atomic.LoadInt32(&d.totalChunkCompleted))
+	st.Connections = int(atomic.LoadInt32(&d.connections))
+	st.Retries = int(atomic.LoadInt32(&d.retries))
+	// the size of a stream is known once the segments are concatenated
+	if st.Size == ^uint64(0) || (stream && st.State < StateDone) {
+		st.Size = 0
+	}
+	return st
+}
+
+func (d *DownloadManager) setState(s State) {
+	atomic.StoreInt32(&d.state, int32(s))
+}
+
+func (d *DownloadManager) setFileName(name string) {
+	d.mu.Lock()
+	d.fileName = name
+	d.mu.Unlock()
+}
+
+func (d *DownloadManager) setTotalChunks(n int) {
+	d.mu.Lock()
+	d.totalChunks = n
+	d.mu.Unlock()
+}
+
+func (d *DownloadManager) setTorrent(t *torrent.Torrent) {
+	d.mu.Lock()
+	d.torrent = t
+	d.mu.Unlock()
+}
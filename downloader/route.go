@@ -0,0 +1,196 @@
+package downloader
+
+import (
+	"fmt"
+	netUrl "net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Rule routes the downloads matching all of its conditions to the destination; an empty condition matches
+// every download
+type Rule struct {
+	Host        string   // the host or its sub-domains e.g: github.com
+	Path        string   // glob of the url path e.g: /releases/*/*.tar.gz
+	Name        string   // regular expression of the file name e.g: ^invoice-\d+\.pdf$
+	Extensions  []string // e.g: .iso, .img
+	MIME        string   // glob of the media type of the response e.g: video/*
+	MinSize     uint64   // bytes; the downloads of unknown size don't match a size range
+	MaxSize     uint64   // bytes, 0 means unlimited
+	Destination string   // template of the path inside the directory, see expandDestination
+}
+
+// route is a compiled Rule
+type route struct {
+	Rule
+	name *regexp.Regexp
+}
+
+// fileInfo is what the rules match against
+type fileInfo struct {
+	url      *netUrl.URL
+	name     string
+	mimeType string
+	size     uint64 // ^uint64(0) if unknown
+}
+
+func compileRule(r Rule) (route, error) {
+	rt := route{Rule: r}
+	if r.Destination == "" {
+		return rt, fmt.Errorf("dl: rule destination can't be empty")
+	}
+	if _, err := path.Match(r.Path, ""); err != nil {
+		return rt, fmt.Errorf("dl: invalid rule path %q: %v", r.Path, err)
+	}
+	if _, err := path.Match(r.MIME, ""); err != nil {
+		return rt, fmt.Errorf("dl: invalid rule mime %q: %v", r.MIME, err)
+	}
+	if r.Name != "" {
+		re, err := regexp.Compile(r.Name)
+		if err != nil {
+			return rt, fmt.Errorf("dl: invalid rule name %q: %v", r.Name, err)
+		}
+		rt.name = re
+	}
+	if r.MaxSize > 0 && r.MaxSize < r.MinSize {
+		return rt, fmt.Errorf("dl: rule max size can't be less than the min size")
+	}
+	return rt, nil
+}
+
+// match report whether the file matches every condition of the rule
+func (r route) match(f fileInfo) bool {
+	if r.Host != "" {
+		host, want := strings.ToLower(f.url.Hostname()), strings.ToLower(r.Host)
+		if host != want && !strings.HasSuffix(host, "."+want) {
+			return false
+		}
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, f.url.Path); !ok {
+			return false
+		}
+	}
+	if r.name != nil && !r.name.MatchString(f.name) {
+		return false
+	}
+	if len(r.Extensions) > 0 {
+		ext, ok := strings.ToLower(filepath.Ext(f.name)), false
+		for _, e := range r.Extensions {
+			if strings.ToLower(strings.TrimSpace(e)) == ext {
+				ok = true
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if r.MIME != "" {
+		if ok, _ := path.Match(strings.ToLower(r.MIME), f.mimeType); !ok {
+			return false
+		}
+	}
+	if r.MinSize > 0 || r.MaxSize > 0 {
+		if f.size == ^uint64(0) || f.size < r.MinSize || (r.MaxSize > 0 && f.size > r.MaxSize) {
+			return false
+		}
+	}
+	return true
+}
+
+// destination return the path of the file name inside the directory: the destination of the first matching rule,
+// the sub-directory of the extension or else of the media type otherwise
+func (d *DownloadManager) destination(url, name string) (string, error) {
+	d.mu.Lock()
+	f := fileInfo{name: name, mimeType: d.mimeType, size: atomic.LoadUint64(&d.fileSize)}
+	d.mu.Unlock()
+	f.url, _ = netUrl.Parse(url)
+	if f.url == nil {
+		f.url = &netUrl.URL{}
+	}
+
+	category := d.category(name, f.mimeType)
+	for _, r := range d.option.rules {
+		if !r.match(f) {
+			continue
+		}
+		dst, err := expandDestination(r.Destination, f, category, time.Now())
+		if err != nil {
+			return "", err
+		}
+		d.option.log.Debug("matched rule", "name", name, "destination", dst)
+		return dst, nil
+	}
+	return filepath.Join(category, name), nil
+}
+
+// Category return the sub-directory of the file name by its extension or else by the media type of the response
+// e.g: video, "other" if neither is mapped; the files are routed to it unless a rule matches
+func (d *DownloadManager) Category(name string) string {
+	d.mu.Lock()
+	mimeType := d.mimeType
+	d.mu.Unlock()
+	return d.category(name, mimeType)
+}
+
+func (d *DownloadManager) category(name, mimeType string) string {
+	ext := filepath.Ext(name)
+	if d.torrent != nil {
+		ext = torrentExt(d.torrent.MetaInfo())
+	}
+	if sp := d.option.subPathMap.Get(ext); sp != "" {
+		return sp
+	}
+	if sp := d.option.subPathMap.Get(".mp4"); d.playlist != nil && sp != "" {
+		// streams are stored along with the videos
+		return sp
+	}
+	if sp := d.option.mimePathMap.Match(mimeType); mimeType != "" && sp != "" {
+		return sp
+	}
+	return "other"
+}
+
+// expandDestination replace the placeholders of the template: {host}, {name}, {base} (the name without
+// the extension), {ext} (without the dot), {category} (the sub-directory of the extension or the media type), {yyyy}, {mm} and {dd};
+// the name is appended to a template ending with a slash. The result can't leave the directory
+func expandDestination(tmpl string, f fileInfo, category string, now time.Time) (string, error) {
+	ext := filepath.Ext(f.name)
+	host := strings.ToLower(f.url.Hostname())
+	if host == "" {
+		host = f.url.Scheme // e.g: magnet, data
+	}
+	if strings.HasSuffix(tmpl, "/") {
+		tmpl += "{name}"
+	}
+	dst := strings.NewReplacer(
+		"{host}", safeSegment(host),
+		"{name}", f.name,
+		"{base}", strings.TrimSuffix(f.name, ext),
+		"{ext}", strings.TrimPrefix(ext, "."),
+		"{category}", category,
+		"{yyyy}", now.Format("2006"),
+		"{mm}", now.Format("01"),
+		"{dd}", now.Format("02"),
+	).Replace(tmpl)
+
+	dst = filepath.Clean(filepath.FromSlash(dst))
+	if filepath.IsAbs(dst) || filepath.VolumeName(dst) != "" || dst == "." || dst == ".." ||
+		strings.HasPrefix(dst, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("dl: rule destination %q leaves the directory: %s", tmpl, dst)
+	}
+	return dst, nil
+}
+
+// safeSegment return the string usable as a single path segment
+func safeSegment(s string) string {
+	s = strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
@@ -0,0 +1,176 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	netUrl "net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/dl/values"
+)
+
+func TestCompileRule(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		err  bool
+	}{
+		{rule: Rule{Destination: "isos/"}},
+		{rule: Rule{Host: "github.com", Path: "/*/releases/*", Name: `^v\d+`, MIME: "video/*", MinSize: 1, MaxSize: 2, Destination: "a/"}},
+		{rule: Rule{}, err: true},
+		{rule: Rule{Path: "[", Destination: "a/"}, err: true},
+		{rule: Rule{MIME: "video/[", Destination: "a/"}, err: true},
+		{rule: Rule{Name: "(", Destination: "a/"}, err: true},
+		{rule: Rule{MinSize: 10, MaxSize: 5, Destination: "a/"}, err: true},
+	}
+	for _, tt := range tests {
+		if _, err := compileRule(tt.rule); (err != nil) != tt.err {
+			t.Errorf("compileRule(%+v): error %v, want %v", tt.rule, err, tt.err)
+		}
+	}
+}
+
+func TestRouteMatch(t *testing.T) {
+	file := func(rawurl, name, mimeType string, size uint64) fileInfo {
+		u, _ := netUrl.Parse(rawurl)
+		return fileInfo{url: u, name: name, mimeType: mimeType, size: size}
+	}
+	release := file("https://objects.GitHub.com/owner/releases/v1/tool.tar.gz", "tool.tar.gz", "application/gzip", 5<<20)
+	unknown := file("https://example.com/file.bin", "file.bin", "", ^uint64(0))
+
+	tests := []struct {
+		rule Rule
+		f    fileInfo
+		want bool
+	}{
+		{rule: Rule{}, f: release, want: true},
+		{rule: Rule{Host: "github.com"}, f: release, want: true},
+		{rule: Rule{Host: "objects.github.com"}, f: release, want: true},
+		{rule: Rule{Host: "hub.com"}, f: release, want: false},
+		{rule: Rule{Host: "example.com"}, f: release, want: false},
+		{rule: Rule{Path: "/*/releases/*/*.tar.gz"}, f: release, want: true},
+		{rule: Rule{Path: "/*/releases/*.tar.gz"}, f: release, want: false}, // * doesn't cross a slash
+		{rule: Rule{Name: `^tool\.`}, f: release, want: true},
+		{rule: Rule{Name: `^invoice-\d+\.pdf$`}, f: release, want: false},
+		{rule: Rule{Extensions: []string{".iso", " .GZ "}}, f: release, want: true},
+		{rule: Rule{Extensions: []string{".iso"}}, f: release, want: false},
+		{rule: Rule{MIME: "application/*"}, f: release, want: true},
+		{rule: Rule{MIME: "Video/*"}, f: release, want: false},
+		{rule: Rule{MIME: "video/*"}, f: unknown, want: false},
+		{rule: Rule{MinSize: 5 << 20}, f: release, want: true},
+		{rule: Rule{MinSize: 5<<20 + 1}, f: release, want: false},
+		{rule: Rule{MaxSize: 5 << 20}, f: release, want: true},
+		{rule: Rule{MaxSize: 1 << 20}, f: release, want: false},
+		{rule: Rule{MinSize: 1}, f: unknown, want: false},
+		{rule: Rule{MaxSize: 1 << 30}, f: unknown, want: false},
+		{rule: Rule{Host: "github.com", Extensions: []string{".gz"}, MaxSize: 1 << 20}, f: release, want: false}, // every condition
+	}
+	for _, tt := range tests {
+		tt.rule.Destination = "a/"
+		rt, err := compileRule(tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rt.match(tt.f); got != tt.want {
+			t.Errorf("%+v matching %s: %v, want %v", tt.rule, tt.f.url, got, tt.want)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	d := New(
+		WithSubPathMap(values.MapStrSliceString{"compressed": {".gz", ".zip"}}),
+		WithRules([]Rule{
+			{Host: "github.com", Extensions: []string{".gz"}, Destination: "releases/{host}/"},
+			{Extensions: []string{".gz"}, Destination: "archives/"},
+			{Host: "github.com", Destination: "github/"}, // the previous rules come first
+		}),
+	)
+	tests := []struct {
+		url, name, want string
+	}{
+		{url: "https://github.com/o/r/tool.tar.gz", name: "tool.tar.gz", want: "releases/github.com/tool.tar.gz"},
+		{url: "https://example.com/tool.tar.gz", name: "tool.tar.gz", want: "archives/tool.tar.gz"},
+		{url: "https://github.com/o/r/README", name: "README", want: "github/README"},
+		{url: "https://example.com/a.zip", name: "a.zip", want: "compressed/a.zip"},
+		{url: "https://example.com/a.txt", name: "a.txt", want: "other/a.txt"},
+		{url: "%zz", name: "a.gz", want: "archives/a.gz"},
+	}
+	for _, tt := range tests {
+		got, err := d.destination(tt.url, tt.name)
+		if err != nil || got != filepath.FromSlash(tt.want) {
+			t.Errorf("destination(%s, %s) = %s, %v, want %s", tt.url, tt.name, got, err, tt.want)
+		}
+	}
+
+	if err := New().ApplyOption(WithRules([]Rule{{Destination: "a/"}, {Name: "("}})); err == nil {
+		t.Error("expected an error for an invalid rule")
+	}
+}
+
+func TestExpandDestination(t *testing.T) {
+	now := time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC)
+	file := func(rawurl, name string) fileInfo {
+		u, _ := netUrl.Parse(rawurl)
+		return fileInfo{url: u, name: name}
+	}
+	f := file("https://CDN.example.com:8443/a/report.final.pdf", "report.final.pdf")
+
+	tests := []struct {
+		tmpl string
+		f    fileInfo
+		want string
+		err  bool
+	}{
+		{tmpl: "docs/", f: f, want: "docs/report.final.pdf"},
+		{tmpl: "{host}/{category}/{yyyy}/{mm}/{dd}/", f: f, want: "cdn.example.com/documents/2024/03/07/report.final.pdf"},
+		{tmpl: "{ext}/{base}-copy.{ext}", f: f, want: "pdf/report.final-copy.pdf"},
+		{tmpl: "flat", f: f, want: "flat"},
+		{tmpl: "{host}/", f: file("magnet:?xt=urn:btih:abc", "movie.mkv"), want: "magnet/movie.mkv"},
+		{tmpl: "{host}/", f: file("/local.torrent", "movie.mkv"), want: "_/movie.mkv"},
+		{tmpl: "{host}/", f: file("http://[::1]:8080/a.bin", "a.bin"), want: "__1/a.bin"},
+		{tmpl: "a/../b/", f: f, want: "b/report.final.pdf"},
+		{tmpl: "../", f: f, err: true},
+		{tmpl: "a/../../{name}", f: f, err: true},
+		{tmpl: "/etc/{name}", f: f, err: true},
+		{tmpl: ".", f: f, err: true},
+		{tmpl: "{base}", f: file("https://example.com/", ".."), err: true},
+		{tmpl: "x/../{name}", f: file("https://example.com/", ".."), err: true},
+	}
+	for _, tt := range tests {
+		got, err := expandDestination(tt.tmpl, tt.f, "documents", now)
+		if (err != nil) != tt.err || (!tt.err && got != filepath.FromSlash(tt.want)) {
+			t.Errorf("expandDestination(%q, %s) = %s, %v, want %s, error %v", tt.tmpl, tt.f.name, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestDownloadRouted(t *testing.T) {
+	content := testContent()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	_, err := New(
+		WithFilePath(dir),
+		WithSubPathMap(values.MapStrSliceString{"video": {".mp4"}}),
+		WithRules([]Rule{
+			{MIME: "audio/*", Destination: "music/"},
+			{MIME: "video/*", MinSize: 1 << 20, Destination: "{category}/{host}/{base}.{ext}"},
+		}),
+	).DownloadContext(context.Background(), srv.URL+"/clip.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "other", "127.0.0.1", "clip.bin"))
+	if err != nil || !bytes.Equal(b, content) {
+		t.Fatalf("routed file: %v", err)
+	}
+}
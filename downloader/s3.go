@@ -0,0 +1,434 @@
+package downloader
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	netUrl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Scheme        = "s3"
+	s3DefaultRegion = "us-east-1"
+	s3EmptyPayload  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Options represents the configuration used to access s3:// urls
+type S3Options struct {
+	Endpoint  string // custom endpoint e.g: http://localhost:9000 for MinIO
+	Region    string // bucket region, resolved from the AWS environment if empty
+	Profile   string // shared credentials profile, resolved from the AWS environment if empty
+	PathStyle bool   // use path-style addressing (always enabled for custom endpoints)
+}
+
+// s3Credentials represents a set of AWS credentials
+type s3Credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// s3Client signs every request with AWS signature version 4 before handing it to the underlying client
+type s3Client struct {
+	client HTTPClient
+	creds  *s3Credentials // nil means anonymous access
+	region string
+	now    func() time.Time
+}
+
+// s3Error represents the error document returned by S3 compatible servers
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// isS3URL report whether the url uses the s3:// scheme
+func isS3URL(url string) bool {
+	return strings.HasPrefix(strings.ToLower(url), s3Scheme+"://")
+}
+
+// resolveS3URL translate a s3://bucket/key url to a HTTP(s) url and return a signing client for it
+func resolveS3URL(rawURL string, opt S3Options, client HTTPClient) (string, *s3Client, error) {
+	u, err := netUrl.Parse(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", nil, errors.New("dl: s3 url must be in this format s3://bucket/key")
+	}
+
+	profile := firstNonEmpty(opt.Profile, os.Getenv("AWS_PROFILE"), "default")
+	region := firstNonEmpty(opt.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), awsConfigValue(profile, "region"), s3DefaultRegion)
+	endpoint := firstNonEmpty(opt.Endpoint, os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"))
+
+	pathStyle := opt.PathStyle || endpoint != "" || strings.Contains(bucket, ".")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	ep, err := netUrl.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return "", nil, fmt.Errorf("dl: invalid s3 endpoint: %v", err)
+	}
+
+	target := *ep
+	target.Path = ep.Path + "/" + key
+	target.RawPath = ep.EscapedPath() + "/" + s3EscapePath(key)
+	if pathStyle {
+		target.Path = ep.Path + "/" + bucket + "/" + key
+		target.RawPath = ep.EscapedPath() + "/" + s3EscapePath(bucket) + "/" + s3EscapePath(key)
+	} else {
+		target.Host = bucket + "." + ep.Host
+	}
+
+	return target.String(), &s3Client{
+		client: client,
+		creds:  resolveS3Credentials(profile),
+		region: region,
+		now:    time.Now,
+	}, nil
+}
+
+// Do sign the request and perform it; non 2xx responses are reported as errors
+func (c *s3Client) Do(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodHead {
+		req.Header.Set("x-amz-checksum-mode", "ENABLED")
+	}
+	if c.creds != nil {
+		c.sign(req)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	e := s3Error{}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return nil, fmt.Errorf("dl: s3: %s: %s", e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("dl: s3: unexpected status: %s", resp.Status)
+}
+
+// sign add AWS signature version 4 headers to the request
+func (c *s3Client) sign(req *http.Request) {
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", s3EmptyPayload)
+	if c.creds.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", c.creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "range" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		s3EmptyPayload,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.creds.secretAccessKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+// verifyS3Object compare the downloaded file against the object checksum or ETag returned by the server
+func verifyS3Object(location string, size uint64, header http.Header) error {
+	if sum := header.Get("x-amz-checksum-sha256"); sum != "" && !strings.Contains(sum, "-") {
+		got, err := fileDigest(location, sha256.New())
+		if err != nil {
+			return err
+		}
+		if base64.StdEncoding.EncodeToString(got) != sum {
+			return errors.New("dl: s3: sha256 checksum mismatch")
+		}
+		return nil
+	}
+
+	// ETags of objects encrypted with SSE-KMS/SSE-C are not MD5 digests
+	if header.Get("x-amz-server-side-encryption") == "aws:kms" || header.Get("x-amz-server-side-encryption-customer-algorithm") != "" {
+		return nil
+	}
+
+	etag := strings.Trim(header.Get("ETag"), `"`)
+	if etag == "" {
+		return nil
+	}
+
+	if i := strings.Index(etag, "-"); i > 0 {
+		parts, err := strconv.Atoi(etag[i+1:])
+		if err != nil || parts < 1 {
+			return nil
+		}
+		for _, partSize := range s3PartSizes(size, parts) {
+			got, err := multipartETag(location, partSize)
+			if err != nil {
+				return err
+			}
+			if got == etag {
+				return nil
+			}
+		}
+		return errors.New("dl: s3: multipart ETag mismatch")
+	}
+
+	got, err := fileDigest(location, md5.New())
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(got) != etag {
+		return errors.New("dl: s3: ETag mismatch")
+	}
+	return nil
+}
+
+// s3PartSizes return the candidate part sizes that could split the object in the given number of parts
+func s3PartSizes(size uint64, parts int) []uint64 {
+	const mib = 1 << 20
+	exact := (size + uint64(parts) - 1) / uint64(parts)
+	candidates := []uint64{
+		(exact + mib - 1) / mib * mib, exact,
+		5 * mib, 8 * mib, 15 * mib, 16 * mib, 64 * mib, 100 * mib, 128 * mib,
+	}
+
+	sizes := make([]uint64, 0, len(candidates))
+	seen := make(map[uint64]bool)
+	for _, c := range candidates {
+		if c == 0 || seen[c] || (size+c-1)/c != uint64(parts) {
+			continue
+		}
+		seen[c] = true
+		sizes = append(sizes, c)
+	}
+	return sizes
+}
+
+// multipartETag compute the S3 multipart ETag of a file for the given part size
+func multipartETag(location string, partSize uint64) (string, error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sums := md5.New()
+	parts := 0
+	r := bufio.NewReader(f)
+	for {
+		h := md5.New()
+		n, err := io.CopyN(h, r, int64(partSize))
+		if n > 0 {
+			sums.Write(h.Sum(nil))
+			parts++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sums.Sum(nil)), parts), nil
+}
+
+// fileDigest return the digest of the file content
+func fileDigest(location string, h hash.Hash) ([]byte, error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// resolveS3Credentials look up credentials from the environment and the shared credentials file
+func resolveS3Credentials(profile string) *s3Credentials {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return &s3Credentials{accessKeyID: id, secretAccessKey: secret, sessionToken: os.Getenv("AWS_SESSION_TOKEN")}
+	}
+
+	fn := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if fn == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		fn = filepath.Join(homeDir, ".aws", "credentials")
+	}
+	section := readINISection(fn, profile)
+	if section["aws_access_key_id"] == "" || section["aws_secret_access_key"] == "" {
+		return nil
+	}
+	return &s3Credentials{
+		accessKeyID:     section["aws_access_key_id"],
+		secretAccessKey: section["aws_secret_access_key"],
+		sessionToken:    section["aws_session_token"],
+	}
+}
+
+// awsConfigValue return a value of the profile from the shared config file
+func awsConfigValue(profile, key string) string {
+	fn := os.Getenv("AWS_CONFIG_FILE")
+	if fn == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		fn = filepath.Join(homeDir, ".aws", "config")
+	}
+	if profile != "default" {
+		profile = "profile " + profile
+	}
+	return readINISection(fn, profile)[key]
+}
+
+// readINISection read the key/value pairs of a section from an ini file
+func readINISection(fn, name string) map[string]string {
+	values := make(map[string]string)
+	f, err := os.Open(fn)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == name
+			continue
+		}
+		if i := strings.Index(line, "="); inSection && i > 0 {
+			values[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return values
+}
+
+// s3EscapePath escape the object key as required by AWS signature version 4
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = s3Escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3Escape percent-encode everything except the RFC 3986 unreserved characters
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// s3CanonicalQuery return the sorted and escaped query string
+func s3CanonicalQuery(q netUrl.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vv := append([]string(nil), q[k]...)
+		sort.Strings(vv)
+		for _, v := range vv {
+			pairs = append(pairs, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// firstNonEmpty return the first non empty value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,390 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	netUrl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// streamVariant represents a selectable quality of a HLS/DASH stream
+	streamVariant struct {
+		uri        string
+		bandwidth  int
+		resolution string
+		kind       string // content type of a DASH representation e.g: video, audio
+	}
+
+	// segmentKey represents the AES-128 key of a segment
+	segmentKey struct {
+		uri string
+		iv  []byte
+	}
+
+	// streamSegment represents a single media segment; length is -1 for the whole resource
+	streamSegment struct {
+		url    string
+		offset int64
+		length int64
+		key    *segmentKey
+	}
+
+	// streamPlaylist represents the ordered segments of the chosen variant
+	streamPlaylist struct {
+		init     *streamSegment
+		segments []streamSegment
+		ext      string // extension of the concatenated file
+	}
+)
+
+var resolutionHeight = regexp.MustCompile(`^(\d+)p$`)
+
+// isStreamURL report whether the url or the response points to a HLS/DASH manifest
+func isStreamURL(url string, header http.Header) bool {
+	u := url
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	switch strings.ToLower(path.Ext(u)) {
+	case ".m3u8", ".mpd":
+		return true
+	}
+
+	if header == nil {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	switch strings.ToLower(mediaType) {
+	case "application/vnd.apple.mpegurl", "application/x-mpegurl", "audio/mpegurl", "audio/x-mpegurl", "application/dash+xml":
+		return true
+	}
+	return false
+}
+
+// selectVariant return the index of the variant matching the selector: highest (default), lowest,
+// a resolution e.g: 1280x720, a height e.g: 720p or an exact bandwidth
+func selectVariant(variants []streamVariant, selector string) (int, error) {
+	if len(variants) == 0 {
+		return 0, errors.New("dl: stream has no variants")
+	}
+
+	order := make([]int, len(variants))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return variants[order[a]].bandwidth > variants[order[b]].bandwidth
+	})
+
+	selector = strings.ToLower(strings.TrimSpace(selector))
+	switch selector {
+	case "", "highest", "best":
+		return order[0], nil
+	case "lowest", "worst":
+		return order[len(order)-1], nil
+	}
+
+	for _, i := range order {
+		v := variants[i]
+		if selector == strings.ToLower(v.resolution) || selector == strconv.Itoa(v.bandwidth) {
+			return i, nil
+		}
+		if m := resolutionHeight.FindStringSubmatch(selector); m != nil && strings.HasSuffix(v.resolution, "x"+m[1]) {
+			return i, nil
+		}
+	}
+
+	available := make([]string, 0, len(order))
+	for _, i := range order {
+		available = append(available, fmt.Sprintf("%s@%d", variants[i].resolution, variants[i].bandwidth))
+	}
+	return 0, fmt.Errorf("dl: no variant matches %q, available: %s", selector, strings.Join(available, ", "))
+}
+
+// resolveReference resolve a possibly relative uri against the base url; the uri keeps the scheme of the base or
+// uses http(s), so a remote manifest can't read local files through file:// segments or keys
+func resolveReference(base, ref string) (string, error) {
+	b, err := netUrl.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := netUrl.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", err
+	}
+	u := b.ResolveReference(r)
+	if scheme := strings.ToLower(u.Scheme); scheme != strings.ToLower(b.Scheme) && scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("dl: %s manifest can't reference %s urls: %s", b.Scheme, u.Scheme, ref)
+	}
+	return u.String(), nil
+}
+
+// readResource read the whole resource e.g: manifests and keys
+func (d *DownloadManager) readResource(ctx context.Context, url string) ([]byte, error) {
+	body, err := d.openSegment(ctx, streamSegment{url: url, length: -1})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// openSegment return a reader of the segment using the fetcher of the url scheme
+func (d *DownloadManager) openSegment(ctx context.Context, seg streamSegment) (io.ReadCloser, error) {
+	f, err := newFetcher(seg.url, d.client)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, remote := f.(httpFetcher); remote && seg.length < 0 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, seg.url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP/GET request: %v", err)
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to perform HTTP/GET request: %w", err)
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to fetch %s: %s", seg.url, resp.Status)
+		}
+		return resp.Body, nil
+	}
+
+	if seg.length < 0 {
+		size, _, err := f.meta(ctx, seg.url)
+		if err != nil {
+			return nil, err
+		}
+		seg.offset, seg.length = 0, size
+	}
+	return f.fetch(ctx, seg.url, seg.offset, seg.offset+seg.length)
+}
+
+// resolveStream fetch the manifest, choose the variant and return its playlist
+func (d *DownloadManager) resolveStream(ctx context.Context, url string) (*streamPlaylist, error) {
+	data, err := d.readResource(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case isHLSPlaylist(data):
+		variants, err := parseHLSMaster(data, url)
+		if err != nil {
+			return nil, err
+		}
+		if len(variants) > 0 {
+			i, err := selectVariant(variants, d.option.variant)
+			if err != nil {
+				return nil, err
+			}
+			d.option.log.Info("selected HLS variant", "resolution", variants[i].resolution, "bandwidth", variants[i].bandwidth)
+			url = variants[i].uri
+			if data, err = d.readResource(ctx, url); err != nil {
+				return nil, err
+			}
+		}
+		return parseHLSMedia(data, url)
+	case isDASHManifest(data):
+		variants, build, err := parseDASH(data, url)
+		if err != nil {
+			return nil, err
+		}
+		i, err := selectDASHVariant(variants, d.option.variant)
+		if err != nil {
+			return nil, err
+		}
+		d.option.log.Info("selected DASH representation", "resolution", variants[i].resolution, "bandwidth", variants[i].bandwidth, "type", variants[i].kind)
+		if variants[i].kind != "audio" {
+			for _, v := range variants {
+				if v.kind == "audio" {
+					d.option.log.Warn("audio is in a separate adaptation set and isn't downloaded, use --variant audio to download it", "url", url)
+					break
+				}
+			}
+		}
+		return build(i)
+	}
+
+	return nil, errors.New("dl: unrecognized stream manifest")
+}
+
+// downloadSegments download the segments of the playlist concurrently in a temporary directory
+func (d *DownloadManager) downloadSegments(ctx context.Context, errCh chan error) {
+	segments := d.playlist.segments
+	if d.playlist.init != nil {
+		segments = append([]streamSegment{*d.playlist.init}, segments...)
+	}
+
+	keys := make(map[string][]byte)
+	keysMu := &sync.Mutex{}
+	getKey := func(uri string) ([]byte, error) {
+		keysMu.Lock()
+		defer keysMu.Unlock()
+		if k, ok := keys[uri]; ok {
+			return k, nil
+		}
+		k, err := d.readResource(ctx, uri)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch key: %v", err)
+		}
+		if len(k) != 16 {
+			return nil, fmt.Errorf("invalid AES-128 key length: %d", len(k))
+		}
+		keys[uri] = k
+		return k, nil
+	}
+
+	jobs := make(chan int, len(segments))
+	for i := range segments {
+		jobs <- i
+	}
+	close(jobs)
+
+	for w := 0; w < d.option.concurrency; w++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				seg := segments[i]
+				d.emit(Event{Type: ChunkStarted, Chunk: i})
+				var lastErr error
+				err := retryContext(ctx, 3, 200*time.Millisecond, func() error {
+					if lastErr != nil {
+						d.option.log.Warn("retrying segment", "segment", i, "error", lastErr)
+						atomic.AddInt32(&d.retries, 1)
+						d.emit(Event{Type: ChunkRetried, Chunk: i, Err: lastErr})
+					}
+					lastErr = d.downloadSegment(ctx, seg, filepath.Join(d.segmentDir, strconv.Itoa(i)), getKey)
+					return lastErr
+				})
+				if err != nil {
+					d.option.log.Error("failed to download segment", "segment", i, "url", seg.url, "error", err)
+					errCh <- err
+					return
+				}
+				atomic.AddInt32(&d.totalChunkCompleted, 1)
+				d.emit(Event{Type: ChunkDone, Chunk: i})
+			}
+		}()
+	}
+}
+
+// downloadSegment download and decrypt a single segment to dst
+func (d *DownloadManager) downloadSegment(ctx context.Context, seg streamSegment, dst string, getKey func(string) ([]byte, error)) error {
+	body, err := d.openSegment(ctx, seg)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	atomic.AddInt32(&d.connections, 1)
+	defer atomic.AddInt32(&d.connections, -1)
+
+	counter := uint64(0)
+	data, err := ioutil.ReadAll(Reader{body, &counter})
+	atomic.AddUint64(&d.totalDownloaded, counter)
+	if err != nil {
+		// discount partial progress; the segment will be downloaded again
+		atomic.AddUint64(&d.totalDownloaded, ^(counter - 1))
+		return err
+	}
+
+	if seg.key != nil {
+		key, err := getKey(seg.key.uri)
+		if err != nil {
+			return err
+		}
+		if data, err = decryptAES128(data, key, seg.key.iv); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(dst, data, 0644)
+}
+
+// mergeSegments concatenate the downloaded segments into the destination file
+func (d *DownloadManager) mergeSegments() error {
+	defer os.RemoveAll(d.segmentDir)
+
+	out, err := os.OpenFile(d.location, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	total := len(d.playlist.segments)
+	if d.playlist.init != nil {
+		total++
+	}
+
+	size := int64(0)
+	for i := 0; i < total; i++ {
+		f, err := os.Open(filepath.Join(d.segmentDir, strconv.Itoa(i)))
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		size += n
+	}
+	atomic.StoreUint64(&d.fileSize, uint64(size))
+	return nil
+}
+
+// decryptAES128 decrypt AES-128-CBC content with PKCS#7 padding
+func decryptAES128(data, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("encrypted segment is not a multiple of the block size")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+
+	if len(out) == 0 {
+		return out, nil
+	}
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(out) || !bytes.Equal(out[len(out)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, errors.New("invalid PKCS#7 padding")
+	}
+	return out[:len(out)-pad], nil
+}
+
+// streamFileName return the name of the concatenated file
+func streamFileName(url, ext string) string {
+	u := url
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	base := path.Base(u)
+	return strings.TrimSuffix(base, path.Ext(base)) + ext
+}
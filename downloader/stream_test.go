@@ -0,0 +1,192 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamServer serves the resources by path; the earlier segments are the slowest so they complete last
+func streamServer(t *testing.T, resources map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := resources[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		var n int
+		if _, err := fmt.Sscanf(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], "seg-%d", &n); err == nil {
+			time.Sleep(time.Duration(4-n%4) * 20 * time.Millisecond)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// segmentContent return distinct content for every segment so that a wrong order is detected
+func segmentContent(name string, n int) []byte {
+	return bytes.Repeat([]byte(name), n)
+}
+
+// encryptAES128 encrypt the content with AES-128-CBC and PKCS#7 padding like HLS
+func encryptAES128(t *testing.T, data, key, iv []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return out
+}
+
+func TestDownloadHLSMaster(t *testing.T) {
+	resources := map[string][]byte{
+		"/master.m3u8": []byte("#EXTM3U\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\nlow/index.m3u8\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720\nhigh/index.m3u8\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=1200000,RESOLUTION=854x480\nmid/index.m3u8\n"),
+	}
+	want := make(map[string][]byte)
+	for _, v := range []string{"low", "mid", "high"} {
+		playlist := "#EXTM3U\n#EXT-X-TARGETDURATION:4\n"
+		for i := 0; i < 6; i++ {
+			seg := segmentContent(fmt.Sprintf("%s-%d|", v, i), 1000+i)
+			resources[fmt.Sprintf("/%s/seg-%d.ts", v, i)] = seg
+			want[v] = append(want[v], seg...)
+			playlist += fmt.Sprintf("#EXTINF:4.0,\nseg-%d.ts\n", i)
+		}
+		resources["/"+v+"/index.m3u8"] = []byte(playlist + "#EXT-X-ENDLIST\n")
+	}
+	srv := streamServer(t, resources)
+
+	tests := map[string]string{"": "high", "highest": "high", "lowest": "low", "480p": "mid", "640x360": "low", "2400000": "high"}
+	for selector, variant := range tests {
+		testDownload(t, srv.URL+"/master.m3u8", want[variant], WithVariant(selector), WithConcurrency(4))
+	}
+	// a media playlist is downloaded as is
+	testDownload(t, srv.URL+"/mid/index.m3u8", want["mid"], WithConcurrency(4))
+
+	if _, err := New(WithFilePath(t.TempDir()), WithVariant("1080p")).DownloadContext(context.Background(), srv.URL+"/master.m3u8"); err == nil {
+		t.Fatal("expected an error for a missing variant")
+	}
+}
+
+func TestDownloadHLSEncrypted(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	iv := []byte("fedcba9876543210")
+	resources := map[string][]byte{"/keys/k1": key}
+
+	// the first segments use the explicit IV, the others the media sequence number starting at 7
+	playlist := "#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:7\n" +
+		fmt.Sprintf("#EXT-X-KEY:METHOD=AES-128,URI=\"/keys/k1\",IV=0x%x\n", iv)
+	var want []byte
+	for i := 0; i < 4; i++ {
+		if i == 2 {
+			playlist += "#EXT-X-KEY:METHOD=AES-128,URI=\"/keys/k1\"\n"
+		}
+		seg := segmentContent(fmt.Sprintf("segment %d|", i), 500+i)
+		want = append(want, seg...)
+		segIV := iv
+		if i >= 2 {
+			segIV = sequenceIV(int64(7 + i))
+		}
+		resources[fmt.Sprintf("/live/seg-%d.ts", i)] = encryptAES128(t, seg, key, segIV)
+		playlist += fmt.Sprintf("#EXTINF:4.0,\nseg-%d.ts\n", i)
+	}
+	resources["/live/index.m3u8"] = []byte(playlist + "#EXT-X-ENDLIST\n")
+	srv := streamServer(t, resources)
+
+	testDownload(t, srv.URL+"/live/index.m3u8", want, WithConcurrency(3))
+}
+
+func TestDownloadDASHTemplate(t *testing.T) {
+	manifest := `<?xml version="1.0"?>
+<MPD type="static" mediaPresentationDuration="PT10S">
+  <Period>
+    <BaseURL>media/</BaseURL>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate media="seg-$Number%03d$-$RepresentationID$.m4s" initialization="init-$RepresentationID$.mp4" startNumber="0" timescale="1000" duration="4000"/>
+      <Representation id="v1" bandwidth="500000" width="640" height="360"/>
+      <Representation id="v2" bandwidth="1500000" width="1280" height="720"/>
+    </AdaptationSet>
+    <AdaptationSet contentType="audio" mimeType="audio/mp4">
+      <Representation id="a1" bandwidth="64000">
+        <SegmentTemplate media="seg-$Time$-$Bandwidth$.m4a" initialization="init-a.mp4" timescale="10">
+          <SegmentTimeline>
+            <S t="0" d="40" r="1"/>
+            <S d="20" r="-1"/>
+          </SegmentTimeline>
+        </SegmentTemplate>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+	resources := map[string][]byte{"/stream/manifest.mpd": []byte(manifest)}
+	want := make(map[string][]byte)
+	add := func(variant, name string) {
+		b := segmentContent(name+"|", 700)
+		resources["/stream/media/"+name] = b
+		want[variant] = append(want[variant], b...)
+	}
+	for _, id := range []string{"v1", "v2"} {
+		// 10s of 4s segments numbered from 0
+		add(id, "init-"+id+".mp4")
+		for n := 0; n < 3; n++ {
+			add(id, fmt.Sprintf("seg-%03d-%s.m4s", n, id))
+		}
+	}
+	// two 4s segments then 2s ones until the end of the period at 10s
+	add("a1", "init-a.mp4")
+	for _, ts := range []int{0, 40, 80} {
+		add("a1", fmt.Sprintf("seg-%d-64000.m4a", ts))
+	}
+	srv := streamServer(t, resources)
+
+	tests := map[string]string{"": "v2", "360p": "v1", "audio": "a1", "audio:lowest": "a1"}
+	for selector, variant := range tests {
+		testDownload(t, srv.URL+"/stream/manifest.mpd", want[variant], WithVariant(selector), WithConcurrency(4))
+	}
+	if _, err := New(WithFilePath(t.TempDir()), WithVariant("audio:720p")).DownloadContext(context.Background(), srv.URL+"/stream/manifest.mpd"); err == nil {
+		t.Fatal("expected an error for a missing audio variant")
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	tests := []struct {
+		base, ref, want string
+		err             bool
+	}{
+		{base: "https://cdn.example.com/live/index.m3u8", ref: "seg-1.ts", want: "https://cdn.example.com/live/seg-1.ts"},
+		{base: "https://cdn.example.com/live/index.m3u8", ref: "/keys/k1", want: "https://cdn.example.com/keys/k1"},
+		{base: "https://cdn.example.com/live/index.m3u8", ref: "http://other.example.com/seg.ts", want: "http://other.example.com/seg.ts"},
+		{base: "file:///tmp/live/index.m3u8", ref: "seg-1.ts", want: "file:///tmp/live/seg-1.ts"},
+		{base: "file:///tmp/live/index.m3u8", ref: "https://cdn.example.com/seg.ts", want: "https://cdn.example.com/seg.ts"},
+		{base: "https://cdn.example.com/live/index.m3u8", ref: "file:///etc/passwd", err: true},
+		{base: "https://cdn.example.com/live/index.mpd", ref: "FILE:///etc/passwd", err: true},
+		{base: "https://cdn.example.com/live/index.m3u8", ref: "data:text/plain,secret", err: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveReference(tt.base, tt.ref)
+		if tt.err {
+			if err == nil {
+				t.Errorf("resolveReference(%q, %q) = %q, expected an error", tt.base, tt.ref, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveReference(%q, %q) = %q, %v, want %q", tt.base, tt.ref, got, err, tt.want)
+		}
+	}
+}
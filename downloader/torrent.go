@@ -0,0 +1,92 @@
+package downloader
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/thedevsaddam/dl/torrent"
+)
+
+// IsTorrentURL report whether the url is a magnet link or points to a .torrent file
+func IsTorrentURL(url string) bool {
+	return isTorrentURL(url)
+}
+
+// isTorrentURL report whether the url is a magnet link or points to a .torrent file
+func isTorrentURL(url string) bool {
+	if urlScheme(url) == "magnet" {
+		return true
+	}
+	u := url
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	return strings.ToLower(path.Ext(u)) == ".torrent"
+}
+
+// resolveTorrent fetch the torrent's meta information; for magnet links it's fetched from the peers
+func (d *DownloadManager) resolveTorrent(ctx context.Context, url string) error {
+	opt := torrent.Options{
+		SeedRatio: d.option.seedRatio,
+		Log:       d.option.log,
+	}
+
+	if urlScheme(url) == "magnet" {
+		m, err := torrent.ParseMagnet(url)
+		if err != nil {
+			return err
+		}
+		d.setTorrent(torrent.NewFromMagnet(m, opt))
+	} else {
+		data, err := d.readResource(ctx, url)
+		if err != nil {
+			return err
+		}
+		mi, err := torrent.ParseMetaInfo(data)
+		if err != nil {
+			return err
+		}
+		d.setTorrent(torrent.New(mi, opt))
+	}
+
+	mi, err := d.torrent.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if d.fileName == "" { // the name given by the user wins, e.g: -n
+		d.setFileName(mi.Name)
+	}
+	atomic.StoreUint64(&d.fileSize, uint64(mi.Length()))
+	d.setTotalChunks(len(mi.Pieces))
+	d.option.log.Info("resolved torrent", "name", mi.Name, "files", len(mi.Files), "pieces", len(mi.Pieces))
+	return nil
+}
+
+// torrentExt return the extension used to choose the sub-directory; the largest file decides for multi-file torrents
+func torrentExt(mi *torrent.MetaInfo) string {
+	if !mi.Multi {
+		return filepath.Ext(mi.Name)
+	}
+	largest := torrent.File{}
+	for _, f := range mi.Files {
+		if f.Length > largest.Length {
+			largest = f
+		}
+	}
+	return filepath.Ext(largest.Path)
+}
+
+// downloadTorrent download the torrent pieces to the location, the directory of a multi-file torrent
+func (d *DownloadManager) downloadTorrent(ctx context.Context, errCh chan error) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		if err := d.torrent.Download(ctx, d.location, &d.totalDownloaded, &d.totalChunkCompleted); err != nil {
+			d.option.log.Error("failed to download torrent", "error", err)
+			errCh <- err
+		}
+	}()
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/dl/torrent"
+)
+
+func TestDownloadTorrentFilename(t *testing.T) {
+	content := testContent()
+	const pieceLength = 256 << 10
+
+	// the server hosts the .torrent and tracks the seeder
+	var mu sync.Mutex
+	var seeder []byte
+	var metainfo string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/original.torrent" {
+			fmt.Fprint(w, metainfo)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		peers := string(seeder)
+		if seeder == nil {
+			port, _ := strconv.Atoi(r.URL.Query().Get("port"))
+			seeder = append(net.IPv4(127, 0, 0, 1).To4(), byte(port>>8), byte(port))
+		}
+		fmt.Fprintf(w, "d8:intervali30e5:peers%d:%se", len(peers), peers)
+	}))
+	defer srv.Close()
+
+	var pieces []byte
+	for i := 0; i < len(content); i += pieceLength {
+		end := i + pieceLength
+		if end > len(content) {
+			end = len(content)
+		}
+		h := sha1.Sum(content[i:end])
+		pieces = append(pieces, h[:]...)
+	}
+	announce := srv.URL + "/announce"
+	metainfo = fmt.Sprintf("d8:announce%d:%s4:infod6:lengthi%de4:name12:original.bin12:piece lengthi%de6:pieces%d:%see",
+		len(announce), announce, len(content), pieceLength, len(pieces), pieces)
+
+	mi, err := torrent.ParseMetaInfo([]byte(metainfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seed := filepath.Join(t.TempDir(), "original.bin")
+	if err := ioutil.WriteFile(seed, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := torrent.New(mi, torrent.Options{})
+	defer s.Close()
+	var progress uint64
+	var done int32
+	if err := s.Download(ctx, seed, &progress, &done); err != nil {
+		t.Fatal(err)
+	}
+
+	// the name given by the user wins over the name of the torrent
+	res := testDownload(t, srv.URL+"/original.torrent", content)
+	if filepath.Base(res.Path) != "file.bin" {
+		t.Fatalf("downloaded to %s, want file.bin", res.Path)
+	}
+}
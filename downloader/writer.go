@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultBufferSize is the write buffer of a chunk; large writes suit network file systems and spinning disks
+const defaultBufferSize = 256 << 10
+
+// bufferPool shares the write buffers of the chunks, retried and resumed chunks reuse them
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	p := &bufferPool{size: size}
+	p.pool.New = func() interface{} {
+		b := make([]byte, size)
+		return &b
+	}
+	return p
+}
+
+func (p *bufferPool) get() *[]byte {
+	return p.pool.Get().(*[]byte)
+}
+
+func (p *bufferPool) put(b *[]byte) {
+	p.pool.Put(b)
+}
+
+// chunkWriter buffers the content of a chunk and writes it at its offset of the shared file handle;
+// done counts the bytes written to the file only, a resumed chunk continues after them
+type chunkWriter struct {
+	f    *os.File
+	off  int64
+	buf  []byte
+	n    int // buffered bytes
+	done *uint64
+}
+
+// ReadFrom read into the buffer directly, io.Copy would copy through a buffer of its own otherwise
+func (w *chunkWriter) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		if w.n == len(w.buf) {
+			if err := w.flush(); err != nil {
+				return total, err
+			}
+		}
+		n, err := r.Read(w.buf[w.n:])
+		w.n += n
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+func (w *chunkWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		if w.n == len(w.buf) {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[w.n:], b)
+		w.n += n
+		written += n
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// flush write the buffered bytes to the file
+func (w *chunkWriter) flush() error {
+	if w.n == 0 {
+		return nil
+	}
+	n, err := w.f.WriteAt(w.buf[:w.n], w.off)
+	w.off += int64(n)
+	atomic.AddUint64(w.done, uint64(n))
+	copy(w.buf, w.buf[n:w.n])
+	w.n -= n
+	return err
+}
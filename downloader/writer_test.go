@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// BenchmarkWriteAt measure the chunk writes of a download by the size of the write buffer
+func BenchmarkWriteAt(b *testing.B) {
+	content := make([]byte, 64<<20)
+	rand.New(rand.NewSource(1)).Read(content)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	for _, size := range []int{32 << 10, 256 << 10, 4 << 20} {
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				dir := b.TempDir()
+				dm := New(WithFilePath(dir), WithSkipSubPathMap(), WithFilename("file.bin"), WithConcurrency(4), WithBufferSize(size))
+				if _, err := dm.DownloadContext(context.Background(), srv.URL+"/file.bin"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
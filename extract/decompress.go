@@ -0,0 +1,57 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Decompressed return the name of the decompressed file e.g: foo.txt for foo.txt.gz; ok is false if the extension
+// isn't one of .gz, .bz2, .xz or .zst
+func Decompressed(name string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if _, ok := decompressors[ext]; !ok || len(name) == len(ext) {
+		return name, false
+	}
+	return name[:len(name)-len(ext)], true
+}
+
+// Decompress write the content of the file compressed by the format of its extension to dst; dst is written
+// through a temporary file so that a failure doesn't leave a partial file behind
+func Decompress(src, dst string) (int64, error) {
+	open, ok := decompressors[strings.ToLower(filepath.Ext(src))]
+	if !ok {
+		return 0, fmt.Errorf("extract: unsupported compression: %s", filepath.Base(src))
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	r, err := open(in)
+	if err != nil {
+		return 0, fmt.Errorf("extract: %v", err)
+	}
+	defer r.Close()
+	return writeAtomic(dst, r)
+}
+
+// writeAtomic write the content of the reader to the path through a temporary file
+func writeAtomic(path string, r io.Reader) (int64, error) {
+	tmp := path + ".dl-tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("extract: %v", err)
+	}
+	return n, os.Rename(tmp, path)
+}
@@ -0,0 +1,338 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Options configures the extraction
+type Options struct {
+	StripComponents int // number of leading path components removed from the names e.g: the top directory
+}
+
+// decompressors maps the compression extensions to their decompressor
+var decompressors = map[string]func(r io.Reader) (io.ReadCloser, error){
+	".gz":  func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	".bz2": func(r io.Reader) (io.ReadCloser, error) { return ioutil.NopCloser(bzip2.NewReader(r)), nil },
+	".xz": func(r io.Reader) (io.ReadCloser, error) {
+		xr, err := xz.NewReader(r)
+		return ioutil.NopCloser(xr), err
+	},
+	".zst": func(r io.Reader) (io.ReadCloser, error) {
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	},
+}
+
+// tarFormats maps the extensions of the tar archives to their compression extension
+var tarFormats = []struct {
+	exts        []string
+	compression string // empty if it isn't compressed
+}{
+	{[]string{".tar"}, ""},
+	{[]string{".tar.gz", ".tgz"}, ".gz"},
+	{[]string{".tar.bz2", ".tbz2"}, ".bz2"},
+	{[]string{".tar.xz", ".txz"}, ".xz"},
+	{[]string{".tar.zst", ".tzst"}, ".zst"},
+}
+
+// Supported report whether the file name has the extension of a supported archive
+func Supported(name string) bool {
+	return isZip(name) || tarFormat(name) >= 0
+}
+
+func isZip(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".zip")
+}
+
+// tarFormat return the index of the tar format of the name; -1 if it isn't a tar archive
+func tarFormat(name string) int {
+	name = strings.ToLower(name)
+	for i, f := range tarFormats {
+		for _, ext := range f.exts {
+			if strings.HasSuffix(name, ext) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Extract extract the archive to the directory and return the number of extracted files;
+// the entries escaping the directory e.g: ../../etc/passwd, absolute paths or through symbolic links, are rejected
+func Extract(archive, dir string, opt Options) (int, error) {
+	if opt.StripComponents < 0 {
+		return 0, errors.New("extract: strip components can't be negative")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return 0, err
+	}
+	x := &extractor{dir: dir, strip: opt.StripComponents, dirs: make(map[string]bool)}
+
+	if isZip(archive) {
+		err = x.zip(archive)
+	} else if i := tarFormat(archive); i >= 0 {
+		err = x.tar(archive, tarFormats[i].compression)
+	} else {
+		err = fmt.Errorf("extract: unsupported archive: %s", filepath.Base(archive))
+	}
+	return x.files, err
+}
+
+// extractor writes the entries of an archive inside dir
+type extractor struct {
+	dir   string
+	strip int
+	dirs  map[string]bool // the directories known to be safe to write into
+	files int
+}
+
+func (x *extractor) tar(archive, compression string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := ioutil.NopCloser(f)
+	if compression != "" {
+		if r, err = decompressors[compression](f); err != nil {
+			return fmt.Errorf("extract: %v", err)
+		}
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("extract: %v", err)
+		}
+		target, ok, err := x.target(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = x.mkdir(target)
+		case tar.TypeReg, tar.TypeRegA:
+			err = x.writeFile(target, tr, hdr.FileInfo().Mode(), hdr.ModTime)
+		case tar.TypeSymlink:
+			err = x.symlink(target, hdr.Linkname)
+		case tar.TypeLink:
+			err = x.link(target, hdr.Linkname)
+		default:
+			// devices, fifos and the like aren't extracted
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *extractor) zip(archive string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("extract: %v", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		target, ok, err := x.target(f.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			if err := x.mkdir(target); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("extract: %v", err)
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			var linkname []byte
+			if linkname, err = ioutil.ReadAll(io.LimitReader(rc, 4096)); err == nil {
+				err = x.symlink(target, string(linkname))
+			}
+		} else {
+			err = x.writeFile(target, rc, f.Mode(), f.Modified)
+		}
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// target return the path of the entry name inside dir; ok is false for the entries removed by the strip components
+func (x *extractor) target(name string) (string, bool, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", false, fmt.Errorf("extract: unsafe path: %s", name)
+	}
+	var parts []string
+	for _, p := range strings.Split(name, "/") {
+		switch p {
+		case "", ".":
+		case "..":
+			return "", false, fmt.Errorf("extract: unsafe path: %s", name)
+		default:
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) <= x.strip {
+		return "", false, nil
+	}
+	return filepath.Join(x.dir, filepath.Join(parts[x.strip:]...)), true, nil
+}
+
+// inside report whether the path is inside dir
+func (x *extractor) inside(p string) bool {
+	rel, err := filepath.Rel(x.dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// mkdir create the directory and its parents; an existing symbolic link on the way is rejected,
+// an entry could write outside dir through it otherwise
+func (x *extractor) mkdir(p string) error {
+	if p == x.dir || x.dirs[p] {
+		return nil
+	}
+	if err := x.mkdir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	fi, err := os.Lstat(p)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := os.Mkdir(p, 0755); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case fi.Mode()&os.ModeSymlink != 0:
+		return fmt.Errorf("extract: unsafe path through symbolic link: %s", p)
+	case !fi.IsDir():
+		return fmt.Errorf("extract: not a directory: %s", p)
+	}
+	x.dirs[p] = true
+	return nil
+}
+
+// create prepare the path of a new entry replacing an existing file
+func (x *extractor) create(p string) error {
+	if err := x.mkdir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	if fi, err := os.Lstat(p); err == nil && !fi.IsDir() {
+		return os.Remove(p)
+	}
+	return nil
+}
+
+func (x *extractor) writeFile(p string, r io.Reader, mode os.FileMode, modTime time.Time) error {
+	if err := x.create(p); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("extract: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	x.files++
+	if !modTime.IsZero() {
+		os.Chtimes(p, modTime, modTime)
+	}
+	return nil
+}
+
+// symlink create the symbolic link; the target may only go up before going down e.g: ../a/b, a link on its way
+// would make the lexical check meaningless: with b -> . the target b/../../x is outside dir despite its look
+func (x *extractor) symlink(p, linkname string) error {
+	if filepath.IsAbs(linkname) || path.IsAbs(linkname) || backtracks(linkname) || !x.inside(filepath.Join(filepath.Dir(p), linkname)) {
+		return fmt.Errorf("extract: unsafe symbolic link: %s -> %s", p, linkname)
+	}
+	if err := x.create(p); err != nil {
+		return err
+	}
+	if err := os.Symlink(linkname, p); err != nil {
+		return err
+	}
+	x.files++
+	return nil
+}
+
+// backtracks report whether the link target goes up after going down a directory e.g: b/../x
+func backtracks(linkname string) bool {
+	down := false
+	for _, c := range strings.Split(strings.ReplaceAll(linkname, `\`, "/"), "/") {
+		switch c {
+		case "", ".":
+		case "..":
+			if down {
+				return true
+			}
+		default:
+			down = true
+		}
+	}
+	return false
+}
+
+func (x *extractor) link(p, linkname string) error {
+	target, ok, err := x.target(linkname)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("extract: hard link to a stripped entry: %s -> %s", p, linkname)
+	}
+	if err := x.create(p); err != nil {
+		return err
+	}
+	if err := os.Link(target, p); err != nil {
+		return err
+	}
+	x.files++
+	return nil
+}
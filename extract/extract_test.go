@@ -0,0 +1,318 @@
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// entry is a file of a test archive; a symbolic or hard link when link is set
+type entry struct {
+	name string
+	body string
+	dir  bool
+	link string
+	hard bool
+}
+
+// writeTar write the entries to a tar archive, gzip compressed for a .tar.gz name
+func writeTar(t *testing.T, fn string, entries []entry) {
+	t.Helper()
+	f, err := os.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w io.WriteCloser = f
+	if strings.HasSuffix(fn, ".gz") {
+		w = gzip.NewWriter(f)
+		defer w.Close()
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		switch {
+		case e.dir:
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0755, 0
+		case e.hard:
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, e.link, 0
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			io.WriteString(tw, e.body)
+		}
+	}
+}
+
+// writeZip write the entries to a zip archive; zip has no hard links
+func writeZip(t *testing.T, fn string, entries []entry) {
+	t.Helper()
+	f, err := os.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		switch {
+		case e.dir:
+			hdr.Name = strings.TrimSuffix(e.name, "/") + "/"
+			hdr.SetMode(os.ModeDir | 0755)
+		case e.link != "":
+			hdr.SetMode(os.ModeSymlink | 0777)
+			body = e.link
+		default:
+			hdr.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, body)
+	}
+}
+
+// tree return the files of the directory with their content, the target of the symbolic links prefixed by ->
+func tree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		switch {
+		case rel == ".":
+		case fi.Mode()&os.ModeSymlink != 0:
+			l, _ := os.Readlink(p)
+			files[rel] = "->" + l
+		case fi.IsDir():
+			files[rel+"/"] = ""
+		default:
+			b, _ := ioutil.ReadFile(p)
+			files[rel] = string(b)
+		}
+		return nil
+	})
+	return files
+}
+
+func treeString(files map[string]string) string {
+	var lines []string
+	for name, body := range files {
+		lines = append(lines, name+"="+body)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ",")
+}
+
+var project = []entry{
+	{name: "project-1.0/", dir: true},
+	{name: "project-1.0/README", body: "readme"},
+	{name: "project-1.0/bin/run", body: "run"},
+	{name: "./project-1.0/docs/guide.txt", body: "guide"},
+	{name: "project-1.0/docs/latest", link: "guide.txt"},
+	{name: "project-1.0/docs/up", link: "../README"},
+	{name: "project-1.0/LICENSE", link: "project-1.0/README", hard: true},
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		archive string
+		strip   int
+		files   int
+		want    map[string]string
+		err     bool // a link made unsafe by the strip components
+	}{
+		{
+			archive: "project.tar.gz", files: 6,
+			want: map[string]string{
+				"project-1.0/": "", "project-1.0/README": "readme", "project-1.0/LICENSE": "readme",
+				"project-1.0/bin/": "", "project-1.0/bin/run": "run",
+				"project-1.0/docs/": "", "project-1.0/docs/guide.txt": "guide", "project-1.0/docs/latest": "->guide.txt", "project-1.0/docs/up": "->../README",
+			},
+		},
+		{
+			archive: "project.tar", strip: 1, files: 6,
+			want: map[string]string{
+				"README": "readme", "LICENSE": "readme", "bin/": "", "bin/run": "run",
+				"docs/": "", "docs/guide.txt": "guide", "docs/latest": "->guide.txt", "docs/up": "->../README",
+			},
+		},
+		{
+			// up -> ../README points outside once docs/ is stripped
+			archive: "project.tar", strip: 2, files: 3, err: true,
+			want: map[string]string{"run": "run", "guide.txt": "guide", "latest": "->guide.txt"},
+		},
+		{archive: "project.tar", strip: 4, files: 0, want: map[string]string{}},
+		{
+			archive: "project.zip", strip: 1, files: 5,
+			want: map[string]string{
+				"README": "readme", "bin/": "", "bin/run": "run",
+				"docs/": "", "docs/guide.txt": "guide", "docs/latest": "->guide.txt", "docs/up": "->../README",
+			},
+		},
+	}
+	for _, tt := range tests {
+		fn := filepath.Join(t.TempDir(), tt.archive)
+		if strings.HasSuffix(fn, ".zip") {
+			writeZip(t, fn, project[:len(project)-1])
+		} else {
+			writeTar(t, fn, project)
+		}
+		dir := filepath.Join(t.TempDir(), "out")
+		n, err := Extract(fn, dir, Options{StripComponents: tt.strip})
+		if (err != nil) != tt.err {
+			t.Fatalf("%s, strip %d: error %v, want %v", tt.archive, tt.strip, err, tt.err)
+		}
+		if n != tt.files {
+			t.Errorf("%s, strip %d: extracted %d files, want %d", tt.archive, tt.strip, n, tt.files)
+		}
+		if got := tree(t, dir); treeString(got) != treeString(tt.want) {
+			t.Errorf("%s, strip %d: extracted\n%s\nwant\n%s", tt.archive, tt.strip, treeString(got), treeString(tt.want))
+		}
+	}
+
+	fn := filepath.Join(t.TempDir(), "project.tar")
+	writeTar(t, fn, project)
+	if _, err := Extract(fn, t.TempDir(), Options{StripComponents: -1}); err == nil {
+		t.Error("expected an error for negative strip components")
+	}
+	if _, err := Extract(filepath.Join(t.TempDir(), "project.rar"), t.TempDir(), Options{}); err == nil {
+		t.Error("expected an error for an unsupported archive")
+	}
+	// a hard link to a stripped entry has nothing to link to
+	writeTar(t, fn, []entry{{name: "README", body: "readme"}, {name: "sub/LICENSE", link: "README", hard: true}})
+	if _, err := Extract(fn, t.TempDir(), Options{StripComponents: 1}); err == nil {
+		t.Error("expected an error for a hard link to a stripped entry")
+	}
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		zip     bool
+	}{
+		{name: "parent", entries: []entry{{name: "../evil", body: "evil"}}},
+		{name: "nested parent", entries: []entry{{name: "a/../../evil", body: "evil"}}},
+		{name: "parent of zip", zip: true, entries: []entry{{name: "../evil", body: "evil"}}},
+		{name: "backslash parent of zip", zip: true, entries: []entry{{name: `a\..\..\evil`, body: "evil"}}},
+		{name: "absolute", entries: []entry{{name: "/tmp/evil", body: "evil"}}},
+		{name: "absolute of zip", zip: true, entries: []entry{{name: "/tmp/evil", body: "evil"}}},
+		{name: "symbolic link outside", entries: []entry{{name: "l", link: "../evil"}}},
+		{name: "absolute symbolic link", entries: []entry{{name: "l", link: "/etc"}}},
+		{name: "symbolic link outside of zip", zip: true, entries: []entry{{name: "l", link: "../evil"}}},
+		{name: "absolute symbolic link of zip", zip: true, entries: []entry{{name: "l", link: "/etc"}}},
+		{
+			// b looks like out/secret but sub/up -> .. makes it the secret next to out
+			name: "chained symbolic links",
+			entries: []entry{
+				{name: "sub/", dir: true},
+				{name: "sub/up", link: ".."},
+				{name: "b", link: "sub/up/../../secret"},
+			},
+		},
+		{
+			name: "chained symbolic links of zip", zip: true,
+			entries: []entry{
+				{name: "sub/", dir: true},
+				{name: "sub/up", link: ".."},
+				{name: "b", link: "sub/up/../../secret"},
+			},
+		},
+		{
+			name: "write through a symbolic link",
+			entries: []entry{
+				{name: "sub/", dir: true},
+				{name: "l", link: "sub"},
+				{name: "l/evil", body: "evil"},
+			},
+		},
+		{
+			name: "write through a symbolic link of zip", zip: true,
+			entries: []entry{
+				{name: "sub/", dir: true},
+				{name: "l", link: "sub"},
+				{name: "l/evil", body: "evil"},
+			},
+		},
+		{name: "hard link outside", entries: []entry{{name: "h", link: "../secret", hard: true}}},
+		{name: "absolute hard link", entries: []entry{{name: "h", link: "/etc/passwd", hard: true}}},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		if err := ioutil.WriteFile(filepath.Join(root, "secret"), []byte("secret"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fn := filepath.Join(t.TempDir(), "evil.tar")
+		if tt.zip {
+			fn = filepath.Join(t.TempDir(), "evil.zip")
+			writeZip(t, fn, tt.entries)
+		} else {
+			writeTar(t, fn, tt.entries)
+		}
+		dir := filepath.Join(root, "out")
+		if _, err := Extract(fn, dir, Options{}); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if got := treeString(tree(t, root)); strings.Contains(got, "evil") || strings.Contains(got, "out/h=") || strings.Contains(got, "out/b=") {
+			t.Errorf("%s: extracted %s", tt.name, got)
+		}
+		if b, _ := ioutil.ReadFile(filepath.Join(root, "secret")); string(b) != "secret" {
+			t.Errorf("%s: changed the file outside", tt.name)
+		}
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "notes.txt.gz")
+	f, _ := os.Create(src)
+	zw := gzip.NewWriter(f)
+	io.WriteString(zw, "notes")
+	zw.Close()
+	f.Close()
+
+	dst, ok := Decompressed(src)
+	if !ok || dst != filepath.Join(dir, "notes.txt") {
+		t.Fatalf("Decompressed = %s, %v", dst, ok)
+	}
+	if n, err := Decompress(src, dst); err != nil || n != 5 {
+		t.Fatalf("Decompress = %d, %v", n, err)
+	}
+	if b, _ := ioutil.ReadFile(dst); string(b) != "notes" {
+		t.Fatalf("decompressed %q", b)
+	}
+	for _, name := range []string{"notes.txt", ".gz", "notes.zip"} {
+		if _, ok := Decompressed(name); ok {
+			t.Errorf("Decompressed(%s) is ok", name)
+		}
+	}
+
+	// a corrupt file leaves nothing behind
+	ioutil.WriteFile(src, []byte("not gzip"), 0644)
+	os.Remove(dst)
+	if _, err := Decompress(src, dst); err == nil {
+		t.Fatal("expected an error for a corrupt file")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatalf("partial file left: %v", err)
+	}
+}
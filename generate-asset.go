@@ -10,13 +10,21 @@ import (
 )
 
 func main() {
-	var fs http.FileSystem = http.Dir("./assets/")
-	err := vfsgen.Generate(fs, vfsgen.Options{
-		Filename:     "./notifier/asset.go",
-		PackageName:  "notifier",
-		VariableName: "AssetFS",
-	})
-	if err != nil {
-		log.Fatalln(err)
+	assets := []struct {
+		dir, filename, pkg, variable string
+	}{
+		{"./assets/notifier/", "./notifier/asset.go", "notifier", "AssetFS"},
+		{"./assets/web/", "./daemon/asset.go", "daemon", "WebFS"}, // web UI of dl serve
+	}
+	for _, a := range assets {
+		var fs http.FileSystem = http.Dir(a.dir)
+		err := vfsgen.Generate(fs, vfsgen.Options{
+			Filename:     a.filename,
+			PackageName:  a.pkg,
+			VariableName: a.variable,
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
 	}
 }
@@ -7,9 +7,10 @@ require (
 	github.com/briandowns/spinner v1.16.0
 	github.com/gen2brain/beeep v0.0.0-20210529141713-5586760f0cc1
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0
+	github.com/klauspost/compress v1.15.9
 	github.com/schollz/progressbar/v3 v3.8.3
 	github.com/shurcooL/httpfs v0.0.0-20190707220628-8d4bc4ba7749 // indirect
 	github.com/shurcooL/vfsgen v0.0.0-20200824052919-0d455de96546
 	github.com/spf13/cobra v1.2.1
-	github.com/thedevsaddam/retry v0.0.0-20200324223450-9769a859cc6d
+	github.com/ulikunitz/xz v0.5.12
 )
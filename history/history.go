@@ -0,0 +1,229 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	netUrl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thedevsaddam/dl/downloader"
+	"github.com/thedevsaddam/dl/logger"
+)
+
+const historyFileName = "history.jsonl"
+
+// ErrNotFound is returned for an unknown entry id
+var ErrNotFound = errors.New("history: entry not found")
+
+// Status represents the outcome of a download
+type Status string
+
+const (
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// Entry represents a finished download
+type Entry struct {
+	ID         int           `json:"-"` // line number in the history file
+	URL        string        `json:"url"`
+	Location   string        `json:"location,omitempty"` // where the file is stored; empty if it failed before the download started
+	Size       uint64        `json:"size"`
+	Digest     string        `json:"digest,omitempty"` // hex encoded SHA-256
+	Status     Status        `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Host return the host of the url; the scheme if it has none e.g: magnet
+func (e Entry) Host() string {
+	u, err := netUrl.Parse(e.URL)
+	if err != nil {
+		return ""
+	}
+	if u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return u.Scheme
+}
+
+// Filter selects the entries of List; the zero value selects every entry
+type Filter struct {
+	Since  time.Time // finished at or after
+	Until  time.Time // finished before
+	Host   string    // the host or its sub-domains
+	Status Status
+	Text   string // case insensitive search in the url, location, digest and error
+	Limit  int    // the most recent entries only
+}
+
+func (f Filter) match(e Entry) bool {
+	if !f.Since.IsZero() && e.FinishedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.FinishedAt.Before(f.Until) {
+		return false
+	}
+	if f.Host != "" {
+		host, want := e.Host(), strings.ToLower(f.Host)
+		if host != want && !strings.HasSuffix(host, "."+want) {
+			return false
+		}
+	}
+	if f.Status != "" && e.Status != f.Status {
+		return false
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		for _, s := range []string{e.URL, e.Location, e.Digest, e.Error} {
+			if strings.Contains(strings.ToLower(s), text) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Store persists the entries as JSON lines; appending a line needs no coordination between the processes
+type Store struct {
+	path string
+}
+
+// New return the store of the directory e.g: ~/.dl
+func New(dir string) *Store {
+	return &Store{path: filepath.Join(dir, historyFileName)}
+}
+
+// Add append the entry to the history
+func (s *Store) Add(e Entry) error {
+	bb, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	// a line broken by a crash is ended first, the entry would be lost along with it otherwise
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			bb = append([]byte{'\n'}, bb...)
+		}
+	}
+	// a single write keeps the lines of concurrent processes apart
+	if _, err := f.Write(append(bb, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// List return the entries matching the filter, oldest first
+func (s *Store) List(f Filter) ([]Entry, error) {
+	var entries []Entry
+	err := s.scan(func(e Entry) bool {
+		if f.match(e) {
+			entries = append(entries, e)
+		}
+		return true
+	})
+	if f.Limit > 0 && len(entries) > f.Limit {
+		entries = entries[len(entries)-f.Limit:]
+	}
+	return entries, err
+}
+
+// Get return the entry of the id
+func (s *Store) Get(id int) (Entry, error) {
+	var found *Entry
+	err := s.scan(func(e Entry) bool {
+		if e.ID == id {
+			found = &e
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	if found == nil {
+		return Entry{}, ErrNotFound
+	}
+	return *found, nil
+}
+
+// scan call fn with every entry until it returns false; a line broken by a crash keeps its id
+func (s *Store) scan(fn func(e Entry) bool) error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for id := 1; ; id++ {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var e Entry
+			if json.Unmarshal(line, &e) == nil {
+				e.ID = id
+				if !fn(e) {
+					return nil
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Recorder return a progress handler adding the outcome of the download of the url to the history;
+// cancelled downloads aren't recorded
+func (s *Store) Recorder(url string, log logger.LevelLogger) func(downloader.Event) {
+	startedAt := time.Now()
+	return func(ev downloader.Event) {
+		e := Entry{
+			URL:        url,
+			Location:   ev.Stats.Location,
+			Size:       ev.Stats.Downloaded,
+			StartedAt:  startedAt,
+			FinishedAt: time.Now(),
+		}
+		switch {
+		case ev.Type == downloader.Completed:
+			e.Status = StatusCompleted
+			e.Location = ev.Result.Path
+			e.Size = ev.Result.Size
+			e.Digest = ev.Result.Digest
+		case ev.Type == downloader.Failed && !errors.Is(ev.Err, context.Canceled):
+			e.Status = StatusFailed
+			e.Error = ev.Err.Error()
+		default:
+			return
+		}
+		e.Duration = e.FinishedAt.Sub(startedAt)
+		if err := s.Add(e); err != nil {
+			log.Error("failed to save history", "url", url, "error", err)
+		}
+	}
+}
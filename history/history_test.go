@@ -0,0 +1,161 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/dl/downloader"
+	"github.com/thedevsaddam/dl/logger"
+)
+
+var day = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// testEntries are finished a day apart from the 1st of June
+var testEntries = []Entry{
+	{URL: "https://example.com/a.iso", Location: "/data/a.iso", Size: 100, Digest: "aaa111", Status: StatusCompleted},
+	{URL: "https://cdn.Example.com/b.zip", Location: "/data/b.zip", Size: 200, Digest: "bbb222", Status: StatusCompleted},
+	{URL: "https://other.org/c.tar.gz", Status: StatusFailed, Error: "404 Not Found"},
+	{URL: "magnet:?xt=urn:btih:abc&dn=Movie", Location: "/data/Movie", Size: 300, Status: StatusCompleted},
+	{URL: "https://notexample.com/d.bin", Status: StatusFailed, Error: "connection reset"},
+}
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	s := New(filepath.Join(t.TempDir(), ".dl"))
+	for i, e := range testEntries {
+		e.StartedAt = day.AddDate(0, 0, i).Add(-time.Minute)
+		e.FinishedAt = day.AddDate(0, 0, i)
+		e.Duration = time.Minute
+		if err := s.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func entryIDs(entries []Entry) string {
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = fmt.Sprint(e.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestStore(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), ".dl"))
+	if entries, err := s.List(Filter{}); err != nil || len(entries) != 0 {
+		t.Fatalf("listed %v, %v without a history", entries, err)
+	}
+	if _, err := s.Get(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get without a history: %v", err)
+	}
+
+	s = testStore(t)
+	entries, err := s.List(Filter{})
+	if err != nil || entryIDs(entries) != "1,2,3,4,5" {
+		t.Fatalf("listed %s, %v", entryIDs(entries), err)
+	}
+	e, err := s.Get(2)
+	if err != nil || e.URL != testEntries[1].URL || e.Size != 200 || !e.FinishedAt.Equal(day.AddDate(0, 0, 1)) || e.Duration != time.Minute {
+		t.Fatalf("Get(2) = %+v, %v", e, err)
+	}
+	if _, err := s.Get(6); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(6): %v", err)
+	}
+
+	// a line broken by a crash is skipped and keeps its id
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"url":"https://example.com/bro`)
+	f.Close()
+	if err := s.Add(Entry{URL: "https://example.com/e.bin", Status: StatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := s.Get(7); err != nil || e.URL != "https://example.com/e.bin" {
+		t.Fatalf("Get(7) = %+v, %v, want the entry added after the broken line", e, err)
+	}
+	if entries, _ := s.List(Filter{Limit: 2}); entryIDs(entries) != "5,7" {
+		t.Fatalf("listed %s, want the 2 most recent", entryIDs(entries))
+	}
+}
+
+func TestFilter(t *testing.T) {
+	s := testStore(t)
+	tests := []struct {
+		f    Filter
+		want string
+	}{
+		{f: Filter{Since: day.AddDate(0, 0, 2)}, want: "3,4,5"},
+		{f: Filter{Until: day.AddDate(0, 0, 2)}, want: "1,2"},
+		{f: Filter{Since: day.AddDate(0, 0, 1), Until: day.AddDate(0, 0, 3)}, want: "2,3"},
+		{f: Filter{Host: "example.com"}, want: "1,2"},
+		{f: Filter{Host: "CDN.example.com"}, want: "2"},
+		{f: Filter{Host: "magnet"}, want: "4"},
+		{f: Filter{Status: StatusFailed}, want: "3,5"},
+		{f: Filter{Status: StatusCompleted, Limit: 2}, want: "2,4"},
+		{f: Filter{Text: "MOVIE"}, want: "4"},
+		{f: Filter{Text: "bbb2"}, want: "2"},
+		{f: Filter{Text: "not found"}, want: "3"},
+		{f: Filter{Text: "/data/"}, want: "1,2,4"},
+		{f: Filter{Host: "example.com", Status: StatusFailed}, want: ""},
+		{f: Filter{Text: "example", Status: StatusFailed}, want: "5"},
+	}
+	for _, tt := range tests {
+		entries, err := s.List(tt.f)
+		if err != nil || entryIDs(entries) != tt.want {
+			t.Errorf("%+v: listed %s, %v, want %s", tt.f, entryIDs(entries), err, tt.want)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), ".dl"))
+	complete := s.Recorder("https://example.com/a.iso", logger.New(false))
+	complete(downloader.Event{Type: downloader.ChunkDone, Stats: downloader.Stats{Downloaded: 50}})
+	complete(downloader.Event{Type: downloader.Completed, Result: &downloader.Result{Path: "/data/a.iso", Size: 100, Digest: "aaa"}})
+	fail := s.Recorder("https://example.com/b.iso", logger.New(false))
+	fail(downloader.Event{Type: downloader.Failed, Err: errors.New("404 Not Found"), Stats: downloader.Stats{Location: "/data/b.iso", Downloaded: 10}})
+	cancel := s.Recorder("https://example.com/c.iso", logger.New(false))
+	cancel(downloader.Event{Type: downloader.Failed, Err: fmt.Errorf("fetching: %w", context.Canceled)})
+
+	entries, err := s.List(Filter{})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("recorded %+v, %v, want the completed and failed downloads", entries, err)
+	}
+	if e := entries[0]; e.Status != StatusCompleted || e.Location != "/data/a.iso" || e.Size != 100 || e.Digest != "aaa" || e.Error != "" {
+		t.Errorf("completed %+v", e)
+	}
+	if e := entries[1]; e.Status != StatusFailed || e.Location != "/data/b.iso" || e.Size != 10 || e.Error != "404 Not Found" {
+		t.Errorf("failed %+v", e)
+	}
+	for _, e := range entries {
+		if e.StartedAt.IsZero() || e.FinishedAt.Before(e.StartedAt) || e.Duration <= 0 || e.Duration > time.Second {
+			t.Errorf("times %+v", e)
+		}
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), ".dl"))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.Add(Entry{URL: fmt.Sprintf("https://example.com/%d.bin", i), Error: strings.Repeat("x", 4096), Status: StatusFailed})
+		}(i)
+	}
+	wg.Wait()
+	if entries, err := s.List(Filter{}); err != nil || len(entries) != 20 {
+		t.Fatalf("listed %d entries, %v, want 20", len(entries), err)
+	}
+}
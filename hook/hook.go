@@ -0,0 +1,121 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thedevsaddam/dl/logger"
+)
+
+// DefaultTimeout is the time a command may run if no timeout is set
+const DefaultTimeout = time.Minute
+
+// maxOutput is the size of the output of a command kept for the log
+const maxOutput = 64 << 10
+
+// Vars are the values of the placeholders: {path}, {name}, {size}, {url}, {digest} and {errors}
+type Vars struct {
+	Path   string
+	Name   string
+	Size   uint64
+	URL    string
+	Digest string // hex encoded SHA-256; empty for a failed download
+	Errors string // the errors of a failed download
+}
+
+// Expand replace the placeholders of the command by the values quoted for the shell,
+// so a name sent by the server e.g: "a.txt; rm -rf ~" stays a single argument
+func Expand(cmd string, v Vars) string {
+	return strings.NewReplacer(
+		"{path}", quote(v.Path),
+		"{name}", quote(v.Name),
+		"{size}", strconv.FormatUint(v.Size, 10),
+		"{url}", quote(v.URL),
+		"{digest}", quote(v.Digest),
+		"{errors}", quote(v.Errors),
+	).Replace(cmd)
+}
+
+// Runner runs the commands of the hooks through the shell: sh on Unix, cmd on Windows
+type Runner struct {
+	Timeout time.Duration // the command is killed after; 0 means DefaultTimeout
+	Log     logger.LevelLogger
+}
+
+// Run run the commands one after another with the placeholders expanded; a failed command is logged
+// and doesn't stop the next ones
+func (r Runner) Run(ctx context.Context, cmds []string, v Vars) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	for _, c := range cmds {
+		if strings.TrimSpace(c) == "" {
+			continue
+		}
+		c = Expand(c, v)
+		r.Log.Debug("running hook", "command", c)
+
+		startedAt := time.Now()
+		output, err := run(ctx, c, timeout)
+		kv := []interface{}{"command", c, "duration", time.Since(startedAt).Round(time.Millisecond)}
+		if out := strings.TrimSpace(output); out != "" {
+			kv = append(kv, "output", out)
+		}
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			r.Log.Error("hook timed out", append(kv, "timeout", timeout)...)
+		case err != nil:
+			r.Log.Error("hook failed", append(kv, "error", err)...)
+		default:
+			r.Log.Info("hook completed", kv...)
+		}
+	}
+}
+
+// limitedBuffer keeps the first bytes written to it and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.max - b.Len(); n > 0 {
+		if len(p) > n {
+			b.Buffer.Write(p[:n])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// run run the command through the shell and return its combined output; the command along with the processes
+// it started are killed once the timeout is reached or the context is cancelled
+func run(ctx context.Context, command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := shell(command)
+	out := &limitedBuffer{max: maxOutput}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return out.String(), err
+	case <-ctx.Done():
+		kill(cmd)
+		<-done
+		return out.String(), ctx.Err()
+	}
+}
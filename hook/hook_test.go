@@ -0,0 +1,116 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thedevsaddam/dl/logger"
+)
+
+// testRunner return a runner logging to the buffer
+func testRunner(timeout time.Duration) (Runner, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	return Runner{Timeout: timeout, Log: logger.NewWriter(buf, logger.Options{Level: logger.LevelInfo})}, buf
+}
+
+func TestExpand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh quoting")
+	}
+	v := Vars{Path: "/tmp/it's.txt", Name: "it's.txt", Size: 42, URL: "https://example.com/it's.txt", Digest: "abc"}
+	got := Expand("mv {path} /done/{name} # {size} {url} {digest} {errors} {unknown}", v)
+	want := `mv '/tmp/it'\''s.txt' /done/'it'\''s.txt' # 42 'https://example.com/it'\''s.txt' 'abc' '' {unknown}`
+	if got != want {
+		t.Fatalf("Expand = %s, want %s", got, want)
+	}
+}
+
+func TestRunQuoting(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh quoting")
+	}
+	dir := t.TempDir()
+	pwned := filepath.Join(dir, "pwned")
+	// the names sent by the server stay a single argument, nothing of them is run
+	names := []string{
+		"it's.txt",
+		"a.txt; touch " + pwned,
+		"$(touch " + pwned + ").txt",
+		"`touch " + pwned + "`.txt",
+		"'; touch " + pwned + "; '",
+		`"$HOME" \n *.txt`,
+	}
+	r, log := testRunner(0)
+	for _, name := range names {
+		fn := filepath.Join(dir, "out")
+		r.Run(context.Background(), []string{"printf '%s|' {name} > " + quote(fn)}, Vars{Name: name})
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			t.Fatalf("%q: %v\n%s", name, err, log)
+		}
+		if string(b) != name+"|" {
+			t.Errorf("%q: the command got %q", name, b)
+		}
+	}
+	if _, err := os.Stat(pwned); !os.IsNotExist(err) {
+		t.Fatalf("a name ran a command: %v", err)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh commands")
+	}
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	r, log := testRunner(200 * time.Millisecond)
+
+	startedAt := time.Now()
+	r.Run(context.Background(), []string{
+		// the background process is killed along with the command
+		"(sleep 1; touch " + quote(marker) + ") & sleep 10",
+		"exit 3",
+		"  ",
+		"echo done",
+	}, Vars{})
+	if d := time.Since(startedAt); d > 5*time.Second {
+		t.Fatalf("ran for %s", d)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("the process started by the command outlived the timeout: %v", err)
+	}
+
+	// a failed command doesn't stop the next ones
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("logged %d entries, want 3:\n%s", len(lines), log)
+	}
+	for i, want := range []string{"hook timed out", "hook failed", "hook completed"} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("entry %d: %s, want %s", i, lines[i], want)
+		}
+	}
+	if !strings.Contains(lines[2], "output=done") {
+		t.Errorf("output missing: %s", lines[2])
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{max: 5}
+	for _, s := range []string{"abc", "defg", "hij"} {
+		if n, err := b.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%s) = %d, %v", s, n, err)
+		}
+	}
+	if b.String() != "abcde" {
+		t.Fatalf("kept %q", b.String())
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package hook
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+func shell(command string) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", command)
+	// a process group of its own, the processes started by the command are killed along with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+func kill(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// quote return the string as a single quoted sh word
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,23 @@
+//go:build windows
+// +build windows
+
+package hook
+
+import (
+	"os/exec"
+	"strings"
+)
+
+func shell(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+func kill(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// quote return the string as a double quoted cmd argument; the characters cmd would interpret are dropped
+func quote(s string) string {
+	s = strings.NewReplacer(`"`, "", "%", "", "^", "", "!", "").Replace(s)
+	return `"` + s + `"`
+}
@@ -1,33 +1,248 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Logger is the logger taken by the options e.g: downloader.WithLogger; the loggers implementing LevelLogger
+// as well get the leveled entries, the others get them formatted as text through Printf
 type Logger interface {
 	Println(v ...interface{})
 	Printf(format string, v ...interface{})
 }
 
-// defautlLogger implements the logger contract
-type defautlLogger struct {
-	verbose bool
+// LevelLogger writes leveled entries; kv are alternating keys and values e.g: "chunk", 2, "url", url
+type LevelLogger interface {
+	Logger // Println and Printf write an info entry
+
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+	// With return a logger adding the key values to every entry
+	With(kv ...interface{}) LevelLogger
+}
+
+// Leveled return the logger as a LevelLogger; a plain Logger gets every entry as a line of text,
+// a nil one discards everything
+func Leveled(l Logger) LevelLogger {
+	switch l := l.(type) {
+	case nil:
+		return New(false)
+	case LevelLogger:
+		return l
+	default:
+		return &printLogger{l: l}
+	}
+}
+
+// Level represents the severity of an entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel return the level of the name e.g: debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("logger: invalid level %q, must be one of debug, info, warn or error", name)
+}
+
+// Format represents the encoding of the entries
+type Format string
+
+const (
+	FormatText Format = "text" // time level message key=value...
+	FormatJSON Format = "json" // one JSON object per line
+)
+
+// ParseFormat return the format of the name e.g: text or json
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("logger: invalid format %q, must be text or json", name)
+}
+
+// Options configures the logger of NewWriter
+type Options struct {
+	Level  Level  // entries below the level are dropped
+	Format Format // FormatText by default
+}
+
+// sink is shared by a logger and the loggers derived through With
+type sink struct {
+	mu  sync.Mutex
+	w   io.Writer // nil discards everything
+	opt Options
+}
+
+// structuredLogger implements the logger contract
+type structuredLogger struct {
+	sink   *sink
+	fields []interface{}
+}
+
+// New return a default implementation of custom logger; verbose writes every entry as text to stderr
+func New(verbose bool) LevelLogger {
+	if !verbose {
+		return &structuredLogger{sink: &sink{}}
+	}
+	return NewWriter(os.Stderr, Options{Level: LevelDebug})
+}
+
+// NewWriter return a logger writing the entries to w; the writes are serialized
+func NewWriter(w io.Writer, opt Options) LevelLogger {
+	if opt.Format == "" {
+		opt.Format = FormatText
+	}
+	return &structuredLogger{sink: &sink{w: w, opt: opt}}
+}
+
+func (l *structuredLogger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+func (l *structuredLogger) Info(msg string, kv ...interface{})  { l.log(LevelInfo, msg, kv) }
+func (l *structuredLogger) Warn(msg string, kv ...interface{})  { l.log(LevelWarn, msg, kv) }
+func (l *structuredLogger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+func (l *structuredLogger) With(kv ...interface{}) LevelLogger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	return &structuredLogger{sink: l.sink, fields: append(fields, kv...)}
+}
+
+func (l *structuredLogger) Println(v ...interface{}) {
+	l.log(LevelInfo, strings.TrimSuffix(fmt.Sprintln(v...), "\n"), nil)
+}
+
+func (l *structuredLogger) Printf(format string, v ...interface{}) {
+	l.log(LevelInfo, strings.TrimSuffix(fmt.Sprintf(format, v...), "\n"), nil)
+}
+
+func (l *structuredLogger) log(level Level, msg string, kv []interface{}) {
+	s := l.sink
+	if s.w == nil || level < s.opt.Level {
+		return
+	}
+	if len(l.fields) > 0 {
+		kv = append(l.fields[:len(l.fields):len(l.fields)], kv...)
+	}
+
+	var b bytes.Buffer
+	now := time.Now()
+	if s.opt.Format == FormatJSON {
+		b.WriteString(`{"time":`)
+		writeJSON(&b, now.Format(time.RFC3339Nano))
+		b.WriteString(`,"level":`)
+		writeJSON(&b, level.String())
+		b.WriteString(`,"msg":`)
+		writeJSON(&b, msg)
+		for i := 0; i < len(kv); i += 2 {
+			b.WriteByte(',')
+			writeJSON(&b, fmt.Sprint(kv[i]))
+			b.WriteByte(':')
+			writeJSON(&b, value(kv, i+1))
+		}
+		b.WriteString("}\n")
+	} else {
+		b.WriteString(now.Format("2006-01-02T15:04:05.000Z07:00"))
+		b.WriteByte(' ')
+		writeText(&b, level, msg, kv)
+		b.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	s.w.Write(b.Bytes())
+	s.mu.Unlock()
+}
+
+// writeText write the level, the message and the key values e.g: INFO chunk done chunk=2 bytes=1024
+func writeText(b *bytes.Buffer, level Level, msg string, kv []interface{}) {
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(kv); i += 2 {
+		fmt.Fprintf(b, " %s=%s", kv[i], quote(fmt.Sprint(value(kv, i+1))))
+	}
 }
 
-// New return a default implementation of custom logger
-func New(verbose bool) Logger {
-	return defautlLogger{verbose: verbose}
+// printLogger adapts a plain Logger; it has no level of its own, the logger decides what to print
+type printLogger struct {
+	l      Logger
+	fields []interface{}
+}
+
+func (l *printLogger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+func (l *printLogger) Info(msg string, kv ...interface{})  { l.log(LevelInfo, msg, kv) }
+func (l *printLogger) Warn(msg string, kv ...interface{})  { l.log(LevelWarn, msg, kv) }
+func (l *printLogger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+func (l *printLogger) With(kv ...interface{}) LevelLogger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	return &printLogger{l: l.l, fields: append(fields, kv...)}
+}
+
+func (l *printLogger) Println(v ...interface{})               { l.l.Println(v...) }
+func (l *printLogger) Printf(format string, v ...interface{}) { l.l.Printf(format, v...) }
+
+func (l *printLogger) log(level Level, msg string, kv []interface{}) {
+	if len(l.fields) > 0 {
+		kv = append(l.fields[:len(l.fields):len(l.fields)], kv...)
+	}
+	var b bytes.Buffer
+	writeText(&b, level, msg, kv)
+	l.l.Printf("%s", b.String())
+}
+
+// value return the value at i; errors are logged through their message
+func value(kv []interface{}, i int) interface{} {
+	if i >= len(kv) {
+		return "(missing)"
+	}
+	if err, ok := kv[i].(error); ok && err != nil {
+		return err.Error()
+	}
+	return kv[i]
 }
 
-func (d defautlLogger) Println(v ...interface{}) {
-	if d.verbose {
-		log.Println("dl:", fmt.Sprintln(v...))
+func writeJSON(b *bytes.Buffer, v interface{}) {
+	bb, err := json.Marshal(v)
+	if err != nil {
+		bb, _ = json.Marshal(fmt.Sprint(v))
 	}
+	b.Write(bb)
 }
 
-func (d defautlLogger) Printf(format string, v ...interface{}) {
-	if d.verbose {
-		log.Println("dl:", fmt.Sprintf(format, v...))
+// quote quote the text values which can't be told apart otherwise
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
 	}
+	return s
 }
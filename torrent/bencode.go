@@ -0,0 +1,156 @@
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// decoder decodes bencoded data into int64, string, []interface{} and map[string]interface{} values
+type decoder struct {
+	data []byte
+	pos  int
+
+	depth  int
+	rawKey string // the raw bytes of the top level dict value with this key are recorded
+	raw    []byte
+}
+
+// decode decode a single bencoded value
+func decode(data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+	return d.value()
+}
+
+// decodeWithRaw decode a bencoded value and return the raw bytes of the value of the given dict key
+func decodeWithRaw(data []byte, key string) (interface{}, []byte, error) {
+	d := &decoder{data: data, rawKey: key}
+	v, err := d.value()
+	return v, d.raw, err
+}
+
+func (d *decoder) value() (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, errors.New("bencode: unexpected end of data")
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		end := bytes.IndexByte(d.data[d.pos:], 'e')
+		if end < 0 {
+			return nil, errors.New("bencode: unterminated integer")
+		}
+		n, err := strconv.ParseInt(string(d.data[d.pos+1:d.pos+end]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bencode: invalid integer: %v", err)
+		}
+		d.pos += end + 1
+		return n, nil
+	case c >= '0' && c <= '9':
+		colon := bytes.IndexByte(d.data[d.pos:], ':')
+		if colon < 0 {
+			return nil, errors.New("bencode: invalid string length")
+		}
+		n, err := strconv.Atoi(string(d.data[d.pos : d.pos+colon]))
+		if err != nil || n < 0 {
+			return nil, errors.New("bencode: invalid string length")
+		}
+		start := d.pos + colon + 1
+		if start+n > len(d.data) {
+			return nil, errors.New("bencode: string exceeds data")
+		}
+		d.pos = start + n
+		return string(d.data[start : start+n]), nil
+	case c == 'l':
+		d.pos++
+		list := make([]interface{}, 0)
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			v, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		d.pos++
+		return list, nil
+	case c == 'd':
+		d.pos++
+		d.depth++
+		defer func() { d.depth-- }()
+		dict := make(map[string]interface{})
+		for d.pos < len(d.data) && d.data[d.pos] != 'e' {
+			k, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, errors.New("bencode: dict key must be a string")
+			}
+			start := d.pos
+			v, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			if d.depth == 1 && d.rawKey != "" && key == d.rawKey {
+				d.raw = d.data[start:d.pos]
+			}
+			dict[key] = v
+		}
+		d.pos++
+		return dict, nil
+	}
+	return nil, fmt.Errorf("bencode: invalid character %q", d.data[d.pos])
+}
+
+// encode bencode a value made of integers, strings, byte slices, lists and string keyed maps
+func encode(v interface{}) []byte {
+	buf := &bytes.Buffer{}
+	encodeTo(buf, v)
+	return buf.Bytes()
+}
+
+func encodeTo(buf *bytes.Buffer, v interface{}) {
+	switch t := v.(type) {
+	case int:
+		fmt.Fprintf(buf, "i%de", t)
+	case int64:
+		fmt.Fprintf(buf, "i%de", t)
+	case string:
+		fmt.Fprintf(buf, "%d:%s", len(t), t)
+	case []byte:
+		fmt.Fprintf(buf, "%d:", len(t))
+		buf.Write(t)
+	case []interface{}:
+		buf.WriteByte('l')
+		for _, e := range t {
+			encodeTo(buf, e)
+		}
+		buf.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, k := range keys {
+			encodeTo(buf, k)
+			encodeTo(buf, t[k])
+		}
+		buf.WriteByte('e')
+	}
+}
+
+// dictInt return the integer value of the key
+func dictInt(d map[string]interface{}, key string) int64 {
+	n, _ := d[key].(int64)
+	return n
+}
+
+// dictString return the string value of the key
+func dictString(d map[string]interface{}, key string) string {
+	s, _ := d[key].(string)
+	return s
+}
@@ -0,0 +1,688 @@
+package torrent
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thedevsaddam/dl/logger"
+)
+
+const (
+	blockSize       = 16 * 1024
+	maxBacklog      = 5
+	defaultMaxPeers = 30
+	announceEvery   = 30 * time.Second
+)
+
+// Options represents the torrent client configuration
+type Options struct {
+	MaxPeers  int           // maximum number of simultaneous peer connections
+	Port      int           // listen port for incoming peers; 0 picks a random port
+	SeedRatio float64       // keep seeding until uploaded/size reaches the ratio; 0 disables seeding
+	Log       logger.Logger // verbose logger
+}
+
+// Torrent represents a single torrent download
+type Torrent struct {
+	meta     *MetaInfo
+	infoHash [20]byte
+	trackers []string
+	opt      Options
+	log      logger.LevelLogger
+	peerID   [20]byte
+
+	storage  *storage
+	listener net.Listener
+	port     int
+
+	mu       sync.Mutex
+	have     bitfield
+	pending  []int                // pieces waiting to be downloaded
+	peers    map[string]*peerConn // connected peers
+	known    map[string]bool      // peer addresses ever tried
+	verified int                  // number of verified pieces
+	done     chan struct{}        // closed once all pieces are verified
+	progress *uint64              // downloaded bytes counter shared with the caller
+	pieces   *int32               // verified pieces counter shared with the caller
+
+	uploaded   int64
+	downloaded int64
+}
+
+// New return a torrent for the meta information
+func New(mi *MetaInfo, opt Options) *Torrent {
+	t := newTorrent(mi.InfoHash, mi.Trackers, opt)
+	t.meta = mi
+	return t
+}
+
+// NewFromMagnet return a torrent for the magnet; the meta information is fetched from the peers by Resolve
+func NewFromMagnet(m *Magnet, opt Options) *Torrent {
+	return newTorrent(m.InfoHash, m.Trackers, opt)
+}
+
+func newTorrent(infoHash [20]byte, trackers []string, opt Options) *Torrent {
+	if opt.MaxPeers <= 0 {
+		opt.MaxPeers = defaultMaxPeers
+	}
+	valid := make([]string, 0, len(trackers))
+	for _, tr := range trackers {
+		if isTracker(tr) {
+			valid = append(valid, tr)
+		}
+	}
+	return &Torrent{
+		infoHash: infoHash,
+		trackers: valid,
+		opt:      opt,
+		log:      logger.Leveled(opt.Log).With("component", "torrent"),
+		peerID:   newPeerID(),
+		peers:    make(map[string]*peerConn),
+		known:    make(map[string]bool),
+		done:     make(chan struct{}),
+	}
+}
+
+// MetaInfo return the meta information; nil until resolved for magnets
+func (t *Torrent) MetaInfo() *MetaInfo {
+	return t.meta
+}
+
+// Uploaded return the number of bytes served to other peers
+func (t *Torrent) Uploaded() int64 {
+	return atomic.LoadInt64(&t.uploaded)
+}
+
+// Resolve fetch the meta information from the peers (BEP 9) if it's not known yet
+func (t *Torrent) Resolve(ctx context.Context) (*MetaInfo, error) {
+	if t.meta != nil {
+		return t.meta, nil
+	}
+	if len(t.trackers) == 0 {
+		return nil, errors.New("torrent: magnet has no supported trackers")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make(chan *MetaInfo, 1)
+	tried := make(map[string]bool)
+	for ctx.Err() == nil {
+		for _, addr := range t.announceAll(ctx, eventStarted) {
+			if tried[addr] {
+				continue
+			}
+			tried[addr] = true
+			go func(addr string) {
+				mi, err := t.fetchMetadata(ctx, addr)
+				if err != nil {
+					t.log.Warn("failed to fetch metadata", "peer", addr, "error", err)
+					return
+				}
+				select {
+				case found <- mi:
+				default:
+				}
+			}(addr)
+		}
+
+		select {
+		case mi := <-found:
+			t.meta = mi
+			t.meta.Trackers = t.trackers
+			return mi, nil
+		case <-ctx.Done():
+		case <-time.After(announceEvery):
+		}
+	}
+	return nil, ctx.Err()
+}
+
+// fetchMetadata download the info dictionary from a single peer
+func (t *Torrent) fetchMetadata(ctx context.Context, addr string) (*MetaInfo, error) {
+	p, err := dialPeer(addr, t.infoHash, t.peerID)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+	go func() {
+		<-ctx.Done()
+		p.close()
+	}()
+
+	if err := p.sendExtended(0, map[string]interface{}{"m": map[string]interface{}{"ut_metadata": 1}}, nil); err != nil {
+		return nil, err
+	}
+
+	var (
+		metadata []byte
+		received int
+	)
+	for {
+		m, err := p.read()
+		if err != nil {
+			return nil, err
+		}
+		if m == nil || m.id != msgExtended || len(m.payload) == 0 {
+			p.handle(m)
+			continue
+		}
+
+		if m.payload[0] == 0 {
+			p.handle(m)
+			id, size := p.extensions["ut_metadata"], p.extensions["metadata_size"]
+			if id == 0 || size <= 0 || size > 16<<20 {
+				return nil, errors.New("peer does not support metadata exchange")
+			}
+			metadata = make([]byte, size)
+			for i := 0; int64(i*blockSize) < size; i++ {
+				if err := p.sendExtended(id, map[string]interface{}{"msg_type": 0, "piece": i}, nil); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		d := &decoder{data: m.payload[1:]}
+		v, err := d.value()
+		if err != nil || metadata == nil {
+			continue
+		}
+		msg, _ := v.(map[string]interface{})
+		if dictInt(msg, "msg_type") != 1 {
+			return nil, errors.New("peer rejected the metadata request")
+		}
+		offset := int(dictInt(msg, "piece")) * blockSize
+		block := m.payload[1+d.pos:]
+		if offset < 0 || offset+len(block) > len(metadata) {
+			return nil, errors.New("invalid metadata piece")
+		}
+		copy(metadata[offset:], block)
+		received += len(block)
+		if received < len(metadata) {
+			continue
+		}
+
+		if sha1.Sum(metadata) != t.infoHash {
+			return nil, errors.New("metadata hash mismatch")
+		}
+		return parseInfo(metadata)
+	}
+}
+
+// Download download the torrent to path, the file of a single file torrent or the directory of a multi-file torrent;
+// progress and pieces are updated atomically while downloading
+func (t *Torrent) Download(ctx context.Context, path string, progress *uint64, pieces *int32) error {
+	if t.meta == nil {
+		return errors.New("torrent: meta information is not resolved")
+	}
+	t.progress, t.pieces = progress, pieces
+
+	s, err := openStorage(path, t.meta)
+	if err != nil {
+		return err
+	}
+	t.storage = s
+
+	t.have = make(bitfield, (len(t.meta.Pieces)+7)/8)
+	t.checkExisting()
+
+	t.listen(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go t.connectLoop(ctx)
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		t.storage.close()
+		return ctx.Err()
+	}
+
+	t.announceAll(context.Background(), eventCompleted)
+	return nil
+}
+
+// Seed keep serving pieces until the seed ratio is reached or ctx is cancelled
+func (t *Torrent) Seed(ctx context.Context) error {
+	defer t.Close()
+	if t.opt.SeedRatio <= 0 {
+		return nil
+	}
+	target := int64(t.opt.SeedRatio * float64(t.meta.Length()))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go t.connectLoop(ctx)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for t.Uploaded() < target {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	t.announceAll(context.Background(), eventStopped)
+	return nil
+}
+
+// Close release the listener, the connections and the files
+func (t *Torrent) Close() {
+	if t.listener != nil {
+		t.listener.Close()
+	}
+	t.mu.Lock()
+	for _, p := range t.peers {
+		p.close()
+	}
+	t.mu.Unlock()
+	if t.storage != nil {
+		t.storage.close()
+	}
+}
+
+// checkExisting verify the pieces already present on disk, e.g: resuming a download
+func (t *Torrent) checkExisting() {
+	for i := range t.meta.Pieces {
+		buf := make([]byte, t.meta.pieceSize(i))
+		if err := t.storage.readAt(buf, int64(i)*t.meta.PieceLength); err == nil && sha1.Sum(buf) == t.meta.Pieces[i] {
+			t.markHave(i, len(buf))
+			continue
+		}
+		t.pending = append(t.pending, i)
+	}
+}
+
+// listen accept incoming peer connections
+func (t *Torrent) listen(ctx context.Context) {
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(t.opt.Port))
+	if err != nil {
+		t.log.Error("failed to listen for peers", "error", err)
+		t.port = t.opt.Port
+		return
+	}
+	t.listener = l
+	t.port = l.Addr().(*net.TCPAddr).Port
+	t.log.Info("listening for peers", "port", t.port)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if err := handshake(conn, t.infoHash, t.peerID); err != nil {
+					conn.Close()
+					return
+				}
+				t.runPeer(ctx, &peerConn{conn: conn, addr: conn.RemoteAddr().String(), choked: true})
+			}()
+		}
+	}()
+}
+
+// connectLoop periodically announce and connect to new peers
+func (t *Torrent) connectLoop(ctx context.Context) {
+	event := eventStarted
+	for {
+		for _, addr := range t.announceAll(ctx, event) {
+			t.mu.Lock()
+			skip := t.known[addr] || len(t.peers) >= t.opt.MaxPeers
+			t.known[addr] = true
+			t.mu.Unlock()
+			if skip {
+				continue
+			}
+			go func(addr string) {
+				p, err := dialPeer(addr, t.infoHash, t.peerID)
+				if err != nil {
+					t.log.Debug("failed to connect peer", "peer", addr, "error", err)
+					t.mu.Lock()
+					delete(t.known, addr)
+					t.mu.Unlock()
+					return
+				}
+				t.runPeer(ctx, p)
+				t.mu.Lock()
+				delete(t.known, addr)
+				t.mu.Unlock()
+			}(addr)
+		}
+		event = eventNone
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(announceEvery):
+		}
+	}
+}
+
+// announceAll announce to every tracker and return the unique peer addresses
+func (t *Torrent) announceAll(ctx context.Context, event string) []string {
+	left := int64(0)
+	if t.meta != nil {
+		left = t.meta.Length() - atomic.LoadInt64(&t.downloaded)
+	}
+	req := announceRequest{
+		infoHash:   t.infoHash,
+		peerID:     t.peerID,
+		port:       t.port,
+		uploaded:   atomic.LoadInt64(&t.uploaded),
+		downloaded: atomic.LoadInt64(&t.downloaded),
+		left:       left,
+		event:      event,
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		addrs []string
+	)
+	for _, tr := range t.trackers {
+		wg.Add(1)
+		go func(tr string) {
+			defer wg.Done()
+			peers, err := announce(ctx, tr, req)
+			if err != nil {
+				t.log.Warn("announce failed", "tracker", tr, "error", err)
+				return
+			}
+			mu.Lock()
+			for _, p := range peers {
+				addrs = appendUnique(addrs, p)
+			}
+			mu.Unlock()
+		}(tr)
+	}
+	wg.Wait()
+	return addrs
+}
+
+// runPeer exchange pieces with a connected peer until the connection or ctx ends
+func (t *Torrent) runPeer(ctx context.Context, p *peerConn) {
+	t.mu.Lock()
+	if _, ok := t.peers[p.addr]; ok || len(t.peers) >= t.opt.MaxPeers {
+		t.mu.Unlock()
+		p.close()
+		return
+	}
+	t.peers[p.addr] = p
+	have := append(bitfield(nil), t.have...)
+	t.mu.Unlock()
+
+	stop := make(chan struct{})
+	defer func() {
+		close(stop)
+		p.close()
+		t.mu.Lock()
+		delete(t.peers, p.addr)
+		t.mu.Unlock()
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.close()
+		case <-stop:
+		}
+	}()
+
+	hs := map[string]interface{}{"m": map[string]interface{}{"ut_metadata": 1}}
+	if t.meta != nil && t.meta.rawInfo != nil {
+		hs["metadata_size"] = len(t.meta.rawInfo)
+	}
+	if p.sendExtended(0, hs, nil) != nil || p.send(msgBitfield, have) != nil ||
+		p.send(msgUnchoke, nil) != nil || p.send(msgInterested, nil) != nil {
+		return
+	}
+
+	var work *pieceWork
+	defer func() {
+		if work != nil {
+			t.requeue(work.index)
+		}
+	}()
+
+	for {
+		if work == nil {
+			if i, ok := t.pick(p); ok {
+				work = newPieceWork(i, int(t.meta.pieceSize(i)))
+			}
+		}
+		if work != nil && !p.choked {
+			for work.backlog < maxBacklog {
+				begin, length, ok := work.next()
+				if !ok {
+					break
+				}
+				if err := p.sendRequest(work.index, begin, length); err != nil {
+					return
+				}
+			}
+		}
+
+		m, err := p.read()
+		if err != nil {
+			return
+		}
+		wasChoked := p.choked
+		if p.handle(m) {
+			if p.choked && !wasChoked && work != nil {
+				work.reset() // pending requests are discarded on choke
+			}
+			continue
+		}
+
+		switch m.id {
+		case msgPiece:
+			index, begin, block, err := parsePiece(m)
+			if err != nil || work == nil || index != work.index {
+				continue
+			}
+			if !work.receive(begin, block) {
+				continue
+			}
+			atomic.AddUint64(t.progress, uint64(len(block)))
+			if work.complete() {
+				t.finishPiece(work)
+				work = nil
+			}
+		case msgRequest:
+			if err := t.serve(p, m.payload); err != nil {
+				return
+			}
+		case msgExtended:
+			t.serveMetadata(p, m.payload)
+		}
+	}
+}
+
+// pick take a pending piece the peer has
+func (t *Torrent) pick(p *peerConn) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, i := range t.pending {
+		if p.bitfield.has(i) {
+			t.pending = append(t.pending[:k], t.pending[k+1:]...)
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// requeue put back a piece that could not be completed
+func (t *Torrent) requeue(i int) {
+	t.mu.Lock()
+	t.pending = append(t.pending, i)
+	t.mu.Unlock()
+}
+
+// finishPiece verify and store a downloaded piece
+func (t *Torrent) finishPiece(w *pieceWork) {
+	if sha1.Sum(w.buf) != t.meta.Pieces[w.index] {
+		t.log.Warn("piece failed hash check", "piece", w.index)
+		atomic.AddUint64(t.progress, ^uint64(len(w.buf)-1))
+		t.requeue(w.index)
+		return
+	}
+	if err := t.storage.writeAt(w.buf, int64(w.index)*t.meta.PieceLength); err != nil {
+		t.log.Error("failed to write piece", "piece", w.index, "error", err)
+		atomic.AddUint64(t.progress, ^uint64(len(w.buf)-1))
+		t.requeue(w.index)
+		return
+	}
+	atomic.AddInt64(&t.downloaded, int64(len(w.buf)))
+	t.markHave(w.index, 0)
+
+	t.mu.Lock()
+	peers := make([]*peerConn, 0, len(t.peers))
+	for _, p := range t.peers {
+		peers = append(peers, p)
+	}
+	t.mu.Unlock()
+	for _, p := range peers {
+		p.sendHave(w.index)
+	}
+}
+
+// markHave record a verified piece; existing bytes are added to the progress counter
+func (t *Torrent) markHave(i int, existing int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.have.has(i) {
+		return
+	}
+	t.have.set(i)
+	t.verified++
+	if existing > 0 && t.progress != nil {
+		atomic.AddUint64(t.progress, uint64(existing))
+	}
+	if t.pieces != nil {
+		atomic.AddInt32(t.pieces, 1)
+	}
+	if t.verified == len(t.meta.Pieces) {
+		close(t.done)
+	}
+}
+
+// serve answer a block request from the peer
+func (t *Torrent) serve(p *peerConn, payload []byte) error {
+	if len(payload) != 12 {
+		return nil
+	}
+	index := int(binary.BigEndian.Uint32(payload[0:]))
+	begin := int64(binary.BigEndian.Uint32(payload[4:]))
+	length := int64(binary.BigEndian.Uint32(payload[8:]))
+
+	t.mu.Lock()
+	ok := t.have.has(index)
+	t.mu.Unlock()
+	if !ok || length > 128*1024 || begin+length > t.meta.pieceSize(index) {
+		return nil
+	}
+
+	block := make([]byte, 8+length)
+	copy(block, payload[:8])
+	if err := t.storage.readAt(block[8:], int64(index)*t.meta.PieceLength+begin); err != nil {
+		return err
+	}
+	if err := p.send(msgPiece, block); err != nil {
+		return err
+	}
+	atomic.AddInt64(&t.uploaded, length)
+	return nil
+}
+
+// serveMetadata answer ut_metadata requests (BEP 9)
+func (t *Torrent) serveMetadata(p *peerConn, payload []byte) {
+	id := p.extensions["ut_metadata"]
+	if len(payload) == 0 || payload[0] != 1 || id == 0 {
+		return
+	}
+	v, err := decode(payload[1:])
+	if err != nil {
+		return
+	}
+	req, _ := v.(map[string]interface{})
+	if dictInt(req, "msg_type") != 0 {
+		return
+	}
+	piece := dictInt(req, "piece")
+	if t.meta == nil || t.meta.rawInfo == nil || piece*blockSize >= int64(len(t.meta.rawInfo)) {
+		p.sendExtended(id, map[string]interface{}{"msg_type": 2, "piece": piece}, nil)
+		return
+	}
+	end := minInt64((piece+1)*blockSize, int64(len(t.meta.rawInfo)))
+	p.sendExtended(id, map[string]interface{}{
+		"msg_type":   1,
+		"piece":      piece,
+		"total_size": len(t.meta.rawInfo),
+	}, t.meta.rawInfo[piece*blockSize:end])
+}
+
+// pieceWork represents the download state of a single piece
+type pieceWork struct {
+	index   int
+	buf     []byte
+	state   []byte // per block: 0 missing, 1 requested, 2 received
+	backlog int
+	got     int
+}
+
+func newPieceWork(index, size int) *pieceWork {
+	return &pieceWork{index: index, buf: make([]byte, size), state: make([]byte, (size+blockSize-1)/blockSize)}
+}
+
+// next return the next block to request
+func (w *pieceWork) next() (int, int, bool) {
+	for b, s := range w.state {
+		if s == 0 {
+			w.state[b] = 1
+			w.backlog++
+			begin := b * blockSize
+			return begin, int(minInt64(blockSize, int64(len(w.buf)-begin))), true
+		}
+	}
+	return 0, 0, false
+}
+
+// receive store a block; return false for unexpected blocks
+func (w *pieceWork) receive(begin int, block []byte) bool {
+	b := begin / blockSize
+	if begin%blockSize != 0 || b >= len(w.state) || w.state[b] == 2 || begin+len(block) > len(w.buf) {
+		return false
+	}
+	copy(w.buf[begin:], block)
+	if w.state[b] == 1 {
+		w.backlog--
+	}
+	w.state[b] = 2
+	w.got++
+	return true
+}
+
+// reset mark the requested blocks as missing
+func (w *pieceWork) reset() {
+	for b, s := range w.state {
+		if s == 1 {
+			w.state[b] = 0
+		}
+	}
+	w.backlog = 0
+}
+
+func (w *pieceWork) complete() bool {
+	return w.got == len(w.state)
+}
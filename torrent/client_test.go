@@ -0,0 +1,226 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tracker is a local HTTP tracker returning every other announced peer of the torrent
+type tracker struct {
+	mu    sync.Mutex
+	peers map[string]map[string]bool // info hash to the announced addresses
+}
+
+func newTracker() *httptest.Server {
+	tr := &tracker{peers: make(map[string]map[string]bool)}
+	return httptest.NewServer(tr)
+}
+
+func (tr *tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	hash := q.Get("info_hash")
+	addr := net.JoinHostPort("127.0.0.1", q.Get("port"))
+
+	tr.mu.Lock()
+	if tr.peers[hash] == nil {
+		tr.peers[hash] = make(map[string]bool)
+	}
+	var compact []byte
+	for a := range tr.peers[hash] {
+		if a == addr {
+			continue
+		}
+		host, port, _ := net.SplitHostPort(a)
+		p, _ := strconv.Atoi(port)
+		compact = append(compact, net.ParseIP(host).To4()...)
+		compact = append(compact, byte(p>>8), byte(p))
+	}
+	if q.Get("port") != "0" && q.Get("event") != eventStopped {
+		tr.peers[hash][addr] = true
+	}
+	tr.mu.Unlock()
+
+	w.Write(encode(map[string]interface{}{"interval": 30, "peers": compact}))
+}
+
+// testTorrent build the .torrent of the files; a single file is stored as name
+func testTorrent(t *testing.T, announce, name string, files map[string][]byte) ([]byte, *MetaInfo) {
+	t.Helper()
+	const pieceLength = 32 << 10
+
+	info := map[string]interface{}{"name": name, "piece length": pieceLength}
+	var content []byte
+	if len(files) == 1 && files[name] != nil {
+		content = files[name]
+		info["length"] = len(content)
+	} else {
+		var list []interface{}
+		for _, p := range sortedKeys(files) {
+			content = append(content, files[p]...)
+			list = append(list, map[string]interface{}{
+				"length": len(files[p]),
+				"path":   []interface{}{filepath.Dir(p), filepath.Base(p)},
+			})
+		}
+		info["files"] = list
+	}
+	var pieces []byte
+	for i := 0; i < len(content); i += pieceLength {
+		end := i + pieceLength
+		if end > len(content) {
+			end = len(content)
+		}
+		h := sha1.Sum(content[i:end])
+		pieces = append(pieces, h[:]...)
+	}
+	info["pieces"] = pieces
+
+	data := encode(map[string]interface{}{"announce": announce, "info": info})
+	mi, err := ParseMetaInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, mi
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func randomBytes(n int, seed int64) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+// seed start an in-process peer serving the complete files stored at path
+func seed(t *testing.T, ctx context.Context, mi *MetaInfo, path string, files map[string][]byte) {
+	t.Helper()
+	for p, b := range files {
+		fn := path
+		if mi.Multi {
+			fn = filepath.Join(path, p)
+		}
+		os.MkdirAll(filepath.Dir(fn), os.ModePerm)
+		if err := ioutil.WriteFile(fn, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seeder := New(mi, Options{})
+	var progress uint64
+	var pieces int32
+	// the pieces on disk complete the download at once, the seeder keeps serving until ctx is cancelled
+	if err := seeder.Download(ctx, path, &progress, &pieces); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(seeder.Close)
+}
+
+func TestDownload(t *testing.T) {
+	tr := newTracker()
+	defer tr.Close()
+
+	tests := map[string]map[string][]byte{
+		"single.bin": {"single.bin": randomBytes(300<<10+7, 1)},
+		"multi": {
+			filepath.Join("a", "one.bin"): randomBytes(100<<10+3, 2),
+			filepath.Join("b", "two.bin"): randomBytes(50<<10, 3),
+		},
+	}
+	for name, files := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_, mi := testTorrent(t, tr.URL+"/announce", name, files)
+		seed(t, ctx, mi, filepath.Join(t.TempDir(), name), files)
+
+		// the leecher stores it under another name
+		dst := filepath.Join(t.TempDir(), "renamed")
+		leecher := New(mi, Options{})
+		var progress uint64
+		var pieces int32
+		err := leecher.Download(ctx, dst, &progress, &pieces)
+		leecher.Close()
+		cancel()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for p, want := range files {
+			fn := dst
+			if mi.Multi {
+				fn = filepath.Join(dst, p)
+			}
+			got, err := ioutil.ReadFile(fn)
+			if err != nil || !bytes.Equal(got, want) {
+				t.Fatalf("%s: %s differs from the seeded file: %v", name, p, err)
+			}
+		}
+		if progress != uint64(mi.Length()) || int(pieces) != len(mi.Pieces) {
+			t.Errorf("%s: progress %d bytes %d pieces, want %d %d", name, progress, pieces, mi.Length(), len(mi.Pieces))
+		}
+	}
+}
+
+func TestDownloadMagnet(t *testing.T) {
+	tr := newTracker()
+	defer tr.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	files := map[string][]byte{"magnet.bin": randomBytes(200<<10+11, 4)}
+	_, mi := testTorrent(t, tr.URL+"/announce", "magnet.bin", files)
+	seed(t, ctx, mi, filepath.Join(t.TempDir(), "magnet.bin"), files)
+
+	m, err := ParseMagnet("magnet:?xt=urn:btih:" + hex.EncodeToString(mi.InfoHash[:]) + "&tr=" + tr.URL + "/announce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leecher := NewFromMagnet(m, Options{})
+	defer leecher.Close()
+	resolved, err := leecher.Resolve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Name != mi.Name || resolved.Length() != mi.Length() {
+		t.Fatalf("resolved %s of %d bytes, want %s of %d bytes", resolved.Name, resolved.Length(), mi.Name, mi.Length())
+	}
+
+	dst := filepath.Join(t.TempDir(), "magnet.bin")
+	var progress uint64
+	var pieces int32
+	if err := leecher.Download(ctx, dst, &progress, &pieces); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ioutil.ReadFile(dst)
+	if !bytes.Equal(got, files["magnet.bin"]) {
+		t.Fatal("downloaded file differs from the seeded file")
+	}
+}
+
+func TestParsePieceLength(t *testing.T) {
+	pieces := string(make([]byte, pieceHashLen))
+	for length, valid := range map[int64]bool{0: false, -1: false, 32 << 10: true, maxPieceLength: true, maxPieceLength + 1: false, 1 << 40: false} {
+		info := encode(map[string]interface{}{"name": "file.bin", "length": 1, "piece length": length, "pieces": pieces})
+		_, err := parseInfo(info)
+		if (err == nil) != valid {
+			t.Errorf("piece length %d: error %v, want valid %v", length, err, valid)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	netUrl "net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	pieceHashLen = 20
+	// maxPieceLength bounds the buffer of a piece; common torrents use up to 16 MiB
+	maxPieceLength = 64 << 20
+)
+
+// File represents a single file of a torrent
+type File struct {
+	Path   string // path relative to the torrent directory
+	Length int64
+}
+
+// MetaInfo represents the meta information of a torrent
+type MetaInfo struct {
+	InfoHash    [20]byte
+	Name        string
+	PieceLength int64
+	Pieces      [][20]byte
+	Files       []File // a single entry for single file torrents
+	Multi       bool   // whether the files are stored in a directory named Name
+	Trackers    []string
+
+	rawInfo []byte // bencoded info dictionary served to magnet peers
+}
+
+// Magnet represents a parsed magnet: uri
+type Magnet struct {
+	InfoHash [20]byte
+	Name     string
+	Trackers []string
+}
+
+// ParseMetaInfo parse the content of a .torrent file
+func ParseMetaInfo(data []byte) (*MetaInfo, error) {
+	v, rawInfo, err := decodeWithRaw(data, "info")
+	if err != nil {
+		return nil, err
+	}
+	root, ok := v.(map[string]interface{})
+	if !ok || rawInfo == nil {
+		return nil, errors.New("torrent: invalid torrent file")
+	}
+
+	mi, err := parseInfo(rawInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if a := dictString(root, "announce"); a != "" {
+		mi.Trackers = append(mi.Trackers, a)
+	}
+	if tiers, ok := root["announce-list"].([]interface{}); ok {
+		for _, tier := range tiers {
+			urls, _ := tier.([]interface{})
+			for _, u := range urls {
+				if s, ok := u.(string); ok {
+					mi.Trackers = appendUnique(mi.Trackers, s)
+				}
+			}
+		}
+	}
+	return mi, nil
+}
+
+// parseInfo parse the bencoded info dictionary
+func parseInfo(raw []byte) (*MetaInfo, error) {
+	v, err := decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	info, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("torrent: invalid info dictionary")
+	}
+
+	mi := &MetaInfo{
+		InfoHash:    sha1.Sum(raw),
+		rawInfo:     append([]byte(nil), raw...),
+		Name:        dictString(info, "name"),
+		PieceLength: dictInt(info, "piece length"),
+	}
+	if mi.Name == "" || mi.PieceLength <= 0 {
+		return nil, errors.New("torrent: info dictionary misses name or piece length")
+	}
+	if mi.PieceLength > maxPieceLength {
+		return nil, fmt.Errorf("torrent: piece length %d exceeds %d bytes", mi.PieceLength, maxPieceLength)
+	}
+	if strings.Contains(mi.Name, "..") || strings.ContainsAny(mi.Name, `/\`) {
+		return nil, fmt.Errorf("torrent: unsafe name: %q", mi.Name)
+	}
+
+	pieces := dictString(info, "pieces")
+	if len(pieces) == 0 || len(pieces)%pieceHashLen != 0 {
+		return nil, errors.New("torrent: invalid pieces")
+	}
+	for i := 0; i < len(pieces); i += pieceHashLen {
+		var h [20]byte
+		copy(h[:], pieces[i:i+pieceHashLen])
+		mi.Pieces = append(mi.Pieces, h)
+	}
+
+	if files, ok := info["files"].([]interface{}); ok {
+		mi.Multi = true
+		for _, f := range files {
+			fd, _ := f.(map[string]interface{})
+			parts, _ := fd["path"].([]interface{})
+			segments := make([]string, 0, len(parts))
+			for _, p := range parts {
+				s, _ := p.(string)
+				if s == "" || s == "." || s == ".." || strings.ContainsAny(s, `/\`) {
+					return nil, fmt.Errorf("torrent: unsafe file path: %v", parts)
+				}
+				segments = append(segments, s)
+			}
+			if len(segments) == 0 {
+				return nil, errors.New("torrent: file without path")
+			}
+			mi.Files = append(mi.Files, File{Path: filepath.Join(segments...), Length: dictInt(fd, "length")})
+		}
+	} else {
+		mi.Files = []File{{Path: mi.Name, Length: dictInt(info, "length")}}
+	}
+
+	total := mi.Length()
+	if expected := (total + mi.PieceLength - 1) / mi.PieceLength; expected != int64(len(mi.Pieces)) {
+		return nil, fmt.Errorf("torrent: expected %d pieces, got %d", expected, len(mi.Pieces))
+	}
+	return mi, nil
+}
+
+// Length return the total length of all the files
+func (m *MetaInfo) Length() int64 {
+	total := int64(0)
+	for _, f := range m.Files {
+		total += f.Length
+	}
+	return total
+}
+
+// pieceSize return the size of the piece
+func (m *MetaInfo) pieceSize(i int) int64 {
+	if i == len(m.Pieces)-1 {
+		if rem := m.Length() % m.PieceLength; rem != 0 {
+			return rem
+		}
+	}
+	return m.PieceLength
+}
+
+// ParseMagnet parse a magnet:?xt=urn:btih:... uri
+func ParseMagnet(uri string) (*Magnet, error) {
+	u, err := netUrl.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "magnet" {
+		return nil, errors.New("torrent: not a magnet uri")
+	}
+	q := u.Query()
+
+	m := &Magnet{Name: q.Get("dn"), Trackers: q["tr"]}
+	for _, xt := range q["xt"] {
+		if !strings.HasPrefix(xt, "urn:btih:") {
+			continue
+		}
+		h := strings.TrimPrefix(xt, "urn:btih:")
+		var b []byte
+		switch len(h) {
+		case 40:
+			b, err = hex.DecodeString(h)
+		case 32:
+			b, err = base32.StdEncoding.DecodeString(strings.ToUpper(h))
+		default:
+			err = errors.New("invalid length")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("torrent: invalid info hash %q: %v", h, err)
+		}
+		copy(m.InfoHash[:], b)
+		return m, nil
+	}
+	return nil, errors.New("torrent: magnet uri misses a btih info hash")
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, e := range list {
+		if e == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
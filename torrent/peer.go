@@ -0,0 +1,228 @@
+package torrent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const protocol = "BitTorrent protocol"
+
+// peer wire message ids
+const (
+	msgChoke         byte = 0
+	msgUnchoke       byte = 1
+	msgInterested    byte = 2
+	msgNotInterested byte = 3
+	msgHave          byte = 4
+	msgBitfield      byte = 5
+	msgRequest       byte = 6
+	msgPiece         byte = 7
+	msgCancel        byte = 8
+	msgExtended      byte = 20
+)
+
+const (
+	maxMessageLength = 1 << 20
+	dialTimeout      = 5 * time.Second
+	readTimeout      = 30 * time.Second
+)
+
+// message represents a peer wire message; a nil message is a keep-alive
+type message struct {
+	id      byte
+	payload []byte
+}
+
+// peerConn represents a connection to a peer after a successful handshake
+type peerConn struct {
+	conn       net.Conn
+	wmu        sync.Mutex // guards writes from the peer loop and the have broadcasts
+	addr       string
+	choked     bool
+	bitfield   bitfield
+	extensions map[string]int64 // extension name to the id used by the peer
+}
+
+// bitfield represents the pieces a peer has
+type bitfield []byte
+
+func (b bitfield) has(i int) bool {
+	if i/8 >= len(b) {
+		return false
+	}
+	return b[i/8]>>(7-uint(i%8))&1 != 0
+}
+
+func (b bitfield) set(i int) {
+	if i/8 < len(b) {
+		b[i/8] |= 1 << (7 - uint(i%8))
+	}
+}
+
+// handshake exchange the handshake over conn and verify the info hash
+func handshake(conn net.Conn, infoHash, peerID [20]byte) error {
+	conn.SetDeadline(time.Now().Add(dialTimeout * 2))
+	defer conn.SetDeadline(time.Time{})
+
+	buf := make([]byte, 0, 68)
+	buf = append(buf, byte(len(protocol)))
+	buf = append(buf, protocol...)
+	reserved := make([]byte, 8)
+	reserved[5] |= 0x10 // extension protocol (BEP 10)
+	buf = append(buf, reserved...)
+	buf = append(buf, infoHash[:]...)
+	buf = append(buf, peerID[:]...)
+	if _, err := conn.Write(buf); err != nil {
+		return err
+	}
+
+	resp := make([]byte, 68)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if int(resp[0]) != len(protocol) || string(resp[1:20]) != protocol {
+		return errors.New("torrent: invalid handshake")
+	}
+	if !bytes.Equal(resp[28:48], infoHash[:]) {
+		return errors.New("torrent: info hash mismatch")
+	}
+	if bytes.Equal(resp[48:68], peerID[:]) {
+		return errors.New("torrent: connected to self")
+	}
+	return nil
+}
+
+// dialPeer connect to the peer and perform the handshake
+func dialPeer(addr string, infoHash, peerID [20]byte) (*peerConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := handshake(conn, infoHash, peerID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &peerConn{conn: conn, addr: addr, choked: true}, nil
+}
+
+func (p *peerConn) close() error {
+	return p.conn.Close()
+}
+
+// read read the next message
+func (p *peerConn) read() (*message, error) {
+	p.conn.SetReadDeadline(time.Now().Add(readTimeout))
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(p.conn, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n == 0 {
+		return nil, nil
+	}
+	if n > maxMessageLength {
+		return nil, fmt.Errorf("torrent: message too large: %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(p.conn, buf); err != nil {
+		return nil, err
+	}
+	return &message{id: buf[0], payload: buf[1:]}, nil
+}
+
+// send write a message
+func (p *peerConn) send(id byte, payload []byte) error {
+	buf := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(1+len(payload)))
+	buf[4] = id
+	copy(buf[5:], payload)
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	p.conn.SetWriteDeadline(time.Now().Add(readTimeout))
+	_, err := p.conn.Write(buf)
+	return err
+}
+
+// sendRequest request a block of a piece
+func (p *peerConn) sendRequest(index, begin, length int) error {
+	payload := make([]byte, 12)
+	binary.BigEndian.PutUint32(payload[0:], uint32(index))
+	binary.BigEndian.PutUint32(payload[4:], uint32(begin))
+	binary.BigEndian.PutUint32(payload[8:], uint32(length))
+	return p.send(msgRequest, payload)
+}
+
+// sendHave announce a verified piece
+func (p *peerConn) sendHave(index int) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, uint32(index))
+	return p.send(msgHave, payload)
+}
+
+// sendExtended send an extension protocol message
+func (p *peerConn) sendExtended(id int64, payload interface{}, trailer []byte) error {
+	body := append([]byte{byte(id)}, encode(payload)...)
+	return p.send(msgExtended, append(body, trailer...))
+}
+
+// handle update the peer state from the message; returns true if the message was consumed
+func (p *peerConn) handle(m *message) bool {
+	if m == nil {
+		return true
+	}
+	switch m.id {
+	case msgChoke:
+		p.choked = true
+	case msgUnchoke:
+		p.choked = false
+	case msgBitfield:
+		p.bitfield = bitfield(append([]byte(nil), m.payload...))
+	case msgHave:
+		if len(m.payload) == 4 {
+			i := int(binary.BigEndian.Uint32(m.payload))
+			for len(p.bitfield) <= i/8 {
+				p.bitfield = append(p.bitfield, 0)
+			}
+			p.bitfield.set(i)
+		}
+	case msgExtended:
+		if len(m.payload) > 0 && m.payload[0] == 0 {
+			if v, err := decode(m.payload[1:]); err == nil {
+				if d, ok := v.(map[string]interface{}); ok {
+					if p.extensions == nil {
+						p.extensions = make(map[string]int64)
+					}
+					if ext, ok := d["m"].(map[string]interface{}); ok {
+						for k, id := range ext {
+							if n, ok := id.(int64); ok {
+								p.extensions[k] = n
+							}
+						}
+					}
+					if size := dictInt(d, "metadata_size"); size > 0 {
+						p.extensions["metadata_size"] = size
+					}
+				}
+			}
+			return true
+		}
+		return false
+	default:
+		return false
+	}
+	return true
+}
+
+// parsePiece return the index, begin offset and the block of a piece message
+func parsePiece(m *message) (int, int, []byte, error) {
+	if m.id != msgPiece || len(m.payload) < 8 {
+		return 0, 0, nil, errors.New("torrent: invalid piece message")
+	}
+	return int(binary.BigEndian.Uint32(m.payload[0:4])), int(binary.BigEndian.Uint32(m.payload[4:8])), m.payload[8:], nil
+}
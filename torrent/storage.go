@@ -0,0 +1,90 @@
+package torrent
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// storage maps the contiguous torrent byte space onto the files of the torrent
+type storage struct {
+	files   []*os.File
+	lengths []int64
+}
+
+// openStorage create (or open) the files of the torrent inside root; root is the file of a single file torrent
+func openStorage(root string, mi *MetaInfo) (*storage, error) {
+	s := &storage{}
+	for _, f := range mi.Files {
+		fn := root
+		if mi.Multi {
+			fn = filepath.Join(root, f.Path)
+		}
+		if err := os.MkdirAll(filepath.Dir(fn), os.ModePerm); err != nil {
+			s.close()
+			return nil, err
+		}
+		fp, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.files = append(s.files, fp)
+		s.lengths = append(s.lengths, f.Length)
+	}
+	return s, nil
+}
+
+// writeAt write p at the offset of the torrent byte space
+func (s *storage) writeAt(p []byte, off int64) error {
+	return s.span(len(p), off, func(f *os.File, b0, b1 int, fileOff int64) error {
+		_, err := f.WriteAt(p[b0:b1], fileOff)
+		return err
+	})
+}
+
+// readAt read len(p) bytes at the offset of the torrent byte space
+func (s *storage) readAt(p []byte, off int64) error {
+	return s.span(len(p), off, func(f *os.File, b0, b1 int, fileOff int64) error {
+		_, err := f.ReadAt(p[b0:b1], fileOff)
+		if err == io.EOF {
+			err = nil
+		}
+		return err
+	})
+}
+
+// span call fn for every file region overlapped by [off, off+n)
+func (s *storage) span(n int, off int64, fn func(f *os.File, b0, b1 int, fileOff int64) error) error {
+	pos := 0
+	start := int64(0)
+	for i, length := range s.lengths {
+		end := start + length
+		if off+int64(pos) < end && pos < n {
+			fileOff := off + int64(pos) - start
+			chunk := int(minInt64(int64(n-pos), length-fileOff))
+			if err := fn(s.files[i], pos, pos+chunk, fileOff); err != nil {
+				return err
+			}
+			pos += chunk
+		}
+		start = end
+	}
+	if pos < n {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+func (s *storage) close() {
+	for _, f := range s.files {
+		f.Close()
+	}
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,205 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	netUrl "net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// announce events
+const (
+	eventNone      = ""
+	eventStarted   = "started"
+	eventCompleted = "completed"
+	eventStopped   = "stopped"
+)
+
+// announceRequest represents the state reported to the tracker
+type announceRequest struct {
+	infoHash   [20]byte
+	peerID     [20]byte
+	port       int
+	uploaded   int64
+	downloaded int64
+	left       int64
+	event      string
+}
+
+// announce report to the tracker and return the peer addresses
+func announce(ctx context.Context, tracker string, req announceRequest) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	u, err := netUrl.Parse(tracker)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return announceHTTP(ctx, u, req)
+	case "udp":
+		return announceUDP(ctx, u, req)
+	}
+	return nil, fmt.Errorf("torrent: unsupported tracker scheme: %s", u.Scheme)
+}
+
+func announceHTTP(ctx context.Context, u *netUrl.URL, req announceRequest) ([]string, error) {
+	q := fmt.Sprintf("info_hash=%s&peer_id=%s&port=%d&uploaded=%d&downloaded=%d&left=%d&compact=1",
+		netUrl.QueryEscape(string(req.infoHash[:])), netUrl.QueryEscape(string(req.peerID[:])),
+		req.port, req.uploaded, req.downloaded, req.left)
+	if req.event != eventNone {
+		q += "&event=" + req.event
+	}
+	if u.RawQuery != "" {
+		q = u.RawQuery + "&" + q
+	}
+	target := *u
+	target.RawQuery = q
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("torrent: invalid tracker response: %v", err)
+	}
+	d, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("torrent: invalid tracker response")
+	}
+	if reason := dictString(d, "failure reason"); reason != "" {
+		return nil, fmt.Errorf("torrent: tracker failure: %s", reason)
+	}
+
+	switch peers := d["peers"].(type) {
+	case string:
+		return compactPeers([]byte(peers)), nil
+	case []interface{}:
+		addrs := make([]string, 0, len(peers))
+		for _, p := range peers {
+			pd, _ := p.(map[string]interface{})
+			if ip := dictString(pd, "ip"); ip != "" {
+				addrs = append(addrs, net.JoinHostPort(ip, strconv.FormatInt(dictInt(pd, "port"), 10)))
+			}
+		}
+		return addrs, nil
+	}
+	return nil, nil
+}
+
+// announceUDP implement the UDP tracker protocol (BEP 15)
+func announceUDP(ctx context.Context, u *netUrl.URL, req announceRequest) ([]string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	roundTrip := func(packet []byte, action uint32, minLen int) ([]byte, error) {
+		txID := packet[12:16]
+		buf := make([]byte, 4096)
+		for attempt := 0; attempt < 3; attempt++ {
+			if _, err := conn.Write(packet); err != nil {
+				return nil, err
+			}
+			conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+			n, err := conn.Read(buf)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() && ctx.Err() == nil {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if n < 8 || !bytes.Equal(buf[4:8], txID) {
+				continue
+			}
+			if got := binary.BigEndian.Uint32(buf[:4]); got == 3 {
+				return nil, fmt.Errorf("torrent: tracker failure: %s", buf[8:n])
+			} else if got != action || n < minLen {
+				return nil, errors.New("torrent: invalid UDP tracker response")
+			}
+			return buf[:n], nil
+		}
+		return nil, errors.New("torrent: UDP tracker timeout")
+	}
+
+	connect := make([]byte, 16)
+	binary.BigEndian.PutUint64(connect[0:], 0x41727101980)
+	binary.BigEndian.PutUint32(connect[8:], 0)
+	rand.Read(connect[12:16])
+	resp, err := roundTrip(connect, 0, 16)
+	if err != nil {
+		return nil, err
+	}
+	connectionID := binary.BigEndian.Uint64(resp[8:16])
+
+	events := map[string]uint32{eventNone: 0, eventCompleted: 1, eventStarted: 2, eventStopped: 3}
+	packet := make([]byte, 98)
+	binary.BigEndian.PutUint64(packet[0:], connectionID)
+	binary.BigEndian.PutUint32(packet[8:], 1)
+	rand.Read(packet[12:16])
+	copy(packet[16:36], req.infoHash[:])
+	copy(packet[36:56], req.peerID[:])
+	binary.BigEndian.PutUint64(packet[56:], uint64(req.downloaded))
+	binary.BigEndian.PutUint64(packet[64:], uint64(req.left))
+	binary.BigEndian.PutUint64(packet[72:], uint64(req.uploaded))
+	binary.BigEndian.PutUint32(packet[80:], events[req.event])
+	rand.Read(packet[88:92]) // key
+	binary.BigEndian.PutUint32(packet[92:], 0xFFFFFFFF)
+	binary.BigEndian.PutUint16(packet[96:], uint16(req.port))
+
+	resp, err = roundTrip(packet, 1, 20)
+	if err != nil {
+		return nil, err
+	}
+	return compactPeers(resp[20:]), nil
+}
+
+// compactPeers decode the compact IPv4 peer list
+func compactPeers(b []byte) []string {
+	addrs := make([]string, 0, len(b)/6)
+	for i := 0; i+6 <= len(b); i += 6 {
+		ip := net.IP(b[i : i+4])
+		port := binary.BigEndian.Uint16(b[i+4 : i+6])
+		addrs = append(addrs, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+	}
+	return addrs
+}
+
+// newPeerID return a random peer id with the client prefix
+func newPeerID() [20]byte {
+	var id [20]byte
+	copy(id[:], "-DL0001-")
+	rand.Read(id[8:])
+	return id
+}
+
+// isTracker report whether the url is a supported tracker url
+func isTracker(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "udp://")
+}
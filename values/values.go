@@ -1,6 +1,10 @@
 package values
 
-import "strings"
+import (
+	"path"
+	"sort"
+	"strings"
+)
 
 // MapStrSliceString maps a string key to a list of MapStrSliceString.
 type MapStrSliceString map[string][]string
@@ -20,6 +24,25 @@ func (v MapStrSliceString) Get(ext string) string {
 	return ""
 }
 
+// Match return the key of the first pattern matching the value e.g: video/* for video/mp4; the keys are checked
+// in sorted order so that the result doesn't depend on the map order
+func (v MapStrSliceString) Match(value string) string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, k := range keys {
+		for _, p := range v[k] {
+			if ok, _ := path.Match(strings.ToLower(strings.TrimSpace(p)), value); ok {
+				return k
+			}
+		}
+	}
+	return ""
+}
+
 // Add adds the value to key. It appends to any existing
 // MapStrSliceString associated with key.
 func (v MapStrSliceString) Add(key, value string) {
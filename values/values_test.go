@@ -0,0 +1,46 @@
+package values
+
+import "testing"
+
+func TestMapStrSliceStringMatch(t *testing.T) {
+	v := MapStrSliceString{
+		"video":    {"video/*"},
+		"document": {"text/*", " Application/PDF "},
+		"audio":    {"*/ogg", "audio/*"},
+		"web":      {"text/html"}, // after document in sorted order
+	}
+	tests := map[string]string{
+		"video/mp4":       "video",
+		"application/pdf": "document",
+		"text/html":       "document",
+		"application/ogg": "audio",
+		"audio/mpeg":      "audio",
+		" VIDEO/WEBM ":    "video",
+		"image/png":       "",
+		"":                "",
+	}
+	for value, want := range tests {
+		for i := 0; i < 10; i++ { // the map order doesn't matter
+			if got := v.Match(value); got != want {
+				t.Fatalf("Match(%q) = %q, want %q", value, got, want)
+			}
+		}
+	}
+	if got := MapStrSliceString(nil).Match("video/mp4"); got != "" {
+		t.Fatalf("nil map matched %q", got)
+	}
+}
+
+func TestMapStrSliceStringGet(t *testing.T) {
+	v := MapStrSliceString{"video": {".mp4", ".MKV"}}
+	v.Add("video", ".mkv")
+	v.Add("video", ".webm")
+	if len(v["video"]) != 3 {
+		t.Fatalf("added duplicates: %v", v["video"])
+	}
+	for ext, want := range map[string]string{".mkv": "video", ".WEBM": "video", ".iso": "", "": ""} {
+		if got := v.Get(ext); got != want {
+			t.Errorf("Get(%q) = %q, want %q", ext, got, want)
+		}
+	}
+}